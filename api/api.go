@@ -0,0 +1,389 @@
+// Package api serves the data of the database as a JSON REST API.
+//
+// All requests must have the header "Authorization: Bearer <token>".
+//
+//	GET  /api/members                  members with their meters and latest readings
+//	GET  /api/members/{id}/readings    all readings of the member, the latest first
+//	GET  /api/meters                   meters with their latest readings
+//	GET  /api/bills                    latest bills
+//	POST /api/read                     read the meters, responds with the meters
+//	POST /api/bill[?dry_run=true]      bill the members, responds with the bills
+//
+// Reading the meters twice or billing twice without reading the meters in
+// between is refused with 409 Conflict.
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jarnoan/vesimittari/model"
+	"github.com/jarnoan/vesimittari/store"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+// Member is a member in the responses.
+type Member struct {
+	ID        int64       `json:"id"`
+	Name      string      `json:"name"`
+	JoinDate  *model.Date `json:"join_date,omitempty"`
+	LeaveDate *model.Date `json:"leave_date,omitempty"`
+	Meter     *Meter      `json:"meter,omitempty"`
+}
+
+// Meter is the water meter of a member with its latest reading.
+type Meter struct {
+	MemberID int64          `json:"member_id"`
+	Site     string         `json:"site"`
+	Number   string         `json:"number"`
+	Reading  *model.Reading `json:"reading,omitempty"`
+}
+
+// Bill is a bill of a member.
+type Bill struct {
+	MemberID  int64           `json:"member_id"`
+	Name      string          `json:"name"`
+	Reference string          `json:"reference"`
+	Lines     []Line          `json:"lines"`
+	Total     decimal.Decimal `json:"total"` // € with tax
+}
+
+// Line is a line of a bill. The amounts are in euros.
+type Line struct {
+	Description string          `json:"description"`
+	Quantity    decimal.Decimal `json:"quantity"`
+	Unit        string          `json:"unit,omitempty"`
+	UnitPrice   decimal.Decimal `json:"unit_price"` // without tax
+	VAT         decimal.Decimal `json:"vat"`        // %
+	WithoutTax  decimal.Decimal `json:"without_tax"`
+	Tax         decimal.Decimal `json:"tax"`
+	WithTax     decimal.Decimal `json:"with_tax"`
+}
+
+// Options are the options of the server.
+type Options struct {
+	Token           string              // required from the clients
+	MeterReader     updater.MeterReader // reads the meters of the read and bill requests
+	Updater         updater.Options
+	AdditionalCosts []updater.AdditionalCost // billed by the bill requests
+}
+
+// Server handles the requests of the API.
+type Server struct {
+	store *store.Store
+	opts  Options
+	mux   *http.ServeMux
+	mu    sync.Mutex // serialises the changes
+}
+
+// New constructs a new server for the database.
+func New(s *store.Store, opts Options) *Server {
+	res := &Server{store: s, opts: opts, mux: http.NewServeMux()}
+	res.mux.HandleFunc("/api/members", res.get(res.members))
+	res.mux.HandleFunc("/api/members/", res.get(res.readings))
+	res.mux.HandleFunc("/api/meters", res.get(res.meters))
+	res.mux.HandleFunc("/api/bills", res.get(res.bills))
+	res.mux.HandleFunc("/api/read", res.post(res.read))
+	res.mux.HandleFunc("/api/bill", res.post(res.bill))
+
+	return res
+}
+
+// ServeHTTP serves the request if it has the token.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	want := "Bearer " + s.opts.Token
+	if s.opts.Token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(want)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, errors.New("invalid token"))
+		return
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+// handler handles a request in a transaction and returns the response.
+type handler func(d *store.Data, r *http.Request) (interface{}, error)
+
+// httpError is an error with the status code of the response.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e httpError) Error() string {
+	return e.err.Error()
+}
+
+// get returns a handler of GET requests. The transaction is discarded.
+func (s *Server) get(h handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+
+		s.serve(w, r, h, false)
+	}
+}
+
+// post returns a handler of POST requests. The transaction is committed
+// unless the request has the parameter dry_run=true.
+func (s *Server) post(h handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+
+		dryRun, err := isDryRun(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.serve(w, r, h, !dryRun)
+	}
+}
+
+// isDryRun tells whether the request has the parameter dry_run=true.
+func isDryRun(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("dry_run")
+	if v == "" {
+		return false, nil
+	}
+
+	dryRun, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("dry_run: %w", err)
+	}
+
+	return dryRun, nil
+}
+
+// serve handles the request in a transaction and writes the response.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request, h handler, commit bool) {
+	d, err := s.store.Begin()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer d.Rollback()
+
+	res, err := h(d, r)
+	if err == nil && commit {
+		err = d.Commit()
+	}
+	if err != nil {
+		var he httpError
+		if !errors.As(err, &he) {
+			he = httpError{http.StatusInternalServerError, err}
+		}
+		writeError(w, he.status, he.err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(res); err != nil {
+		log.Printf("%s %s: write response: %s", r.Method, r.URL.Path, err)
+	}
+}
+
+// writeError writes the error as the response. Internal errors are also
+// logged.
+func writeError(w http.ResponseWriter, status int, err error) {
+	if status == http.StatusInternalServerError {
+		log.Print(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
+
+func (s *Server) members(d *store.Data, r *http.Request) (interface{}, error) {
+	return Members(d)
+}
+
+func (s *Server) meters(d *store.Data, r *http.Request) (interface{}, error) {
+	members, err := Members(d)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []Meter{}
+	for _, m := range members {
+		if m.Meter != nil {
+			res = append(res, *m.Meter)
+		}
+	}
+
+	return res, nil
+}
+
+// readings handles /api/members/{id}/readings.
+func (s *Server) readings(d *store.Data, r *http.Request) (interface{}, error) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/members/"), "/")
+	if len(parts) != 2 || parts[1] != "readings" {
+		return nil, httpError{http.StatusNotFound, fmt.Errorf("%s not found", r.URL.Path)}
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, httpError{http.StatusNotFound, fmt.Errorf("invalid member id %q", parts[0])}
+	}
+
+	mr, err := d.Record(id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, httpError{http.StatusNotFound, err}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rdgs, err := d.Readings(mr)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []model.Reading{}
+	for _, rdg := range rdgs {
+		res = append(res, model.Reading{Counter: rdg.Counter, Date: model.NewDate(rdg.Date), Check: rdg.Customer})
+	}
+
+	return res, nil
+}
+
+func (s *Server) bills(d *store.Data, r *http.Request) (interface{}, error) {
+	return Bills(d)
+}
+
+// read reads the meters and responds with the meters.
+func (s *Server) read(d *store.Data, r *http.Request) (interface{}, error) {
+	if err := updater.New(s.opts.MeterReader, s.opts.Updater).ReadMeters(d); err != nil {
+		return nil, conflict(err)
+	}
+
+	return s.meters(d, r)
+}
+
+// bill bills the members and responds with the new bills. A dry run
+// does not read the meters, as the readings could not be undone.
+func (s *Server) bill(d *store.Data, r *http.Request) (interface{}, error) {
+	opts := s.opts.Updater
+	if dryRun, _ := isDryRun(r); dryRun {
+		opts.UpdateMeterReadings = false
+	}
+	if _, err := updater.New(s.opts.MeterReader, opts).Update(d, s.opts.AdditionalCosts); err != nil {
+		return nil, conflict(err)
+	}
+
+	return Bills(d)
+}
+
+// conflict returns an error of reading or billing twice as a conflict.
+func conflict(err error) error {
+	if errors.Is(err, updater.ErrAlreadyRead) || errors.Is(err, updater.ErrNotRead) {
+		return httpError{http.StatusConflict, err}
+	}
+
+	return err
+}
+
+// Members returns the members with their meters and latest readings.
+func Members(d *store.Data) ([]Member, error) {
+	mrs, err := d.MeterRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	res := []Member{}
+	for _, mr := range mrs {
+		id, err := d.ID(mr)
+		if err != nil {
+			return nil, err
+		}
+		membership, err := mr.Membership()
+		if err != nil {
+			return nil, err
+		}
+		m := Member{ID: id, Name: mr.Name(), JoinDate: model.NewDate(membership.Start), LeaveDate: model.NewDate(membership.End)}
+
+		num, err := mr.MeterNumber()
+		if err != nil {
+			return nil, err
+		}
+		site, err := mr.SiteNumber()
+		if err != nil {
+			return nil, err
+		}
+		if num != "" || site != "" {
+			m.Meter = &Meter{MemberID: id, Site: string(site), Number: string(num)}
+			rdgs, err := d.Readings(mr)
+			if err != nil {
+				return nil, err
+			}
+			if len(rdgs) > 0 {
+				m.Meter.Reading = &model.Reading{Counter: rdgs[0].Counter, Date: model.NewDate(rdgs[0].Date), Check: rdgs[0].Customer}
+			}
+		}
+
+		res = append(res, m)
+	}
+
+	return res, nil
+}
+
+// Bills returns the latest bills of the members that have any lines.
+func Bills(d *store.Data) ([]Bill, error) {
+	mrs, err := d.MeterRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	res := []Bill{}
+	for _, mr := range mrs {
+		b, err := mr.Bill()
+		if err != nil {
+			return nil, err
+		}
+		if len(b.Lines) == 0 {
+			continue
+		}
+		id, err := d.ID(mr)
+		if err != nil {
+			return nil, err
+		}
+
+		bill := Bill{MemberID: id, Name: b.Name, Reference: string(b.Reference), Lines: []Line{}, Total: b.Total()}
+		for _, l := range b.Lines {
+			bill.Lines = append(bill.Lines, Line{
+				Description: l.Description,
+				Quantity:    l.Quantity,
+				Unit:        l.Unit,
+				UnitPrice:   l.UnitPrice,
+				VAT:         l.VAT,
+				WithoutTax:  l.WithoutTax,
+				Tax:         l.Tax,
+				WithTax:     l.WithTax,
+			})
+		}
+		res = append(res, bill)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarnoan/vesimittari/csv"
+	"github.com/jarnoan/vesimittari/meter"
+	"github.com/jarnoan/vesimittari/model"
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/jarnoan/vesimittari/store"
+	"github.com/jarnoan/vesimittari/updater"
+)
+
+const testData = `Nimi,Tilinumero,Puhelin,Sähköposti,Katuosoite,Postinumero,Postitoimipaikka,Kiinteistötunnus,Asukkaita,Vakituinen asunto,Liittynyt,Eronnut,Mittauspaikka,Mittari,Edellinen lukema,Edellinen pvm,Lukema,Pvm,Tarkistus,Kulutus,Vesimaksu alv 0,Vesimaksu alv,Vesimaksu,Kk,Perusmaksu alv 0,Perusmaksu alv,Perusmaksu,Lisämaksujen selite,Lisämaksut,Yhteensä,Viite
+Pää,,,,,,,,,,,,1,M0,900,1.1.2022,1000,1.7.2022,,100,,,,,,,,,,,1000012
+Aho,,,,,,,,,,,,2,M1,100,1.1.2022,140,1.7.2022,,40,"61,00","14,64","75,64","5,95","59,51","14,28","73,79",,"0,00","149,43",1000025
+Bäck,,,,,,,,,,,,3,M2,50,1.1.2022,110,1.7.2022,,60,"91,50","21,96","113,46","5,95","59,51","14,28","73,79",,"0,00","187,25",1000038
+###,,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Päivä,1.7.2022,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Maksuaika,14,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Päämittari,"30,00",,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Vesi,"1,525",,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+ALV,24,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Viesti,Hei,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+`
+
+// fakeReader reads 10 m³ more than the latest reading of the member.
+type fakeReader struct{}
+
+func (fakeReader) ReadMeter(site meter.SiteNumber, num meter.Number) (meter.Reading, error) {
+	counters := map[meter.Number]int{"M0": 1030, "M1": 150, "M2": 130}
+	return meter.Reading{Counter: counters[num], Date: time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)}, nil
+}
+
+// failingReader fails the test if a meter is read.
+type failingReader struct {
+	t *testing.T
+}
+
+func (r failingReader) ReadMeter(site meter.SiteNumber, num meter.Number) (meter.Reading, error) {
+	r.t.Errorf("meter %s read", num)
+	return meter.Reading{}, fmt.Errorf("meter %s read", num)
+}
+
+func testServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(New(testStore(t), Options{
+		Token:       "secret",
+		MeterReader: fakeReader{},
+		Updater: updater.Options{
+			MonthConvention: period.Actual365,
+			Date:            time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// testStore returns a store with the test data.
+func testStore(t *testing.T) *store.Store {
+	t.Helper()
+
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	f, err := csv.Read(strings.NewReader(testData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := f.Dataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := s.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Import(ds); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// do makes the request with the token and decodes the response to res.
+func do(t *testing.T, srv *httptest.Server, method, path string, res interface{}) int {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK && res != nil {
+		if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+func TestServer_auth(t *testing.T) {
+	srv := testServer(t)
+
+	for _, auth := range []string{"", "Bearer wrong", "secret"} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/members", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%q: status %d, want %d", auth, resp.StatusCode, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestServer_get(t *testing.T) {
+	srv := testServer(t)
+
+	var members []Member
+	if status := do(t, srv, http.MethodGet, "/api/members", &members); status != http.StatusOK {
+		t.Fatalf("members: status %d", status)
+	}
+	if len(members) != 3 || members[1].Name != "Aho" || members[1].Meter.Number != "M1" || members[1].Meter.Reading.Counter != 140 {
+		t.Fatalf("members = %+v", members)
+	}
+
+	var meters []Meter
+	if status := do(t, srv, http.MethodGet, "/api/meters", &meters); status != http.StatusOK || len(meters) != 3 {
+		t.Errorf("meters: status %d, %+v", status, meters)
+	}
+
+	var rdgs []model.Reading
+	path := "/api/members/" + strconv.FormatInt(members[1].ID, 10) + "/readings"
+	if status := do(t, srv, http.MethodGet, path, &rdgs); status != http.StatusOK {
+		t.Fatalf("readings: status %d", status)
+	}
+	if len(rdgs) != 2 || rdgs[0].Counter != 140 || rdgs[1].Counter != 100 {
+		t.Errorf("readings = %+v", rdgs)
+	}
+
+	var bills []Bill
+	if status := do(t, srv, http.MethodGet, "/api/bills", &bills); status != http.StatusOK {
+		t.Fatalf("bills: status %d", status)
+	}
+	if len(bills) != 2 || bills[0].Name != "Aho" || bills[0].Total.String() != "149.43" {
+		t.Errorf("bills = %+v", bills)
+	}
+
+	for path, want := range map[string]int{
+		"/api/members/999/readings": http.StatusNotFound,
+		"/api/members/x/readings":   http.StatusNotFound,
+		"/api/members/1/other":      http.StatusNotFound,
+	} {
+		if status := do(t, srv, http.MethodGet, path, nil); status != want {
+			t.Errorf("%s: status %d, want %d", path, status, want)
+		}
+	}
+	if status := do(t, srv, http.MethodPost, "/api/members", nil); status != http.StatusMethodNotAllowed {
+		t.Errorf("POST members: status %d", status)
+	}
+}
+
+func TestServer_post(t *testing.T) {
+	srv := testServer(t)
+
+	var bills []Bill
+	if status := do(t, srv, http.MethodPost, "/api/read", nil); status != http.StatusOK {
+		t.Fatalf("read: status %d", status)
+	}
+	if status := do(t, srv, http.MethodPost, "/api/bill?dry_run=true", &bills); status != http.StatusOK {
+		t.Fatalf("dry run: status %d", status)
+	}
+	if len(bills) != 2 || bills[0].Reference != "1000041" {
+		t.Errorf("dry run bills = %+v", bills)
+	}
+
+	// The dry run is not stored
+	var stored []Bill
+	do(t, srv, http.MethodGet, "/api/bills", &stored)
+	if len(stored) != 2 || stored[0].Reference != "1000025" {
+		t.Errorf("stored bills = %+v", stored)
+	}
+
+	if status := do(t, srv, http.MethodPost, "/api/bill", &bills); status != http.StatusOK {
+		t.Fatalf("bill: status %d", status)
+	}
+	do(t, srv, http.MethodGet, "/api/bills", &stored)
+	if len(stored) != 2 || stored[0].Reference != bills[0].Reference || !stored[1].Total.Equal(bills[1].Total) {
+		t.Errorf("stored bills = %+v, want %+v", stored, bills)
+	}
+	if status := do(t, srv, http.MethodPost, "/api/bill?dry_run=maybe", nil); status != http.StatusBadRequest {
+		t.Errorf("invalid dry run: status %d", status)
+	}
+
+	// The readings have been billed
+	if status := do(t, srv, http.MethodPost, "/api/bill", nil); status != http.StatusConflict {
+		t.Errorf("second bill: status %d", status)
+	}
+}
+
+func TestServer_post_readTwice(t *testing.T) {
+	srv := testServer(t)
+
+	if status := do(t, srv, http.MethodPost, "/api/read", nil); status != http.StatusOK {
+		t.Fatalf("read: status %d", status)
+	}
+	if status := do(t, srv, http.MethodPost, "/api/read", nil); status != http.StatusConflict {
+		t.Errorf("second read: status %d", status)
+	}
+}
+
+func TestServer_post_dryRunReadings(t *testing.T) {
+	srv := httptest.NewServer(New(testStore(t), Options{
+		Token:       "secret",
+		MeterReader: failingReader{t},
+		Updater: updater.Options{
+			MonthConvention:     period.Actual365,
+			Date:                time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC),
+			UpdateMeterReadings: true,
+		},
+	}))
+	t.Cleanup(srv.Close)
+
+	// The dry run refuses to bill the old readings instead of reading the meters
+	if status := do(t, srv, http.MethodPost, "/api/bill?dry_run=true", nil); status != http.StatusConflict {
+		t.Errorf("dry run: status %d", status)
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jarnoan/vesimittari/config"
+	"github.com/jarnoan/vesimittari/csv"
+	"github.com/jarnoan/vesimittari/meter"
+	"github.com/jarnoan/vesimittari/scraper"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+// runRead reads the meters without billing.
+func runRead(cfg config.Config, args []string) error {
+	var (
+		opts   updater.Options
+		portal string
+	)
+	fs := newFlagSet("read")
+	updaterFlags(fs, &opts, cfg)
+	fs.StringVar(&portal, "portal", cfg.Portal, "login page URL of the meter reading portal")
+	fs.Parse(args)
+
+	csvf, file, err := readData(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := updater.New(scraper.New(portal), opts).ReadMeters(csvf); err != nil {
+		return err
+	}
+
+	return writeData(file, csvf)
+}
+
+// runBill bills the members with the readings in the data.
+func runBill(cfg config.Config, args []string) error {
+	var (
+		opts         updater.Options
+		addCostsCSV  string
+		pricesCSV    string
+		invoicesFile string
+		journalFile  string
+		accountsCSV  string
+		portal       string
+	)
+	fs := newFlagSet("bill")
+	updaterFlags(fs, &opts, cfg)
+	fs.BoolVar(&opts.UpdateMeterReadings, "read", false, "read the meters before billing")
+	fs.StringVar(&portal, "portal", cfg.Portal, "login page URL of the meter reading portal")
+	fs.StringVar(&addCostsCSV, "add", "", "additional costs CSV file, overrides the configured costs")
+	fs.StringVar(&pricesCSV, "prices", "", "water price components CSV file, overrides the configured prices and the water price row")
+	fs.StringVar(&invoicesFile, "invoices", "", "write itemised bills as text to this file")
+	fs.StringVar(&journalFile, "journal", "", "write journal entries of the bills to this file for bookkeeping")
+	fs.StringVar(&accountsCSV, "accounts", "", "account numbers of the journal entries CSV file (entry, account)")
+	fs.Parse(args)
+
+	acs := cfg.AdditionalCostList()
+	if addCostsCSV != "" {
+		var err error
+		acs, err = additionalCosts(addCostsCSV)
+		if err != nil {
+			return fmt.Errorf("read additional costs csv: %w", err)
+		}
+	}
+
+	if pricesCSV != "" {
+		var err error
+		opts.WaterPrices, err = waterPrices(pricesCSV)
+		if err != nil {
+			return fmt.Errorf("read water prices csv: %w", err)
+		}
+	}
+
+	csvf, file, err := readData(cfg)
+	if err != nil {
+		return err
+	}
+
+	bills, err := updater.New(scraper.New(portal), opts).Update(csvf, acs)
+	if err != nil {
+		return err
+	}
+
+	due, err := dueDate(csvf)
+	if err != nil {
+		return err
+	}
+
+	if err := writeBillOutputs(cfg, csvf, bills, due, invoicesFile, journalFile, accountsCSV); err != nil {
+		return err
+	}
+
+	return writeData(file, csvf)
+}
+
+// runHandover hands a meter site over to a new owner.
+func runHandover(cfg config.Config, args []string) error {
+	var (
+		opts updater.Options
+		h    updater.Handover
+	)
+	fs := newFlagSet("handover")
+	updaterFlags(fs, &opts, cfg)
+	fs.StringVar(&h.Seller, "seller", "", "name of the member selling the property, billed up to the handover")
+	fs.StringVar(&h.Buyer, "buyer", "", "name of the new owner")
+	dateFlag(fs, &h.Date, "date", "handover date")
+	fs.IntVar(&h.Counter, "counter", 0, "meter counter at the handover")
+	fs.Parse(args)
+
+	if h.Seller == "" || h.Buyer == "" {
+		return fmt.Errorf("seller and buyer are required")
+	}
+	if h.Date.IsZero() {
+		return fmt.Errorf("handover date is required")
+	}
+
+	csvf, file, err := readData(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := updater.New(scraper.New(cfg.Portal), opts).Handover(csvf, h); err != nil {
+		return err
+	}
+
+	return writeData(file, csvf)
+}
+
+// runExchange replaces the meter of a member.
+func runExchange(cfg config.Config, args []string) error {
+	var (
+		opts updater.Options
+		name string
+		ex   meter.Exchange
+	)
+	fs := newFlagSet("exchange")
+	updaterFlags(fs, &opts, cfg)
+	fs.StringVar(&name, "member", "", "name of the member whose meter was replaced")
+	dateFlag(fs, &ex.Date, "date", "meter exchange date")
+	fs.IntVar(&ex.FinalCounter, "final", 0, "final counter of the old meter")
+	fs.Func("meter", "number of the new meter", func(s string) error {
+		ex.NewNumber = meter.Number(s)
+		return nil
+	})
+	fs.IntVar(&ex.StartCounter, "start", 0, "start counter of the new meter")
+	fs.Parse(args)
+
+	if name == "" || ex.NewNumber == "" {
+		return fmt.Errorf("member and new meter number are required")
+	}
+	if ex.Date.IsZero() {
+		return fmt.Errorf("exchange date is required")
+	}
+
+	csvf, file, err := readData(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := updater.New(scraper.New(cfg.Portal), opts).ExchangeMeter(csvf, name, ex); err != nil {
+		return err
+	}
+
+	return writeData(file, csvf)
+}
+
+// runCorrect corrects a bill that has already been sent.
+func runCorrect(cfg config.Config, args []string) error {
+	var (
+		opts         updater.Options
+		c            updater.Correction
+		historyCSV   string
+		amount       string
+		invoicesFile string
+		journalFile  string
+		accountsCSV  string
+	)
+	fs := newFlagSet("correct")
+	updaterFlags(fs, &opts, cfg)
+	fs.StringVar(&c.Name, "member", "", "name of the member whose bill is corrected")
+	fs.StringVar(&historyCSV, "history", "", "data CSV file as it was when the corrected bill was sent")
+	fs.Func("counter", "corrected meter counter", func(s string) error {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("negative counter %d", n)
+		}
+		c.Counter = &n
+		return nil
+	})
+	fs.StringVar(&amount, "amount", "", "corrected total of the bill with tax")
+	fs.StringVar(&invoicesFile, "invoices", "", "write the correction bill as text to this file")
+	fs.StringVar(&journalFile, "journal", "", "write journal entries of the correction bill to this file for bookkeeping")
+	fs.StringVar(&accountsCSV, "accounts", "", "account numbers of the journal entries CSV file (entry, account)")
+	fs.Parse(args)
+
+	if c.Name == "" {
+		return fmt.Errorf("member is required")
+	}
+
+	var err error
+	switch {
+	case amount != "" && c.Counter != nil:
+		return fmt.Errorf("either corrected counter or amount is required, not both")
+	case amount != "":
+		c.Amount, err = decimal.NewFromString(strings.Replace(amount, ",", ".", 1))
+		if err != nil {
+			return fmt.Errorf("parse corrected amount: %w", err)
+		}
+	case c.Counter == nil:
+		return fmt.Errorf("corrected counter or amount is required")
+	}
+	c.Date = time.Now()
+
+	history, err := readCSV(historyCSV)
+	if err != nil {
+		return fmt.Errorf("read history csv: %w", err)
+	}
+
+	csvf, file, err := readData(cfg)
+	if err != nil {
+		return err
+	}
+
+	b, err := updater.New(scraper.New(cfg.Portal), opts).Correct(history, csvf, c)
+	if err != nil {
+		return err
+	}
+
+	// The correction is due counting from its own date
+	due, err := dueDateFrom(csvf, c.Date)
+	if err != nil {
+		return err
+	}
+
+	if err := writeBillOutputs(cfg, csvf, []updater.Bill{b}, due, invoicesFile, journalFile, accountsCSV); err != nil {
+		return err
+	}
+
+	return writeData(file, csvf)
+}
+
+// writeBillOutputs writes the invoices and the journal entries of the
+// bills of a billing run to the files that are set.
+func writeBillOutputs(cfg config.Config, csvf *csv.CSVFile, bills []updater.Bill, due time.Time, invoicesFile, journalFile, accountsCSV string) error {
+	if invoicesFile != "" {
+		if err := writeInvoices(invoicesFile, seller(cfg), bills, due); err != nil {
+			return fmt.Errorf("write invoices: %w", err)
+		}
+	}
+
+	if journalFile != "" {
+		a, err := accounts(cfg, accountsCSV)
+		if err != nil {
+			return err
+		}
+		if err := writeJournal(journalFile, a, bills, csvf); err != nil {
+			return fmt.Errorf("write journal: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func additionalCosts(filename string) ([]updater.AdditionalCost, error) {
+	if filename == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	res, err := csv.ReadAdditionalCosts(file)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", filename, err)
+	}
+
+	return res, nil
+}
+
+func waterPrices(filename string) ([]updater.PriceComponent, error) {
+	if filename == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	res, err := csv.ReadPrices(file)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", filename, err)
+	}
+
+	return res, nil
+}
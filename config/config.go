@@ -0,0 +1,317 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jarnoan/vesimittari/journal"
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+// Config contains the settings of the cooperative. The billing settings
+// override the corresponding rows of the data file when set.
+type Config struct {
+	Data            string            `yaml:"data"`     // data file, stdin and stdout if empty
+	Database        string            `yaml:"database"` // SQLite database file
+	Seller          Seller            `yaml:"seller"`
+	Portal          string            `yaml:"portal"` // login page of the meter reading portal
+	Billing         Billing           `yaml:"billing"`
+	Prices          []Price           `yaml:"prices"`
+	AdditionalCosts []AdditionalCost  `yaml:"additional_costs"`
+	Accounts        map[string]string `yaml:"accounts"` // journal entry name to account number
+	SMTP            SMTP              `yaml:"smtp"`
+	Server          Server            `yaml:"server"`
+}
+
+// Seller is the cooperative that sends the bills.
+type Seller struct {
+	Name       string `yaml:"name"`
+	Address    string `yaml:"address"`
+	BusinessID string `yaml:"business_id"` // Y-tunnus
+	IBAN       string `yaml:"iban"`
+	Email      string `yaml:"email"`
+}
+
+// Billing contains the parameters of the billing.
+type Billing struct {
+	VAT          *decimal.Decimal `yaml:"vat"`            // % replacing the general rate of the data
+	MainMeterFee *decimal.Decimal `yaml:"main_meter_fee"` // € per month without tax
+	Months       string           `yaml:"months"`         // month convention
+	Digits       int              `yaml:"digits"`         // digits in meter counters
+}
+
+// Price is a water price component with its tiers.
+type Price struct {
+	Label string          `yaml:"label"`
+	Price decimal.Decimal `yaml:"price"` // €/m³ without tax
+	VAT   decimal.Decimal `yaml:"vat"`   // %
+	Tiers []struct {
+		Above decimal.Decimal `yaml:"above"` // m³ per year
+		Price decimal.Decimal `yaml:"price"` // €/m³ without tax
+	} `yaml:"tiers"`
+}
+
+// AdditionalCost is a cost billed in addition to the water and basic fees.
+type AdditionalCost struct {
+	Description string          `yaml:"description"`
+	Cost        decimal.Decimal `yaml:"cost"` // € without tax
+	VAT         decimal.Decimal `yaml:"vat"`  // %
+	Target      string          `yaml:"target"`
+	Rule        string          `yaml:"rule"` // days, equal or each
+}
+
+// SMTP contains the settings of the mail server.
+type SMTP struct {
+	Addr     string `yaml:"addr"` // host:port
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// Server contains the settings of the API server.
+type Server struct {
+	Addr  string `yaml:"addr"`  // host:port to listen
+	Token string `yaml:"token"` // required from the clients
+}
+
+// Read reads the configuration from YAML.
+func Read(r io.Reader) (Config, error) {
+	var res Config
+
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&res); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("parse config: %w", err)
+	}
+
+	return res, nil
+}
+
+// Load reads the configuration from the file, applies the overrides of the
+// environment variables and validates the result. A missing file is an
+// error only if required is set.
+func Load(name string, required bool) (Config, error) {
+	var res Config
+
+	file, err := os.Open(name)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && !required:
+	case err != nil:
+		return Config{}, fmt.Errorf("open config: %w", err)
+	default:
+		defer file.Close()
+		res, err = Read(file)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	if err := res.ApplyEnv(os.LookupEnv); err != nil {
+		return Config{}, err
+	}
+
+	if err := res.Validate(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return res, nil
+}
+
+// ApplyEnv overrides the settings with the environment variables that are
+// set, like VESIMITTARI_PORTAL and VESIMITTARI_SMTP_PASSWORD.
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) error {
+	strs := map[string]*string{
+		"VESIMITTARI_DATA":               &c.Data,
+		"VESIMITTARI_DATABASE":           &c.Database,
+		"VESIMITTARI_SELLER_NAME":        &c.Seller.Name,
+		"VESIMITTARI_SELLER_ADDRESS":     &c.Seller.Address,
+		"VESIMITTARI_SELLER_BUSINESS_ID": &c.Seller.BusinessID,
+		"VESIMITTARI_SELLER_IBAN":        &c.Seller.IBAN,
+		"VESIMITTARI_SELLER_EMAIL":       &c.Seller.Email,
+		"VESIMITTARI_PORTAL":             &c.Portal,
+		"VESIMITTARI_MONTHS":             &c.Billing.Months,
+		"VESIMITTARI_SMTP_ADDR":          &c.SMTP.Addr,
+		"VESIMITTARI_SMTP_USERNAME":      &c.SMTP.Username,
+		"VESIMITTARI_SMTP_PASSWORD":      &c.SMTP.Password,
+		"VESIMITTARI_SMTP_FROM":          &c.SMTP.From,
+		"VESIMITTARI_SERVER_ADDR":        &c.Server.Addr,
+		"VESIMITTARI_SERVER_TOKEN":       &c.Server.Token,
+	}
+	for name, dst := range strs {
+		if v, ok := lookup(name); ok {
+			*dst = v
+		}
+	}
+
+	decs := map[string]**decimal.Decimal{
+		"VESIMITTARI_VAT":            &c.Billing.VAT,
+		"VESIMITTARI_MAIN_METER_FEE": &c.Billing.MainMeterFee,
+	}
+	for name, dst := range decs {
+		if v, ok := lookup(name); ok {
+			d, err := decimal.NewFromString(strings.Replace(v, ",", ".", 1))
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*dst = &d
+		}
+	}
+
+	if v, ok := lookup("VESIMITTARI_DIGITS"); ok {
+		d, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("VESIMITTARI_DIGITS: %w", err)
+		}
+		c.Billing.Digits = d
+	}
+
+	return nil
+}
+
+// Validate checks the settings and returns an error listing all the
+// problems found.
+func (c Config) Validate() error {
+	var errs []string
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	if c.Portal != "" {
+		if u, err := url.Parse(c.Portal); err != nil || u.Scheme == "" || u.Host == "" {
+			add("portal: %q is not an absolute URL", c.Portal)
+		}
+	}
+
+	hundred := decimal.NewFromInt(100)
+	percent := func(field string, d decimal.Decimal) {
+		if d.IsNegative() || d.GreaterThan(hundred) {
+			add("%s: %s %% is not between 0 and 100", field, d)
+		}
+	}
+
+	if c.Billing.VAT != nil {
+		percent("billing.vat", *c.Billing.VAT)
+	}
+	if c.Billing.MainMeterFee != nil && c.Billing.MainMeterFee.IsNegative() {
+		add("billing.main_meter_fee: %s is negative", c.Billing.MainMeterFee)
+	}
+	if c.Billing.Months != "" {
+		var conv period.Convention
+		if err := conv.Set(c.Billing.Months); err != nil {
+			add("billing.months: %s", err)
+		}
+	}
+	if c.Billing.Digits < 0 {
+		add("billing.digits: %d is negative", c.Billing.Digits)
+	}
+
+	labels := make(map[string]bool)
+	for i, p := range c.Prices {
+		field := fmt.Sprintf("prices[%d]", i)
+		if p.Label == "" {
+			add("%s.label: missing", field)
+		} else if labels[p.Label] {
+			add("%s.label: duplicate label %q", field, p.Label)
+		}
+		labels[p.Label] = true
+
+		if p.Price.IsNegative() {
+			add("%s.price: %s is negative", field, p.Price)
+		}
+		percent(field+".vat", p.VAT)
+
+		above := decimal.Zero
+		for j, t := range p.Tiers {
+			if !t.Above.GreaterThan(above) {
+				add("%s.tiers[%d].above: %s is not greater than %s", field, j, t.Above, above)
+			}
+			above = t.Above
+		}
+	}
+
+	for i, ac := range c.AdditionalCosts {
+		field := fmt.Sprintf("additional_costs[%d]", i)
+		if ac.Description == "" {
+			add("%s.description: missing", field)
+		}
+		percent(field+".vat", ac.VAT)
+
+		var rule updater.AllocationRule
+		if err := rule.Set(ac.Rule); err != nil {
+			add("%s.rule: %s", field, err)
+		}
+	}
+
+	var accounts journal.Accounts
+	for name, account := range c.Accounts {
+		if err := accounts.Set(name, account); err != nil {
+			add("accounts: %s", err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config:\n  %s", strings.Join(errs, "\n  "))
+	}
+
+	return nil
+}
+
+// MonthConvention returns the month convention of the billing.
+func (c Config) MonthConvention() period.Convention {
+	var res period.Convention
+	if c.Billing.Months != "" {
+		_ = res.Set(c.Billing.Months) // validated
+	}
+
+	return res
+}
+
+// WaterPrices returns the water price components, none if not set.
+func (c Config) WaterPrices() []updater.PriceComponent {
+	var res []updater.PriceComponent
+	for _, p := range c.Prices {
+		pc := updater.PriceComponent{Label: p.Label, VAT: p.VAT, Price: p.Price}
+		for _, t := range p.Tiers {
+			pc.Tiers = append(pc.Tiers, updater.PriceTier{Above: t.Above, Price: t.Price})
+		}
+		res = append(res, pc)
+	}
+
+	return res
+}
+
+// AdditionalCostList returns the additional costs to bill.
+func (c Config) AdditionalCostList() []updater.AdditionalCost {
+	var res []updater.AdditionalCost
+	for _, ac := range c.AdditionalCosts {
+		var rule updater.AllocationRule
+		_ = rule.Set(ac.Rule) // validated
+		res = append(res, updater.AdditionalCost{
+			Description: ac.Description,
+			VAT:         ac.VAT,
+			Cost:        ac.Cost,
+			Target:      ac.Target,
+			Rule:        rule,
+		})
+	}
+
+	return res
+}
+
+// JournalAccounts returns the default accounts with the configured ones.
+func (c Config) JournalAccounts() journal.Accounts {
+	res := journal.DefaultAccounts
+	for name, account := range c.Accounts {
+		_ = res.Set(name, account) // validated
+	}
+
+	return res
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+const testConfig = `
+seller:
+  name: Vesiosuuskunta
+  iban: FI21 1234 5600 0007 85
+portal: https://portal.example.com/vesi/
+billing:
+  vat: 25.5
+  months: actual/360
+prices:
+  - label: Vesi
+    price: 1.5
+    vat: 25.5
+    tiers:
+      - above: 200
+        price: 2
+additional_costs:
+  - description: Vakuutus
+    cost: 100
+    rule: equal
+accounts:
+  water: "3001"
+`
+
+func TestRead(t *testing.T) {
+	c, err := Read(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Seller.Name != "Vesiosuuskunta" || c.Billing.VAT == nil || c.Billing.VAT.String() != "25.5" {
+		t.Errorf("unexpected config %+v", c)
+	}
+	if c.MonthConvention() != period.Actual360 {
+		t.Errorf("month convention %s, want actual/360", c.MonthConvention())
+	}
+
+	wps := c.WaterPrices()
+	if len(wps) != 1 || len(wps[0].Tiers) != 1 || !wps[0].Tiers[0].Price.Equal(decimal.NewFromInt(2)) {
+		t.Errorf("unexpected water prices %+v", wps)
+	}
+	acs := c.AdditionalCostList()
+	if len(acs) != 1 || acs[0].Rule != updater.Equally {
+		t.Errorf("unexpected additional costs %+v", acs)
+	}
+	if a := c.JournalAccounts(); a.WaterSales != "3001" || a.Receivables != "1700" {
+		t.Errorf("unexpected accounts %+v", a)
+	}
+
+	env := map[string]string{"VESIMITTARI_PORTAL": "https://other.example.com/", "VESIMITTARI_VAT": "24"}
+	if err := c.ApplyEnv(func(k string) (string, bool) { v, ok := env[k]; return v, ok }); err != nil {
+		t.Fatal(err)
+	}
+	if c.Portal != "https://other.example.com/" || c.Billing.VAT.String() != "24" {
+		t.Errorf("environment not applied: %+v", c)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c, err := Read(strings.NewReader(`
+portal: not a url
+billing:
+  vat: 124
+prices:
+  - label: Vesi
+    price: -1
+    tiers:
+      - above: 0
+additional_costs:
+  - cost: 10
+    rule: sometimes
+accounts:
+  cash: "1900"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Validate()
+	if err == nil {
+		t.Fatal("no error")
+	}
+	for _, want := range []string{"portal", "billing.vat", "prices[0].price", "prices[0].tiers[0].above",
+		"additional_costs[0].description", "additional_costs[0].rule", `unknown account "cash"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%s", want, err)
+		}
+	}
+}
+
+func TestRead_unknownField(t *testing.T) {
+	if _, err := Read(strings.NewReader("seler:\n  name: x\n")); err == nil {
+		t.Error("no error for a misspelt field")
+	}
+}
@@ -0,0 +1,72 @@
+package contact
+
+import "testing"
+
+func TestNormalizeIBAN(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"FI21 1234 5600 0007 85", "FI21 1234 5600 0007 85", true},
+		{"fi2112345600000785", "FI21 1234 5600 0007 85", true},
+		{"DE89 3704 0044 0532 0130 00", "DE89 3704 0044 0532 0130 00", true},
+		{"FI21 1234 5600 0007 86", "", false},
+		{"FI21 1234 5600 0007 8", "", false},
+		{"FI91 1234 5600 0007 86", "", false}, // wrong account check digit
+		{"123456-785", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := NormalizeIBAN(tt.in)
+			if (err == nil) != tt.ok || got != tt.want {
+				t.Errorf("NormalizeIBAN(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"040 123 4567", "+358401234567", true},
+		{"+358 40 123 4567", "+358401234567", true},
+		{"0046-8-123 456", "+468123456", true},
+		{"(09) 123 456", "+3589123456", true},
+		{"+358 (0)40 123 4567", "+358401234567", true},
+		{"00358 040 123 4567", "+358401234567", true},
+		{"401234567", "", false},
+		{"040 123 456x", "", false},
+		{"+0401234567", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := NormalizePhone(tt.in)
+			if (err == nil) != tt.ok || got != tt.want {
+				t.Errorf("NormalizePhone(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{" aho@example.com ", "aho@example.com", true},
+		{"aho@example", "", false},
+		{"aho.example.com", "", false},
+		{"Aho <aho@example.com>", "", false},
+		{"aho@@example.com", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := NormalizeEmail(tt.in)
+			if (err == nil) != tt.ok || got != tt.want {
+				t.Errorf("NormalizeEmail(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package contact
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// NormalizeEmail checks the syntax of the email address and returns it
+// without surrounding space.
+func NormalizeEmail(s string) (string, error) {
+	addr := strings.TrimSpace(s)
+
+	a, err := mail.ParseAddress(addr)
+	if err != nil || a.Address != addr || a.Name != "" {
+		return "", fmt.Errorf("email address %q is not valid", s)
+	}
+
+	// Addresses of local hosts are valid syntax but cannot be mailed to
+	at := strings.LastIndex(addr, "@")
+	domain := addr[at+1:]
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "", fmt.Errorf("email address %q has an invalid domain", s)
+	}
+
+	return addr, nil
+}
@@ -0,0 +1,110 @@
+package contact
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// bbanLengths are the lengths of the basic bank account numbers of the
+// countries whose format is checked in addition to the check digits.
+var bbanLengths = map[string]int{
+	"FI": 14,
+	"AX": 14,
+	"SE": 20,
+	"EE": 16,
+	"DE": 18,
+}
+
+// NormalizeIBAN checks the international bank account number and returns
+// it in the print format of four character groups, like
+// "FI21 1234 5600 0007 85". The check digits are checked for all
+// countries, the length of the account number for the countries in
+// bbanLengths and the check digit of the account number for Finnish
+// accounts only.
+func NormalizeIBAN(s string) (string, error) {
+	iban := strings.ToUpper(strings.Join(strings.Fields(s), ""))
+	if len(iban) < 15 || len(iban) > 34 {
+		return "", fmt.Errorf("IBAN %q has %d characters", s, len(iban))
+	}
+
+	for i, c := range iban {
+		switch {
+		case i < 2 && c >= 'A' && c <= 'Z':
+		case i >= 2 && i < 4 && c >= '0' && c <= '9':
+		case i >= 4 && (c >= '0' && c <= '9' || c >= 'A' && c <= 'Z'):
+		default:
+			return "", fmt.Errorf("IBAN %q has an invalid character %q", s, c)
+		}
+	}
+
+	country, bban := iban[:2], iban[4:]
+	if n, ok := bbanLengths[country]; ok && len(bban) != n {
+		return "", fmt.Errorf("IBAN %q: %s account number has %d characters, want %d", s, country, len(bban), n)
+	}
+	if country == "FI" || country == "AX" {
+		for _, c := range bban {
+			if c < '0' || c > '9' {
+				return "", fmt.Errorf("IBAN %q: Finnish account number has a letter", s)
+			}
+		}
+	}
+
+	if !ibanChecksumOK(iban) {
+		return "", fmt.Errorf("IBAN %q has wrong check digits", s)
+	}
+	if (country == "FI" || country == "AX") && !luhnOK(bban) {
+		return "", fmt.Errorf("IBAN %q: Finnish account number has a wrong check digit", s)
+	}
+
+	var groups []string
+	for i := 0; i < len(iban); i += 4 {
+		end := i + 4
+		if end > len(iban) {
+			end = len(iban)
+		}
+		groups = append(groups, iban[i:end])
+	}
+
+	return strings.Join(groups, " "), nil
+}
+
+// luhnOK tells whether the last digit of the number is the Luhn check
+// digit of the others, as in Finnish account numbers: every second digit
+// from the right of the check digit is doubled and the digits summed.
+func luhnOK(num string) bool {
+	sum := 0
+	for i := len(num) - 1; i >= 0; i-- {
+		d := int(num[i] - '0')
+		if (len(num)-1-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+
+	return sum%10 == 0
+}
+
+// ibanChecksumOK tells whether the IBAN is divisible by 97 when the
+// country code and the check digits are moved to the end and the letters
+// are replaced with numbers, A being 10.
+func ibanChecksumOK(iban string) bool {
+	var digits strings.Builder
+	for _, c := range iban[4:] + iban[:4] {
+		if c >= 'A' && c <= 'Z' {
+			fmt.Fprintf(&digits, "%d", c-'A'+10)
+		} else {
+			digits.WriteRune(c)
+		}
+	}
+
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	if !ok {
+		return false
+	}
+
+	return new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
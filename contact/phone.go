@@ -0,0 +1,48 @@
+package contact
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NormalizePhone checks the phone number and returns it in the E.164
+// format, like "+358401234567". A number without a country code is taken
+// to be Finnish, and the trunk prefix 0 of a Finnish number is dropped.
+func NormalizePhone(s string) (string, error) {
+	var digits strings.Builder
+	for i, c := range strings.TrimSpace(s) {
+		switch {
+		case c >= '0' && c <= '9':
+			digits.WriteRune(c)
+		case c == '+' && i == 0:
+			digits.WriteRune(c)
+		case c == ' ' || c == '-' || c == '(' || c == ')':
+		default:
+			return "", fmt.Errorf("phone number %q has an invalid character %q", s, c)
+		}
+	}
+
+	num := digits.String()
+	switch {
+	case strings.HasPrefix(num, "+"):
+	case strings.HasPrefix(num, "00"):
+		num = "+" + num[2:]
+	case strings.HasPrefix(num, "0"):
+		num = "+358" + num[1:]
+	default:
+		return "", fmt.Errorf("phone number %q has no area code or country code", s)
+	}
+
+	// A Finnish number is dialled without the trunk prefix after the country
+	// code, like "+358 (0)40 123 4567"
+	if strings.HasPrefix(num, "+3580") {
+		num = "+358" + num[5:]
+	}
+
+	// E.164 numbers have at most 15 digits and country codes do not start with zero
+	if len(num) < 8 || len(num) > 16 || num[1] == '0' {
+		return "", fmt.Errorf("phone number %q is not a valid international number", s)
+	}
+
+	return num, nil
+}
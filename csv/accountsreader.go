@@ -0,0 +1,40 @@
+package csv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jarnoan/vesimittari/journal"
+)
+
+// ReadAccounts reads the account numbers of the journal export from a CSV
+// file. The expected columns are the name of the entry (receivables, water,
+// basic, additional, other or vat) and the account number. Entries that are
+// not listed keep their default accounts.
+func ReadAccounts(rdr io.Reader) (journal.Accounts, error) {
+	r := csv.NewReader(rdr)
+	r.FieldsPerRecord = 2
+
+	// read header row
+	if _, err := r.Read(); err != nil {
+		return journal.Accounts{}, fmt.Errorf("read header row: %w", err)
+	}
+
+	res := journal.DefaultAccounts
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			// all rows read
+			return res, nil
+		}
+		if err != nil {
+			return journal.Accounts{}, fmt.Errorf("read row: %w", err)
+		}
+
+		if err := res.Set(row[0], row[1]); err != nil {
+			return journal.Accounts{}, err
+		}
+	}
+}
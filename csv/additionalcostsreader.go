@@ -11,9 +11,13 @@ import (
 )
 
 // ReadAdditionalCosts reads the additional costs from a CSV file.
-// The expected columns are description, cost, vat %.
+// The expected columns are description, cost, vat % and optionally
+// target and allocation rule. The target is a member name, site number
+// or one of the groups all, metered and unmetered. The rule is days,
+// equal or each.
 func ReadAdditionalCosts(rdr io.Reader) ([]updater.AdditionalCost, error) {
 	r := csv.NewReader(rdr)
+	r.FieldsPerRecord = -1
 
 	// read header row
 	if _, err := r.Read(); err != nil {
@@ -32,6 +36,10 @@ func ReadAdditionalCosts(rdr io.Reader) ([]updater.AdditionalCost, error) {
 			return nil, fmt.Errorf("read row: %w", err)
 		}
 
+		if len(row) < 3 {
+			return nil, fmt.Errorf("row %v has %d columns, want at least 3", row, len(row))
+		}
+
 		cost, err := decimal.NewFromString(row[1])
 		if err != nil {
 			return nil, fmt.Errorf("read cost column: %w", err)
@@ -47,8 +55,16 @@ func ReadAdditionalCosts(rdr io.Reader) ([]updater.AdditionalCost, error) {
 			Cost:        cost,
 			VAT:         vat,
 		}
+
+		if len(row) > 3 {
+			ac.Target = row[3]
+		}
+
+		if len(row) > 4 {
+			if err := ac.Rule.Set(row[4]); err != nil {
+				return nil, fmt.Errorf("read rule column: %w", err)
+			}
+		}
 		res = append(res, ac)
 	}
-
-	return res, nil
 }
@@ -49,7 +49,7 @@ func Read(rdr io.Reader) (*CSVFile, error) {
 			break
 		}
 
-		mr := MeterRow{rec}
+		mr := MeterRow{rec: rec}
 		res.meterRows = append(res.meterRows, mr)
 	}
 
@@ -79,7 +79,7 @@ func Read(rdr io.Reader) (*CSVFile, error) {
 		return nil, fmt.Errorf("read water fee record: %w", err)
 	}
 
-	// VAT percentage
+	// VAT percentage, optionally followed by the previous percentage and the date it changed
 	res.vatRow, err = r.Read()
 	if err != nil {
 		return nil, fmt.Errorf("read VAT record: %w", err)
@@ -109,20 +109,26 @@ func (f *CSVFile) CommonVariables() (updater.CommonVariables, error) {
 		return updater.CommonVariables{}, fmt.Errorf("parse VAT: %w", err)
 	}
 
-	mainMeterFee, err := decimal.NewFromString(strings.Replace(f.mainMeterFeeRow[1], ",", ".", 1))
-	if err != nil {
-		return updater.CommonVariables{}, fmt.Errorf("parse main meter fee: %w", err)
-	}
+	// An optional previous rate and change date follow the current rate
+	var vatChanges []updater.VATChange
+	if len(f.vatRow) > 3 && f.vatRow[3] != "" {
+		oldVAT, err := decimal.NewFromString(strings.Replace(f.vatRow[2], ",", ".", 1))
+		if err != nil {
+			return updater.CommonVariables{}, fmt.Errorf("parse previous VAT: %w", err)
+		}
 
-	var meteredCount int64
-	for _, mr := range f.meterRows {
-		if mnum, _ := mr.MeterNumber(); mnum != "" {
-			meteredCount++
+		changeDate, err := time.Parse(datefmt, f.vatRow[3])
+		if err != nil {
+			return updater.CommonVariables{}, fmt.Errorf("parse VAT change date: %w", err)
 		}
+
+		vatChanges = append(vatChanges, updater.VATChange{Date: changeDate, Old: oldVAT, New: vat})
 	}
-	meteredCount-- // less the main meter
 
-	monthly := mainMeterFee.Div(decimal.NewFromInt(meteredCount))
+	mainMeterFee, err := decimal.NewFromString(strings.Replace(f.mainMeterFeeRow[1], ",", ".", 1))
+	if err != nil {
+		return updater.CommonVariables{}, fmt.Errorf("parse main meter fee: %w", err)
+	}
 
 	water, err := decimal.NewFromString(strings.Replace(f.waterPriceRow[1], ",", ".", 1))
 	if err != nil {
@@ -130,12 +136,86 @@ func (f *CSVFile) CommonVariables() (updater.CommonVariables, error) {
 	}
 
 	return updater.CommonVariables{
-		VAT:        vat,
-		MonthlyFee: monthly,
-		WaterPrice: water,
+		VAT:          vat,
+		VATChanges:   vatChanges,
+		MainMeterFee: mainMeterFee,
+		WaterPrices:  []updater.PriceComponent{{Label: "Vesimaksu", VAT: vat, Price: water}},
 	}, nil
 }
 
+// widen pads the rows to the width of the widest row, so that a column
+// added to some rows, like the exchange column, is in all of them. The
+// names of the optional columns are added to the header.
+func (f *CSVFile) widen() {
+	rows := []*[]string{&f.headerRow, &f.separatorRow, &f.dateRow, &f.paymentTimeRow, &f.mainMeterFeeRow, &f.waterPriceRow, &f.vatRow, &f.messageRow}
+	for i := range f.meterRows {
+		rows = append(rows, &f.meterRows[i].rec)
+	}
+
+	width := 0
+	for _, rec := range rows {
+		if len(*rec) > width {
+			width = len(*rec)
+		}
+	}
+
+	for n := len(f.headerRow); n < width && n >= colExchange && n-colExchange < len(optionalHeaders); n++ {
+		f.headerRow = append(f.headerRow, optionalHeaders[n-colExchange])
+	}
+	for _, rec := range rows {
+		if len(*rec) < width {
+			*rec = append(*rec, make([]string, width-len(*rec))...)
+		}
+	}
+}
+
+// Transfer ends the membership of the seller at the date and adds a row
+// for the buyer right after it. The buyer row starts from the seller's
+// latest reading and keeps the site, meter and property details.
+func (f *CSVFile) Transfer(seller updater.MeterRecord, buyer string, date time.Time) (updater.MeterRecord, error) {
+	idx, err := f.rowIndex(seller)
+	if err != nil {
+		return nil, err
+	}
+
+	f.meterRows[idx].rec[colLeaveDate] = date.Format(datefmt)
+	f.insertRow(idx+1, f.meterRows[idx].newOwnerRow(buyer, date))
+
+	return &f.meterRows[idx+1], nil
+}
+
+// AddCorrection adds a row for the correction bill right after the row
+// of the member.
+func (f *CSVFile) AddCorrection(mr updater.MeterRecord, b updater.Bill, date time.Time) error {
+	idx, err := f.rowIndex(mr)
+	if err != nil {
+		return err
+	}
+
+	f.insertRow(idx+1, f.meterRows[idx].correctionRow(b, date))
+
+	return nil
+}
+
+// rowIndex returns the index of the row of the record.
+func (f *CSVFile) rowIndex(mr updater.MeterRecord) (int, error) {
+	for i := range f.meterRows {
+		if updater.MeterRecord(&f.meterRows[i]) == mr {
+			return i, nil
+		}
+	}
+
+	return -1, fmt.Errorf("row of %s not found", mr.Name())
+}
+
+// insertRow inserts the row at the index. Pointers to the rows after the
+// index are invalidated.
+func (f *CSVFile) insertRow(idx int, r MeterRow) {
+	f.meterRows = append(f.meterRows, MeterRow{})
+	copy(f.meterRows[idx+1:], f.meterRows[idx:])
+	f.meterRows[idx] = r
+}
+
 func (f *CSVFile) AdditionalCosts() ([]updater.AdditionalCost, error) {
 	return nil, nil
 }
@@ -165,12 +245,20 @@ func (f *CSVFile) Message() (string, error) {
 	return f.messageRow[1], nil
 }
 
+// SetDate sets the billing date. It is also the date of the bills
+// calculated during this run.
 func (f *CSVFile) SetDate(t time.Time) {
 	f.dateRow[1] = t.Format(datefmt)
+	for i := range f.meterRows {
+		if r := &f.meterRows[i]; r.bill.Reference != "" && !r.correction() {
+			r.set(colBillDate, t.Format(datefmt))
+		}
+	}
 }
 
 // Write writes the file to the writer.
 func (f *CSVFile) Write(wtr io.Writer) error {
+	f.widen()
 	w := csv.NewWriter(wtr)
 
 	if err := w.Write(f.headerRow); err != nil {
@@ -205,5 +293,10 @@ func (f *CSVFile) Write(wtr io.Writer) error {
 		return fmt.Errorf("write message row: %w", err)
 	}
 
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("write data: %w", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,145 @@
+package csv
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarnoan/vesimittari/updater"
+)
+
+func TestCSVFile_Transfer(t *testing.T) {
+	seller := testMeterRow()
+	seller.rec[colName] = "Seller"
+	seller.rec[colEmail] = "seller@example.com"
+	seller.rec[colDate] = "1.3.2022"
+	other := testMeterRow()
+	other.rec[colName] = "Other"
+	f := &CSVFile{meterRows: []MeterRow{seller, other}}
+
+	date := time.Date(2022, 3, 15, 0, 0, 0, 0, time.UTC)
+	buyer, err := f.Transfer(&f.meterRows[0], "Buyer", date)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.meterRows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(f.meterRows))
+	}
+	if got := f.meterRows[0].rec[colLeaveDate]; got != "15.3.2022" {
+		t.Errorf("seller leave date = %s, want 15.3.2022", got)
+	}
+	br := f.meterRows[1]
+	if buyer.Name() != "Buyer" || br.rec[colName] != "Buyer" {
+		t.Errorf("row after seller is %s, want Buyer", br.rec[colName])
+	}
+	if br.rec[colJoinDate] != "15.3.2022" || br.rec[colCounter] != "110" || br.rec[colMeter] != "5678" {
+		t.Errorf("buyer row = %v", br.rec)
+	}
+	if br.rec[colEmail] != "" || br.rec[colConsumption] != "" {
+		t.Errorf("buyer row has seller details: %v", br.rec)
+	}
+	if f.meterRows[2].rec[colName] != "Other" {
+		t.Errorf("last row is %s, want Other", f.meterRows[2].rec[colName])
+	}
+}
+
+func TestCSVFile_SetDate_billDates(t *testing.T) {
+	billed := testMeterRow()
+	billed.rec[colConsumption] = ""
+	stale := testMeterRow()
+	stale.rec[colName] = "Stale"
+	f := &CSVFile{meterRows: []MeterRow{billed, stale}, dateRow: []string{"Päivä", "1.1.2022"}}
+
+	if err := f.meterRows[0].UpdateBilling("1232", updater.CommonVariables{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	date := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	f.SetDate(date)
+	if err := f.AddCorrection(&f.meterRows[1], updater.Bill{Reference: "1245"}, time.Date(2022, 7, 5, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []time.Time{date, {}, time.Date(2022, 7, 5, 0, 0, 0, 0, time.UTC)} {
+		got, err := f.meterRows[i].BillDate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("bill date of row %d = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestCSVFile_Validate(t *testing.T) {
+	data := `Nimi,Tili,Puhelin,Sähköposti,Osoite,Postinumero,Kunta,Kiinteistötunnus,Asukkaita,Vakituinen,Liittynyt,Eronnut,Paikka,Mittari,Edellinen,Edellinen pvm,Lukema,Pvm,Tarkistus,Kulutus,a,b,c,d,e,f,g,h,i,Yhteensä,Viite
+Aho,FI21 1234 5600 0007 86,040 123 456x,aho@example,,,,91-404-1-123,,,,,1,M1,100,1.1.2022,150,1.7.2022,,50,,,,,,,,,,,1232
+Bäck,fi2112345600000785, 040 123 4567,,,,,09140400010123,,,,,,M1,1O,1.1.2022,30,31.6.2022,,20,,,,,,,,,,"1,x",1232
+,,,,,,,999-1-1-1,,,1.4.2022,1.3.2022,3,M3,10,1.4.2022,30,1.3.2022,,20,,,,,,,,,,,1233
+Cedergren,,,,,,,,,,,,4,M4,100,1.1.2022,90,1.7.2022,,-10,,,,,,,,,,,
+Cedergren,,,,,,,,,,1.8.2022,1.8.2022,,,,,,,,-10,"-15,00","-3,60","-18,60","0,00","0,00","0,00","0,00",,"0,00","-18,60",1245
+Dahl,,,,,,,91-404-1-5,,,,1.3.2022,5,M5,10,1.1.2022,20,1.3.2022,,10,,,,,,,,,,,
+Eklund,,,,,,,91-404-1-5,,,1.3.2022,,5,M5,,,20,1.3.2022,,,,,,,,,,,,,
+Forsman,,,,,,,91-404-1-5,,,,1.6.2022,6,M5,-5,1.1.2022,10,1.6.2022,,15,,,,,,,,,,,
+###,,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Päivä,1.13.2022,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Maksuaika,14,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Päämittari,"30,00",,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Vesi,x,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+ALV,24,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Viesti,Hei,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+`
+	f, err := Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, p := range f.Validate() {
+		got = append(got, p.String())
+	}
+	want := []string{
+		`row 2, Tili: IBAN "FI21 1234 5600 0007 86" has wrong check digits`,
+		`row 2, Puhelin: phone number "040 123 456x" has an invalid character 'x'`,
+		`row 2, Sähköposti: email address "aho@example" has an invalid domain`,
+		"row 3, Paikka: missing",
+		"row 3, Mittari: meter M1 is also on row 2",
+		`row 3, Edellinen: "1O" is not a whole number`,
+		`row 3, Pvm: "31.6.2022" is not a date like 31.12.2022`,
+		`row 3, Yhteensä: "1,x" is not a number`,
+		"row 3, Viite: reference 1232 is also on row 2",
+		"row 3, Kiinteistötunnus: property 91-404-1-123 is also on row 2",
+		"row 4, Nimi: missing",
+		`row 4, Kiinteistötunnus: property ID "999-1-1-1" has an unknown municipality code 999`,
+		"row 4, Eronnut: 1.3.2022 is before the join date 1.4.2022",
+		"row 4, Pvm: 1.3.2022 is before the previous date 1.4.2022",
+		"row 4, Viite: 1233 is not a valid reference number",
+		"row 5, Kulutus: -10 is negative",
+		"row 9, Mittari: meter M5 is also on row 7",
+		"row 9, Edellinen: -5 is negative",
+		"row 9, Kiinteistötunnus: property 91-404-1-5 is also on row 7",
+		`row 11: billing date: invalid value "1.13.2022": parsing time "1.13.2022": month out of range`,
+		`row 14: water price: invalid value "x": can't convert x to decimal`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got problems\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCSVFile_Normalize(t *testing.T) {
+	r := testMeterRow()
+	r.rec[colBankAccount] = "fi2112345600000785"
+	r.rec[colPhone] = "040-123 4567"
+	r.rec[colEmail] = "invalid"
+	r.rec[colPropertyID] = "09140400010123"
+	f := &CSVFile{meterRows: []MeterRow{r}}
+
+	if n := f.Normalize(); n != 3 {
+		t.Errorf("%d values normalised, want 3", n)
+	}
+	rec := f.meterRows[0].rec
+	if rec[colBankAccount] != "FI21 1234 5600 0007 85" || rec[colPhone] != "+358401234567" || rec[colEmail] != "invalid" ||
+		rec[colPropertyID] != "91-404-1-123" {
+		t.Errorf("normalised = %q, %q, %q, %q", rec[colBankAccount], rec[colPhone], rec[colEmail], rec[colPropertyID])
+	}
+}
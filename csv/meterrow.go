@@ -2,11 +2,15 @@ package csv
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/jarnoan/vesimittari/format"
 	"github.com/jarnoan/vesimittari/meter"
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/jarnoan/vesimittari/property"
 	"github.com/jarnoan/vesimittari/reference"
 	"github.com/jarnoan/vesimittari/updater"
 	"github.com/shopspring/decimal"
@@ -44,10 +48,24 @@ const (
 	colExtraCost
 	colTotal
 	colReference
+	colExchange // optional, added by the first meter exchange
+	colLines    // optional, the water and basic fee lines of the bill
+	colBillDate // optional, the date the bill was issued
 )
 
+// Names of the optional columns.
+const (
+	exchangeHeader = "Mittarinvaihto"
+	linesHeader    = "Laskurivit"
+	billDateHeader = "Laskupäivä"
+)
+
+// optionalHeaders are the names of the optional columns from colExchange on.
+var optionalHeaders = []string{exchangeHeader, linesHeader, billDateHeader}
+
 type MeterRow struct {
-	rec []string // raw csv records
+	rec  []string     // raw csv records
+	bill updater.Bill // bill calculated during this run
 }
 
 func (r *MeterRow) Name() string {
@@ -66,12 +84,66 @@ func (r *MeterRow) Reference() reference.Number {
 	return reference.Number(r.rec[colReference])
 }
 
+// PropertyID returns the property ID of the connection.
+func (r *MeterRow) PropertyID() (property.ID, error) {
+	return property.Parse(r.rec[colPropertyID])
+}
+
+// Email returns the email address of the member.
+func (r *MeterRow) Email() string {
+	return strings.TrimSpace(r.rec[colEmail])
+}
+
+// Membership returns the period from the join date to the leave date.
+// Start or End is zero if the corresponding date is not set.
+func (r *MeterRow) Membership() (period.Period, error) {
+	var res period.Period
+	var err error
+
+	if r.rec[colJoinDate] != "" {
+		res.Start, err = time.Parse(datefmt, r.rec[colJoinDate])
+		if err != nil {
+			return res, fmt.Errorf("parse join date: %w", err)
+		}
+	}
+
+	if r.rec[colLeaveDate] != "" {
+		res.End, err = time.Parse(datefmt, r.rec[colLeaveDate])
+		if err != nil {
+			return res, fmt.Errorf("parse leave date: %w", err)
+		}
+	}
+
+	return res, nil
+}
+
+// AddReading adds the reading as the latest one. A pending meter exchange
+// is included in the consumption and applies to the new reading interval,
+// an exchange of an earlier interval is cleared.
 func (r *MeterRow) AddReading(rdg meter.Reading) error {
 	prevCounter, err := strconv.Atoi(r.rec[colCounter])
 	if err != nil {
 		return fmt.Errorf("parse previous counter: %w", err)
 	}
-	cons := rdg.Counter - prevCounter
+
+	ex, err := r.pendingExchange()
+	if err != nil {
+		return err
+	}
+
+	var cons int
+	if ex != nil {
+		if !rdg.Date.After(ex.Date) {
+			return fmt.Errorf("reading on %s is not after the meter exchange on %s", rdg.Date.Format(datefmt), ex.Date.Format(datefmt))
+		}
+		cons, err = ex.Consumption(prevCounter, rdg.Counter, rdg.Digits)
+	} else {
+		r.set(colExchange, "")
+		cons, err = meter.Consumption(prevCounter, rdg.Counter, rdg.Digits)
+	}
+	if err != nil {
+		return err
+	}
 
 	r.rec[colPrevCounter] = r.rec[colCounter]
 	r.rec[colPrevDate] = r.rec[colDate]
@@ -83,77 +155,655 @@ func (r *MeterRow) AddReading(rdg meter.Reading) error {
 	return nil
 }
 
-var hundred = decimal.NewFromInt(100)
+// Reading returns the latest reading of the meter.
+func (r *MeterRow) Reading() (meter.Reading, error) {
+	counter, err := strconv.Atoi(r.rec[colCounter])
+	if err != nil {
+		return meter.Reading{}, fmt.Errorf("parse counter: %w", err)
+	}
+
+	date, err := time.Parse(datefmt, r.rec[colDate])
+	if err != nil {
+		return meter.Reading{}, fmt.Errorf("parse meter date: %w", err)
+	}
+
+	return meter.Reading{Counter: counter, Date: date, Customer: r.rec[colCheck]}, nil
+}
+
+// PreviousReading returns the reading before the latest one. The date of
+// the reading is zero if the row has no previous reading.
+func (r *MeterRow) PreviousReading() (meter.Reading, error) {
+	if r.rec[colPrevDate] == "" {
+		return meter.Reading{}, nil
+	}
+
+	counter, err := strconv.Atoi(r.rec[colPrevCounter])
+	if err != nil {
+		return meter.Reading{}, fmt.Errorf("parse previous counter: %w", err)
+	}
+
+	date, err := time.Parse(datefmt, r.rec[colPrevDate])
+	if err != nil {
+		return meter.Reading{}, fmt.Errorf("parse previous date: %w", err)
+	}
+
+	return meter.Reading{Counter: counter, Date: date}, nil
+}
+
+// Consumption returns the consumption between the previous and the latest
+// reading in m³.
+func (r *MeterRow) Consumption() (int, error) {
+	res, err := strconv.Atoi(r.rec[colConsumption])
+	if err != nil {
+		return 0, fmt.Errorf("parse consumption: %w", err)
+	}
+
+	return res, nil
+}
+
+// CorrectReading replaces the counter of the latest reading and
+// recalculates the consumption. The date must match the latest reading.
+func (r *MeterRow) CorrectReading(rdg meter.Reading) error {
+	if rdg.Date.Format(datefmt) != r.rec[colDate] {
+		return fmt.Errorf("latest reading is from %s, not %s", r.rec[colDate], rdg.Date.Format(datefmt))
+	}
+
+	prevCounter, err := strconv.Atoi(r.rec[colPrevCounter])
+	if err != nil {
+		return fmt.Errorf("parse previous counter: %w", err)
+	}
+
+	ex, err := r.appliedExchange()
+	if err != nil {
+		return err
+	}
+
+	var cons int
+	if ex != nil {
+		cons, err = ex.Consumption(prevCounter, rdg.Counter, rdg.Digits)
+	} else {
+		cons, err = meter.Consumption(prevCounter, rdg.Counter, rdg.Digits)
+	}
+	if err != nil {
+		return err
+	}
+
+	r.rec[colCounter] = strconv.Itoa(rdg.Counter)
+	r.rec[colConsumption] = strconv.Itoa(cons)
+
+	return nil
+}
+
+// ExchangeMeter replaces the meter of the row. The exchange is recorded
+// in the exchange column and the latest reading of the old meter is kept,
+// so that the next reading yields the consumption of both meters.
+func (r *MeterRow) ExchangeMeter(ex meter.Exchange) error {
+	counter, err := strconv.Atoi(r.rec[colCounter])
+	if err != nil {
+		return fmt.Errorf("parse counter: %w", err)
+	}
+	date, err := r.latestDate()
+	if err != nil {
+		return err
+	}
+	if ex.Date.Before(date) {
+		return fmt.Errorf("exchange date %s is before the latest reading %s", ex.Date.Format(datefmt), r.rec[colDate])
+	}
+
+	pending, err := r.pendingExchange()
+	if err != nil {
+		return err
+	}
+	if pending != nil {
+		return fmt.Errorf("meter already exchanged on %s and not read since", pending.Date.Format(datefmt))
+	}
+
+	if _, err := meter.Consumption(counter, ex.FinalCounter, ex.Digits); err != nil {
+		return fmt.Errorf("final counter of old meter: %w", err)
+	}
+
+	ex.OldNumber = meter.Number(r.rec[colMeter])
+	r.set(colExchange, exchangeString(ex))
+	r.rec[colMeter] = string(ex.NewNumber)
+
+	return nil
+}
+
+// Exchange returns the latest meter exchange recorded on the row, nil if
+// there is none.
+func (r *MeterRow) Exchange() (*meter.Exchange, error) {
+	s := r.optional(colExchange)
+	if s == "" {
+		return nil, nil
+	}
+
+	ex, err := parseExchange(s)
+	if err != nil {
+		return nil, err
+	}
+	ex.NewNumber = meter.Number(r.rec[colMeter])
+
+	return &ex, nil
+}
+
+// pendingExchange returns the exchange if the new meter has not been read
+// yet, that is, the exchange is not before the latest reading.
+func (r *MeterRow) pendingExchange() (*meter.Exchange, error) {
+	ex, err := r.Exchange()
+	if ex == nil || err != nil {
+		return nil, err
+	}
+
+	latest, err := r.latestDate()
+	if err != nil {
+		return nil, err
+	}
+	if ex.Date.Before(latest) {
+		return nil, nil
+	}
+
+	return ex, nil
+}
+
+// appliedExchange returns the exchange if it took place between the
+// previous and the latest reading.
+func (r *MeterRow) appliedExchange() (*meter.Exchange, error) {
+	ex, err := r.Exchange()
+	if ex == nil || err != nil {
+		return nil, err
+	}
+
+	prev, err := r.PreviousReading()
+	if err != nil {
+		return nil, err
+	}
+	latest, err := r.latestDate()
+	if err != nil {
+		return nil, err
+	}
+	if ex.Date.Before(prev.Date) || !ex.Date.Before(latest) {
+		return nil, nil
+	}
+
+	return ex, nil
+}
+
+// latestDate returns the date of the latest reading, zero if there is none.
+func (r *MeterRow) latestDate() (time.Time, error) {
+	if r.rec[colDate] == "" {
+		return time.Time{}, nil
+	}
+
+	res, err := time.Parse(datefmt, r.rec[colDate])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse meter date: %w", err)
+	}
+
+	return res, nil
+}
+
+// optional returns the value of an optional column, empty if the row does
+// not have the column.
+func (r *MeterRow) optional(col int) string {
+	if len(r.rec) <= col {
+		return ""
+	}
+
+	return r.rec[col]
+}
+
+// set sets the value of an optional column, adding it to the row if needed.
+func (r *MeterRow) set(col int, s string) {
+	if len(r.rec) <= col {
+		if s == "" {
+			return
+		}
+		r.rec = append(r.rec, make([]string, col+1-len(r.rec))...)
+	}
+	r.rec[col] = s
+}
+
+// newOwnerRow returns a row for a new owner of the meter site. Only the
+// columns describing the site and the latest reading are kept.
+func (r *MeterRow) newOwnerRow(name string, joinDate time.Time) MeterRow {
+	rec := make([]string, len(r.rec))
+	for _, col := range []int{colStreetAddress, colPostalCode, colCity, colPropertyID, colSite, colMeter, colCounter, colDate} {
+		rec[col] = r.rec[col]
+	}
+	rec[colName] = name
+	rec[colJoinDate] = joinDate.Format(datefmt)
+
+	return MeterRow{rec: rec}
+}
+
+// correctionRow returns a row for a correction bill of the member. The
+// membership of the row ends when it starts so that it is never billed.
+// The consumption and months are the differences to the original bill, so
+// the consumption of a credit note is negative. The row has no meter or
+// readings, so the consumption is never taken as metered.
+func (r *MeterRow) correctionRow(b updater.Bill, date time.Time) MeterRow {
+	rec := make([]string, len(r.rec))
+	for _, col := range []int{colName, colBankAccount, colPhone, colEmail, colStreetAddress, colPostalCode, colCity, colPropertyID} {
+		rec[col] = r.rec[col]
+	}
+	rec[colJoinDate] = date.Format(datefmt)
+	rec[colLeaveDate] = date.Format(datefmt)
+
+	// The consumption and months are the differences to the original bill
+	var cons, months decimal.Decimal
+	metered := false
+	for _, l := range b.Lines {
+		switch l.Kind {
+		case updater.WaterFee:
+			cons = cons.Add(l.Quantity)
+			metered = true
+		case updater.BasicFee:
+			months = months.Add(l.Quantity)
+			metered = true
+		}
+	}
+	if metered {
+		rec[colConsumption] = cons.String()
+		rec[colMonths] = format.Amount(months)
+	}
+
+	res := MeterRow{rec: rec, bill: b}
+	res.writeBill(b)
+	res.set(colBillDate, date.Format(datefmt))
+
+	return res
+}
+
+// correction tells whether the row is a correction bill added by
+// correctionRow: a row without a meter whose membership ends when it
+// starts.
+func (r *MeterRow) correction() bool {
+	return r.rec[colMeter] == "" && r.rec[colJoinDate] != "" && r.rec[colJoinDate] == r.rec[colLeaveDate]
+}
+
+var one = decimal.NewFromInt(1)
 
 func (r *MeterRow) UpdateBilling(ref reference.Number, cv updater.CommonVariables, acs []updater.AdditionalCost) error {
-	var total decimal.Decimal
+	var lines []updater.BillLine
 
 	// Basic fee and consumption are billed only from members who have a water meter
 	if r.rec[colConsumption] != "" {
-		months, err := r.months()
+		mp, err := r.meterPeriod()
 		if err != nil {
-			return fmt.Errorf("get months: %w", err)
+			return fmt.Errorf("get meter period: %w", err)
 		}
 
-		basicFeeWithoutTax := cv.MonthlyFee.Mul(decimal.NewFromInt(int64(months)))
-		basicFeeTax := basicFeeWithoutTax.Mul(cv.VAT.Div(hundred))
-		basicFeeWithTax := basicFeeWithoutTax.Add(basicFeeTax)
-		total = total.Add(basicFeeWithTax)
-
-		r.rec[colMonths] = strconv.Itoa(months)
-		r.rec[colBasicFeeWithoutTax] = decimalToString(basicFeeWithoutTax)
-		r.rec[colBasicFeeTax] = decimalToString(basicFeeTax)
-		r.rec[colBasicFeeWithTax] = decimalToString(basicFeeWithTax)
+		membership, err := r.Membership()
+		if err != nil {
+			return err
+		}
 
 		cons, err := strconv.ParseInt(r.rec[colConsumption], 10, 64)
 		if err != nil {
 			return fmt.Errorf("parse consumption: %w", err)
 		}
 
-		waterFeeWithoutTax := decimal.NewFromInt(int64(cons)).Mul(cv.WaterPrice)
-		waterTax := waterFeeWithoutTax.Mul(cv.VAT.Div(hundred))
-		waterFeeWithTax := waterFeeWithoutTax.Add(waterTax)
-		total = total.Add(waterFeeWithTax)
-
-		r.rec[colWaterFeeWithoutTax] = decimalToString(waterFeeWithoutTax)
-		r.rec[colWaterTax] = decimalToString(waterTax)
-		r.rec[colWaterFeeWithTax] = decimalToString(waterFeeWithTax)
+		// The columns hold the sums of the lines
+		var months decimal.Decimal
+		lines, months = updater.MeteredLines(decimal.NewFromInt(cons), mp, membership, cv)
+		r.rec[colMonths] = format.Amount(months)
 	}
 
 	// Additional costs are billed from all members
-	var acsTotal decimal.Decimal
 	for _, ac := range acs {
-		acTax := ac.Cost.Mul(ac.VAT.Div(hundred))
-		acsTotal = acsTotal.Add(ac.Cost).Add(acTax)
+		lines = append(lines, updater.NewBillLine(updater.AdditionalFee, ac.Description, one, "", ac.Cost, ac.VAT))
 	}
-	r.rec[colExtraCost] = decimalToString(acsTotal)
-	total = total.Add(acsTotal)
 
-	r.rec[colTotal] = decimalToString(total)
-	r.rec[colReference] = string(ref)
+	r.bill = updater.Bill{Name: r.Name(), Reference: ref, Lines: lines}
+	r.writeBill(r.bill)
 
 	return nil
 }
 
-func (r *MeterRow) months() (int, error) {
+// writeBill writes the sums of the bill lines of each kind and the
+// reference to the billing columns. The water and basic fee lines are
+// written to the lines column and the additional cost lines to the extra
+// cost description, so that the bill can be read back as it was.
+func (r *MeterRow) writeBill(b updater.Bill) {
+	linesOf := func(kinds ...updater.LineKind) []updater.BillLine {
+		var res []updater.BillLine
+		for _, l := range b.Lines {
+			for _, k := range kinds {
+				if l.Kind == k {
+					res = append(res, l)
+				}
+			}
+		}
+		return res
+	}
+
+	if r.rec[colConsumption] != "" {
+		waterFee := updater.SumLines("", linesOf(updater.WaterFee))
+		r.rec[colWaterFeeWithoutTax] = format.Amount(waterFee.WithoutTax)
+		r.rec[colWaterTax] = format.Amount(waterFee.Tax)
+		r.rec[colWaterFeeWithTax] = format.Amount(waterFee.WithTax)
+
+		basicFee := updater.SumLines("", linesOf(updater.BasicFee))
+		r.rec[colBasicFeeWithoutTax] = format.Amount(basicFee.WithoutTax)
+		r.rec[colBasicFeeTax] = format.Amount(basicFee.Tax)
+		r.rec[colBasicFeeWithTax] = format.Amount(basicFee.WithTax)
+	}
+
+	r.set(colLines, linesDescription(linesOf(updater.WaterFee, updater.BasicFee)))
+
+	extraLines := linesOf(updater.AdditionalFee, updater.OtherFee)
+	r.rec[colExtraDescription] = extraDescription(extraLines)
+	r.rec[colExtraCost] = format.Amount(updater.SumLines("", extraLines).WithTax)
+
+	r.rec[colTotal] = format.Amount(b.Total())
+	r.rec[colReference] = string(b.Reference)
+}
+
+// Bill returns the bill calculated by UpdateBilling during this run.
+// The bill of a row not billed during this run is read from the lines
+// column and the extra cost description. A row billed before the lines
+// were stored gets a single line for the water fee and the basic fee from
+// the sum columns.
+func (r *MeterRow) Bill() (updater.Bill, error) {
+	if r.bill.Reference != "" {
+		return r.bill, nil
+	}
+
+	res := updater.Bill{Name: r.Name(), Reference: r.Reference()}
+	if res.Reference == "" {
+		return res, nil
+	}
+
+	if desc := r.optional(colLines); desc != "" {
+		lines, err := parseLinesDescription(desc)
+		if err != nil {
+			return res, err
+		}
+		res.Lines = append(res.Lines, lines...)
+	} else if r.rec[colConsumption] != "" && r.rec[colWaterFeeWithTax] != "" {
+		water, err := r.columnLine(updater.WaterFee, "Vesimaksu", colConsumption, "m³", colWaterFeeWithoutTax, colWaterTax, colWaterFeeWithTax)
+		if err != nil {
+			return res, fmt.Errorf("water fee: %w", err)
+		}
+
+		basicFee, err := r.columnLine(updater.BasicFee, "Perusmaksu", colMonths, "kk", colBasicFeeWithoutTax, colBasicFeeTax, colBasicFeeWithTax)
+		if err != nil {
+			return res, fmt.Errorf("basic fee: %w", err)
+		}
+
+		res.Lines = append(res.Lines, water, basicFee)
+	}
+
+	extraLines, err := parseExtraDescription(r.rec[colExtraDescription])
+	if err != nil {
+		return res, err
+	}
+	if len(extraLines) == 0 && r.rec[colExtraCost] != "" {
+		// Without a description the tax of the extra cost is not known
+		extraCost, err := stringToDecimal(r.rec[colExtraCost])
+		if err != nil {
+			return res, fmt.Errorf("parse extra cost: %w", err)
+		}
+		if !extraCost.IsZero() {
+			extraLines = append(extraLines, updater.NewBillLine(updater.AdditionalFee, "Lisämaksut", one, "", extraCost, decimal.Zero))
+		}
+	}
+	res.Lines = append(res.Lines, extraLines...)
+
+	return res, nil
+}
+
+// BillDate returns the date the bill of the row was issued, zero if it is
+// not known because the bill was written before the dates were kept.
+func (r *MeterRow) BillDate() (time.Time, error) {
+	s := r.optional(colBillDate)
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	res, err := time.Parse(datefmt, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse bill date: %w", err)
+	}
+
+	return res, nil
+}
+
+// Total returns the billed total from the total column, zero if the row
+// has not been billed.
+func (r *MeterRow) Total() (decimal.Decimal, error) {
+	if r.rec[colTotal] == "" {
+		return decimal.Zero, nil
+	}
+
+	res, err := stringToDecimal(r.rec[colTotal])
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("parse total: %w", err)
+	}
+
+	return res, nil
+}
+
+// columnLine returns a bill line from the quantity and amount columns.
+func (r *MeterRow) columnLine(kind updater.LineKind, desc string, qtyCol int, unit string, withoutTaxCol, taxCol, withTaxCol int) (updater.BillLine, error) {
+	res := updater.BillLine{Kind: kind, Description: desc, Unit: unit}
+
+	for _, c := range []struct {
+		dst *decimal.Decimal
+		col int
+	}{
+		{&res.Quantity, qtyCol},
+		{&res.WithoutTax, withoutTaxCol},
+		{&res.Tax, taxCol},
+		{&res.WithTax, withTaxCol},
+	} {
+		d, err := stringToDecimal(r.rec[c.col])
+		if err != nil {
+			return res, fmt.Errorf("parse column %d: %w", c.col, err)
+		}
+		*c.dst = d
+	}
+
+	if !res.Quantity.IsZero() {
+		res.UnitPrice = res.WithoutTax.DivRound(res.Quantity, 4)
+	}
+	if !res.WithoutTax.IsZero() {
+		res.VAT = res.Tax.Mul(decimal.NewFromInt(100)).DivRound(res.WithoutTax, 1)
+	}
+
+	return res, nil
+}
+
+// meterPeriod returns the period between the previous and the current reading.
+func (r *MeterRow) meterPeriod() (period.Period, error) {
 	prevDate, err := time.Parse(datefmt, r.rec[colPrevDate])
 	if err != nil {
-		return 0, fmt.Errorf("parse previous date: %w", err)
+		return period.Period{}, fmt.Errorf("parse previous date: %w", err)
 	}
 
 	meterDate, err := time.Parse(datefmt, r.rec[colDate])
 	if err != nil {
-		return 0, fmt.Errorf("parse meter date: %w", err)
+		return period.Period{}, fmt.Errorf("parse meter date: %w", err)
+	}
+
+	if meterDate.Before(prevDate) {
+		return period.Period{}, fmt.Errorf("meter date %s is before previous date %s", r.rec[colDate], r.rec[colPrevDate])
+	}
+
+	return period.New(prevDate, meterDate), nil
+}
+
+// extraDescription joins the additional cost lines into a single
+// description, like "Vakuutus 10,00 + ALV 24 % 2,40 = 12,40; ...".
+func extraDescription(lines []updater.BillLine) string {
+	descs := make([]string, len(lines))
+	for i, l := range lines {
+		descs[i] = fmt.Sprintf("%s %s + ALV %s %% %s = %s",
+			strings.ReplaceAll(l.Description, ";", ","),
+			format.Amount(l.WithoutTax),
+			format.Percent(l.VAT),
+			format.Amount(l.Tax),
+			format.Amount(l.WithTax))
+	}
+
+	return strings.Join(descs, "; ")
+}
+
+// lineUnits tells the kind of a line in the lines column by its unit.
+var lineUnits = map[string]updater.LineKind{
+	"m³": updater.WaterFee,
+	"kk": updater.BasicFee,
+}
+
+// linesDescription joins the water and basic fee lines into a single
+// description, like "Vesimaksu 12,00 m³ à 1,50 = 18,00 + ALV 24 % 4,32 =
+// 22,32; ...". Quantities are rounded to two decimals.
+func linesDescription(lines []updater.BillLine) string {
+	descs := make([]string, len(lines))
+	for i, l := range lines {
+		descs[i] = fmt.Sprintf("%s %s %s à %s = %s + ALV %s %% %s = %s",
+			strings.ReplaceAll(l.Description, ";", ","),
+			format.Amount(l.Quantity),
+			l.Unit,
+			priceString(l.UnitPrice),
+			format.Amount(l.WithoutTax),
+			format.Percent(l.VAT),
+			format.Amount(l.Tax),
+			format.Amount(l.WithTax))
 	}
 
-	mos := meterDate.Month() - prevDate.Month()
-	if mos < 0 {
-		mos += 12
+	return strings.Join(descs, "; ")
+}
+
+// priceString formats the price with a decimal comma and at least two
+// decimals. Trailing zeros beyond them are dropped so that a price reads
+// the same whatever its source.
+func priceString(d decimal.Decimal) string {
+	places := int32(2)
+	if s := d.String(); strings.Contains(s, ".") {
+		if n := int32(len(s) - strings.Index(s, ".") - 1); n > places {
+			places = n
+		}
 	}
 
-	return int(mos), nil
+	return format.Fixed(d, places)
 }
 
-func decimalToString(d decimal.Decimal) string {
-	return strings.Replace(d.StringFixed(2), ".", ",", 1)
+var lineRegex = regexp.MustCompile(`^(.*) (-?\d+,\d\d) (\S+) à (-?\d+,\d+) = (-?\d+,\d\d) \+ ALV (-?\d+(?:,\d+)?) % (-?\d+,\d\d) = (-?\d+,\d\d)$`)
+
+// parseLinesDescription parses the lines joined by linesDescription.
+func parseLinesDescription(desc string) ([]updater.BillLine, error) {
+	var res []updater.BillLine
+	for _, d := range strings.Split(desc, "; ") {
+		ms := lineRegex.FindStringSubmatch(d)
+		if ms == nil {
+			return nil, fmt.Errorf("invalid bill line %q", d)
+		}
+		kind, ok := lineUnits[ms[3]]
+		if !ok {
+			return nil, fmt.Errorf("unknown unit %s of bill line %q", ms[3], d)
+		}
+
+		var amounts [6]decimal.Decimal
+		for i, m := range []string{ms[2], ms[4], ms[5], ms[6], ms[7], ms[8]} {
+			a, err := stringToDecimal(m)
+			if err != nil {
+				return nil, fmt.Errorf("parse bill line %q: %w", d, err)
+			}
+			amounts[i] = a
+		}
+
+		res = append(res, updater.BillLine{
+			Kind:        kind,
+			Description: ms[1],
+			Quantity:    amounts[0],
+			Unit:        ms[3],
+			UnitPrice:   amounts[1],
+			WithoutTax:  amounts[2],
+			VAT:         amounts[3],
+			Tax:         amounts[4],
+			WithTax:     amounts[5],
+		})
+	}
+
+	return res, nil
+}
+
+var extraRegex = regexp.MustCompile(`^(.*) (-?\d+,\d\d) \+ ALV (-?\d+(?:,\d+)?) % (-?\d+,\d\d) = (-?\d+,\d\d)$`)
+
+// parseExtraDescription parses the additional cost lines joined by extraDescription.
+func parseExtraDescription(desc string) ([]updater.BillLine, error) {
+	if desc == "" {
+		return nil, nil
+	}
+
+	var res []updater.BillLine
+	for _, d := range strings.Split(desc, "; ") {
+		ms := extraRegex.FindStringSubmatch(d)
+		if ms == nil {
+			return nil, fmt.Errorf("invalid extra cost description %q", d)
+		}
+
+		var amounts [4]decimal.Decimal
+		for i := range amounts {
+			a, err := stringToDecimal(ms[i+2])
+			if err != nil {
+				return nil, fmt.Errorf("parse extra cost %q: %w", d, err)
+			}
+			amounts[i] = a
+		}
+
+		res = append(res, updater.BillLine{
+			Kind:        updater.AdditionalFee,
+			Description: ms[1],
+			Quantity:    one,
+			UnitPrice:   amounts[0],
+			WithoutTax:  amounts[0],
+			VAT:         amounts[1],
+			Tax:         amounts[2],
+			WithTax:     amounts[3],
+		})
+	}
+
+	return res, nil
+}
+
+// exchangeString describes the exchange in the exchange column, like
+// "1.3.2022 M1 loppulukema 130, alkulukema 5". The new meter is in the
+// meter column.
+func exchangeString(ex meter.Exchange) string {
+	return fmt.Sprintf("%s %s loppulukema %d, alkulukema %d", ex.Date.Format(datefmt), ex.OldNumber, ex.FinalCounter, ex.StartCounter)
+}
+
+var exchangeRegex = regexp.MustCompile(`^(\S+) (.*) loppulukema (\d+), alkulukema (\d+)$`)
+
+// parseExchange parses the exchange described by exchangeString.
+func parseExchange(s string) (meter.Exchange, error) {
+	ms := exchangeRegex.FindStringSubmatch(s)
+	if ms == nil {
+		return meter.Exchange{}, fmt.Errorf("invalid meter exchange %q", s)
+	}
+
+	date, err := time.Parse(datefmt, ms[1])
+	if err != nil {
+		return meter.Exchange{}, fmt.Errorf("parse meter exchange date: %w", err)
+	}
+	final, err := strconv.Atoi(ms[3])
+	if err != nil {
+		return meter.Exchange{}, fmt.Errorf("parse final counter: %w", err)
+	}
+	start, err := strconv.Atoi(ms[4])
+	if err != nil {
+		return meter.Exchange{}, fmt.Errorf("parse start counter: %w", err)
+	}
+
+	return meter.Exchange{Date: date, OldNumber: meter.Number(ms[2]), FinalCounter: final, StartCounter: start}, nil
+}
+
+func stringToDecimal(s string) (decimal.Decimal, error) {
+	if s == "" {
+		return decimal.Zero, nil
+	}
+
+	return decimal.NewFromString(strings.Replace(s, ",", ".", 1))
 }
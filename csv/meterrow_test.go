@@ -0,0 +1,233 @@
+package csv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jarnoan/vesimittari/meter"
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+func TestMeterRow_UpdateBilling_basicFee(t *testing.T) {
+	tests := []struct {
+		name             string
+		prevDate, date   string
+		months, basicFee string
+	}{
+		{"month end", "31.1.2022", "1.2.2022", "0,03", "0,33"},
+		{"year boundary", "15.12.2021", "15.1.2022", "1,02", "10,19"},
+		{"long gap", "15.1.2021", "15.2.2022", "13,02", "130,19"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testMeterRow()
+			r.rec[colPrevDate] = tt.prevDate
+			r.rec[colDate] = tt.date
+			cv := updater.CommonVariables{
+				MonthlyFee:      decimal.NewFromInt(10),
+				WaterPrices:     []updater.PriceComponent{{Label: "Vesi", Price: decimal.NewFromInt(2)}},
+				MonthConvention: period.Actual365,
+			}
+
+			if err := r.UpdateBilling("1232", cv, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := r.rec[colMonths]; got != tt.months {
+				t.Errorf("months = %s, want %s", got, tt.months)
+			}
+			if got := r.rec[colBasicFeeWithoutTax]; got != tt.basicFee {
+				t.Errorf("basic fee = %s, want %s", got, tt.basicFee)
+			}
+		})
+	}
+}
+
+func TestMeterRow_UpdateBilling_dateOrder(t *testing.T) {
+	r := testMeterRow()
+	r.rec[colPrevDate] = "1.2.2022"
+	r.rec[colDate] = "1.1.2022"
+
+	if err := r.UpdateBilling("1232", updater.CommonVariables{}, nil); err == nil {
+		t.Error("UpdateBilling succeeded, want error")
+	}
+}
+
+func testMeterRow() MeterRow {
+	rec := make([]string, colReference+1)
+	rec[colName] = "Virtanen"
+	rec[colSite] = "1234"
+	rec[colMeter] = "5678"
+	rec[colPrevCounter] = "100"
+	rec[colCounter] = "110"
+	rec[colConsumption] = "10"
+	return MeterRow{rec: rec}
+}
+
+func TestMeterRow_ExchangeMeter(t *testing.T) {
+	r := testMeterRow()
+	r.rec[colMeter] = "1234"
+	r.rec[colDate] = "1.1.2022"
+	ex := meter.Exchange{
+		Date:         time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC),
+		FinalCounter: 130,
+		NewNumber:    "9999",
+		StartCounter: 5,
+	}
+	if err := r.ExchangeMeter(ex); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.rec[colCounter]; got != "110" {
+		t.Errorf("counter = %s after the exchange, want the reading of the old meter 110", got)
+	}
+	if got, want := r.rec[colExchange], "1.3.2022 1234 loppulukema 130, alkulukema 5"; got != want {
+		t.Errorf("exchange = %q, want %q", got, want)
+	}
+	if err := r.ExchangeMeter(ex); err == nil {
+		t.Error("second exchange before reading the new meter succeeded")
+	}
+
+	rdg := meter.Reading{Counter: 25, Date: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)}
+	if err := r.AddReading(rdg); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := r.rec[colMeter]; got != "9999" {
+		t.Errorf("meter = %s, want 9999", got)
+	}
+	if got := r.rec[colConsumption]; got != "40" {
+		t.Errorf("consumption = %s, want 40", got)
+	}
+	if got := r.rec[colPrevDate]; got != "1.1.2022" {
+		t.Errorf("previous date = %s, want 1.1.2022", got)
+	}
+
+	// The exchange applies to the latest interval until the next reading
+	if err := r.CorrectReading(meter.Reading{Counter: 35, Date: rdg.Date}); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.rec[colConsumption]; got != "50" {
+		t.Errorf("corrected consumption = %s, want 50", got)
+	}
+	if err := r.AddReading(meter.Reading{Counter: 40, Date: time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.rec[colConsumption]; got != "5" {
+		t.Errorf("consumption after the exchange = %s, want 5", got)
+	}
+	if got := r.rec[colExchange]; got != "" {
+		t.Errorf("exchange = %q after the next interval, want empty", got)
+	}
+}
+
+func TestMeterRow_ExchangeMeter_wrapAround(t *testing.T) {
+	r := testMeterRow()
+	r.rec[colCounter] = "99990"
+	r.rec[colDate] = "1.1.2022"
+	ex := meter.Exchange{
+		Date:         time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC),
+		FinalCounter: 5,
+		NewNumber:    "9999",
+		StartCounter: 99998,
+		Digits:       5,
+	}
+	if err := r.ExchangeMeter(ex); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddReading(meter.Reading{Counter: 3, Date: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC), Digits: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.rec[colConsumption]; got != "20" {
+		t.Errorf("consumption = %s, want 20", got)
+	}
+
+	ex.Digits = 0
+	r = testMeterRow()
+	r.rec[colCounter] = "99990"
+	if err := r.ExchangeMeter(ex); err == nil {
+		t.Error("exchange with a decreased final counter and unknown digits succeeded")
+	}
+}
+
+func TestMeterRow_AddReading_decrease(t *testing.T) {
+	r := testMeterRow()
+	if err := r.AddReading(meter.Reading{Counter: 90}); err == nil {
+		t.Error("AddReading succeeded with a decreased counter, want error")
+	}
+}
+
+func TestMeterRow_UpdateBilling_additionalCosts(t *testing.T) {
+	r := testMeterRow()
+	r.rec[colConsumption] = ""
+	acs := []updater.AdditionalCost{
+		{Description: "Vakuutus", Cost: decimal.RequireFromString("33.34"), VAT: decimal.Zero},
+		{Description: "Huolto; syksy", Cost: decimal.NewFromInt(10), VAT: decimal.RequireFromString("25.5")},
+	}
+
+	if err := r.UpdateBilling("1232", updater.CommonVariables{}, acs); err != nil {
+		t.Fatal(err)
+	}
+
+	wantDesc := "Vakuutus 33,34 + ALV 0 % 0,00 = 33,34; Huolto, syksy 10,00 + ALV 25,5 % 2,55 = 12,55"
+	if got := r.rec[colExtraDescription]; got != wantDesc {
+		t.Errorf("description = %q, want %q", got, wantDesc)
+	}
+	if got := r.rec[colExtraCost]; got != "45,89" {
+		t.Errorf("extra cost = %s, want 45,89", got)
+	}
+	b, err := r.Bill()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Lines) != 2 || b.Lines[1].Description != "Huolto; syksy" {
+		t.Errorf("bill lines = %+v", b.Lines)
+	}
+}
+
+func TestMeterRow_Bill_vatChange(t *testing.T) {
+	r := testMeterRow()
+	r.rec[colPrevDate] = "1.7.2024"
+	r.rec[colDate] = "1.10.2024"
+	r.rec[colConsumption] = "92"
+	vat := decimal.RequireFromString("25.5")
+	cv := updater.CommonVariables{
+		VAT:        vat,
+		VATChanges: []updater.VATChange{{Date: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC), Old: decimal.NewFromInt(24), New: vat}},
+		MonthlyFee: decimal.NewFromInt(10),
+		WaterPrices: []updater.PriceComponent{
+			{Label: "Vesi", VAT: vat, Price: decimal.RequireFromString("1.525")},
+			{Label: "Jätevesi", VAT: vat, Price: decimal.RequireFromString("2.1")},
+		},
+		MonthConvention: period.Actual365,
+	}
+	if err := r.UpdateBilling("1232", cv, nil); err != nil {
+		t.Fatal(err)
+	}
+	want, err := r.Bill()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A row read from the file has only the columns
+	reread := MeterRow{rec: r.rec}
+	got, err := reread.Bill()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.Lines) != 6 || len(got.Lines) != len(want.Lines) {
+		t.Fatalf("got %d lines, want 6: %+v", len(got.Lines), got.Lines)
+	}
+	for i, l := range got.Lines {
+		w := want.Lines[i]
+		if l.Kind != w.Kind || l.Description != w.Description || l.Unit != w.Unit ||
+			!l.Quantity.Equal(w.Quantity.Round(2)) || !l.UnitPrice.Equal(w.UnitPrice) || !l.VAT.Equal(w.VAT) ||
+			!l.WithoutTax.Equal(w.WithoutTax) || !l.Tax.Equal(w.Tax) || !l.WithTax.Equal(w.WithTax) {
+			t.Errorf("line %d = %+v, want %+v", i, l, w)
+		}
+	}
+	if !got.Total().Equal(want.Total()) {
+		t.Errorf("total = %s, want %s", got.Total(), want.Total())
+	}
+}
@@ -0,0 +1,419 @@
+package csv
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jarnoan/vesimittari/format"
+	"github.com/jarnoan/vesimittari/meter"
+	"github.com/jarnoan/vesimittari/model"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+// defaultHeader is the header row of a file converted from a dataset
+// without one.
+var defaultHeader = []string{
+	"Nimi", "Tilinumero", "Puhelin", "Sähköposti", "Katuosoite", "Postinumero", "Postitoimipaikka",
+	"Kiinteistötunnus", "Asukkaita", "Vakituinen asunto", "Liittynyt", "Eronnut", "Mittauspaikka",
+	"Mittari", "Edellinen lukema", "Edellinen pvm", "Lukema", "Pvm", "Tarkistus", "Kulutus",
+	"Vesimaksu alv 0", "Vesimaksu alv", "Vesimaksu", "Kk", "Perusmaksu alv 0", "Perusmaksu alv",
+	"Perusmaksu", "Lisämaksujen selite", "Lisämaksut", "Yhteensä", "Viite",
+}
+
+// Dataset returns the data of the file as a dataset.
+func (f *CSVFile) Dataset() (model.Dataset, error) {
+	res := model.Dataset{Header: f.headerRow}
+
+	for i := range f.meterRows {
+		m, err := f.meterRows[i].member()
+		if err != nil {
+			return model.Dataset{}, fmt.Errorf("row %d: %w", i+2, err)
+		}
+		res.Members = append(res.Members, m)
+	}
+
+	var err error
+	p := &res.Parameters
+	if p.Date.Time, err = f.Date(); err != nil {
+		return model.Dataset{}, err
+	}
+	if p.PaymentDays, err = f.PaymentDays(); err != nil {
+		return model.Dataset{}, err
+	}
+	cv, err := f.CommonVariables()
+	if err != nil {
+		return model.Dataset{}, err
+	}
+	p.MainMeterFee = cv.MainMeterFee
+	p.WaterPrice = cv.WaterPrices[0].Price
+	p.VAT = cv.VAT
+	if len(cv.VATChanges) > 0 {
+		p.VATChange = &model.VATChange{Date: model.Date{Time: cv.VATChanges[0].Date}, Previous: cv.VATChanges[0].Old}
+	}
+	if p.Message, err = f.Message(); err != nil {
+		return model.Dataset{}, err
+	}
+
+	return res, nil
+}
+
+// member returns the row as a member of a dataset.
+func (r *MeterRow) member() (model.Member, error) {
+	rec := r.rec
+	if len(rec) <= colReference {
+		return model.Member{}, fmt.Errorf("%d columns, want %d", len(rec), colReference+1)
+	}
+
+	res := model.Member{
+		Name:               rec[colName],
+		BankAccount:        rec[colBankAccount],
+		Phone:              rec[colPhone],
+		Email:              rec[colEmail],
+		StreetAddress:      rec[colStreetAddress],
+		PostalCode:         rec[colPostalCode],
+		City:               rec[colCity],
+		PropertyID:         rec[colPropertyID],
+		Tenants:            rec[colTenants],
+		PermanentResidency: rec[colPermanentResidency],
+	}
+
+	membership, err := r.Membership()
+	if err != nil {
+		return model.Member{}, err
+	}
+	res.JoinDate = model.NewDate(membership.Start)
+	res.LeaveDate = model.NewDate(membership.End)
+
+	if hasAny(rec, colSite, colMeter, colPrevCounter, colPrevDate, colCounter, colDate, colCheck, colConsumption) {
+		m := &model.Meter{Site: rec[colSite], Number: rec[colMeter]}
+		if m.PreviousReading, err = reading(rec, colPrevCounter, colPrevDate, -1); err != nil {
+			return model.Member{}, fmt.Errorf("previous reading: %w", err)
+		}
+		if m.Reading, err = reading(rec, colCounter, colDate, colCheck); err != nil {
+			return model.Member{}, fmt.Errorf("reading: %w", err)
+		}
+		if rec[colConsumption] != "" {
+			cons, err := r.Consumption()
+			if err != nil {
+				return model.Member{}, err
+			}
+			m.Consumption = &cons
+		}
+		ex, err := r.Exchange()
+		if err != nil {
+			return model.Member{}, err
+		}
+		if ex != nil {
+			m.Exchange = &model.Exchange{Date: model.Date{Time: ex.Date}, OldNumber: string(ex.OldNumber), FinalCounter: ex.FinalCounter, StartCounter: ex.StartCounter}
+		}
+		res.Meter = m
+	}
+
+	if hasAny(rec, colMonths, colWaterFeeWithTax, colBasicFeeWithTax, colExtraDescription, colExtraCost, colTotal, colReference) {
+		if res.Bill, err = r.modelBill(); err != nil {
+			return model.Member{}, err
+		}
+	}
+
+	return res, nil
+}
+
+// modelBill returns the billing columns of the row as a bill of a dataset.
+func (r *MeterRow) modelBill() (*model.Bill, error) {
+	rec := r.rec
+	res := &model.Bill{Reference: rec[colReference]}
+
+	var err error
+	if res.Months, err = optionalDecimal(rec[colMonths]); err != nil {
+		return nil, fmt.Errorf("parse months: %w", err)
+	}
+	if res.WaterFee, err = amount(rec, colWaterFeeWithoutTax, colWaterTax, colWaterFeeWithTax); err != nil {
+		return nil, fmt.Errorf("water fee: %w", err)
+	}
+	if res.BasicFee, err = amount(rec, colBasicFeeWithoutTax, colBasicFeeTax, colBasicFeeWithTax); err != nil {
+		return nil, fmt.Errorf("basic fee: %w", err)
+	}
+
+	if desc := r.optional(colLines); desc != "" {
+		lines, err := parseLinesDescription(desc)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range lines {
+			ml := model.Line{
+				Description: l.Description,
+				Quantity:    l.Quantity,
+				Unit:        l.Unit,
+				UnitPrice:   l.UnitPrice,
+				VAT:         l.VAT,
+				Amount:      model.Amount{WithoutTax: l.WithoutTax, Tax: l.Tax, WithTax: l.WithTax},
+			}
+			if l.Kind == updater.BasicFee {
+				res.BasicLines = append(res.BasicLines, ml)
+			} else {
+				res.WaterLines = append(res.WaterLines, ml)
+			}
+		}
+	}
+
+	lines, err := parseExtraDescription(rec[colExtraDescription])
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lines {
+		res.AdditionalCosts = append(res.AdditionalCosts, model.Cost{
+			Description: l.Description,
+			VAT:         l.VAT,
+			Amount:      model.Amount{WithoutTax: l.WithoutTax, Tax: l.Tax, WithTax: l.WithTax},
+		})
+	}
+	if res.AdditionalTotal, err = optionalDecimal(rec[colExtraCost]); err != nil {
+		return nil, fmt.Errorf("parse extra cost: %w", err)
+	}
+	if res.Total, err = optionalDecimal(rec[colTotal]); err != nil {
+		return nil, fmt.Errorf("parse total: %w", err)
+	}
+
+	date, err := r.BillDate()
+	if err != nil {
+		return nil, err
+	}
+	res.Date = model.NewDate(date)
+
+	return res, nil
+}
+
+// FromDataset returns a file with the data of the dataset.
+func FromDataset(d model.Dataset) *CSVFile {
+	header := d.Header
+	if len(header) == 0 {
+		header = defaultHeader
+	}
+	width := len(header)
+	if width <= colReference {
+		width = colReference + 1
+	}
+
+	res := CSVFile{headerRow: header}
+	for _, m := range d.Members {
+		res.meterRows = append(res.meterRows, MeterRow{rec: memberRecord(m, width)})
+	}
+
+	p := d.Parameters
+	param := func(values ...string) []string {
+		rec := make([]string, width)
+		copy(rec, values)
+		return rec
+	}
+	res.separatorRow = param("###")
+	res.dateRow = param("Päivä", p.Date.Format(datefmt))
+	res.paymentTimeRow = param("Maksuaika", strconv.Itoa(p.PaymentDays))
+	res.mainMeterFeeRow = param("Päämittari", priceString(p.MainMeterFee))
+	res.waterPriceRow = param("Vesi", priceString(p.WaterPrice))
+	res.vatRow = param("ALV", format.Percent(p.VAT))
+	if p.VATChange != nil {
+		res.vatRow[2] = format.Percent(p.VATChange.Previous)
+		res.vatRow[3] = p.VATChange.Date.Format(datefmt)
+	}
+	res.messageRow = param("Viesti", p.Message)
+
+	return &res
+}
+
+// memberRecord returns the columns of the row of the member.
+func memberRecord(m model.Member, width int) []string {
+	rec := make([]string, width)
+	rec[colName] = m.Name
+	rec[colBankAccount] = m.BankAccount
+	rec[colPhone] = m.Phone
+	rec[colEmail] = m.Email
+	rec[colStreetAddress] = m.StreetAddress
+	rec[colPostalCode] = m.PostalCode
+	rec[colCity] = m.City
+	rec[colPropertyID] = m.PropertyID
+	rec[colTenants] = m.Tenants
+	rec[colPermanentResidency] = m.PermanentResidency
+	rec[colJoinDate] = dateString(m.JoinDate)
+	rec[colLeaveDate] = dateString(m.LeaveDate)
+
+	if mt := m.Meter; mt != nil {
+		rec[colSite] = mt.Site
+		rec[colMeter] = mt.Number
+		if rdg := mt.PreviousReading; rdg != nil {
+			rec[colPrevCounter] = strconv.Itoa(rdg.Counter)
+			rec[colPrevDate] = dateString(rdg.Date)
+		}
+		if rdg := mt.Reading; rdg != nil {
+			rec[colCounter] = strconv.Itoa(rdg.Counter)
+			rec[colDate] = dateString(rdg.Date)
+			rec[colCheck] = rdg.Check
+		}
+		if mt.Consumption != nil {
+			rec[colConsumption] = strconv.Itoa(*mt.Consumption)
+		}
+		if ex := mt.Exchange; ex != nil {
+			r := MeterRow{rec: rec}
+			r.set(colExchange, exchangeString(meter.Exchange{Date: ex.Date.Time, OldNumber: meter.Number(ex.OldNumber), FinalCounter: ex.FinalCounter, StartCounter: ex.StartCounter}))
+			rec = r.rec
+		}
+	}
+
+	if b := m.Bill; b != nil {
+		rec[colReference] = b.Reference
+		rec[colMonths] = optionalDecimalString(b.Months)
+		for _, a := range []struct {
+			amount               *model.Amount
+			withoutTax, tax, sum int
+		}{
+			{b.WaterFee, colWaterFeeWithoutTax, colWaterTax, colWaterFeeWithTax},
+			{b.BasicFee, colBasicFeeWithoutTax, colBasicFeeTax, colBasicFeeWithTax},
+		} {
+			if a.amount != nil {
+				rec[a.withoutTax] = format.Amount(a.amount.WithoutTax)
+				rec[a.tax] = format.Amount(a.amount.Tax)
+				rec[a.sum] = format.Amount(a.amount.WithTax)
+			}
+		}
+
+		var metered []updater.BillLine
+		for _, ls := range []struct {
+			kind  updater.LineKind
+			lines []model.Line
+		}{
+			{updater.WaterFee, b.WaterLines},
+			{updater.BasicFee, b.BasicLines},
+		} {
+			for _, l := range ls.lines {
+				metered = append(metered, updater.BillLine{
+					Kind:        ls.kind,
+					Description: l.Description,
+					Quantity:    l.Quantity,
+					Unit:        l.Unit,
+					UnitPrice:   l.UnitPrice,
+					VAT:         l.VAT,
+					WithoutTax:  l.WithoutTax,
+					Tax:         l.Tax,
+					WithTax:     l.WithTax,
+				})
+			}
+		}
+
+		var lines []updater.BillLine
+		for _, c := range b.AdditionalCosts {
+			lines = append(lines, updater.BillLine{
+				Kind:        updater.AdditionalFee,
+				Description: c.Description,
+				VAT:         c.VAT,
+				WithoutTax:  c.WithoutTax,
+				Tax:         c.Tax,
+				WithTax:     c.WithTax,
+			})
+		}
+		rec[colExtraDescription] = extraDescription(lines)
+		rec[colExtraCost] = optionalDecimalString(b.AdditionalTotal)
+		rec[colTotal] = optionalDecimalString(b.Total)
+
+		r := MeterRow{rec: rec}
+		r.set(colLines, linesDescription(metered))
+		r.set(colBillDate, dateString(b.Date))
+		rec = r.rec
+	}
+
+	return rec
+}
+
+// reading returns the reading in the columns, nil if they are empty. The
+// check column is not used if negative.
+func reading(rec []string, counterCol, dateCol, checkCol int) (*model.Reading, error) {
+	if !hasAny(rec, counterCol, dateCol) && (checkCol < 0 || rec[checkCol] == "") {
+		return nil, nil
+	}
+
+	var res model.Reading
+	if rec[counterCol] != "" {
+		counter, err := strconv.Atoi(rec[counterCol])
+		if err != nil {
+			return nil, fmt.Errorf("parse counter: %w", err)
+		}
+		res.Counter = counter
+	}
+	if rec[dateCol] != "" {
+		date, err := time.Parse(datefmt, rec[dateCol])
+		if err != nil {
+			return nil, fmt.Errorf("parse date: %w", err)
+		}
+		res.Date = model.NewDate(date)
+	}
+	if checkCol >= 0 {
+		res.Check = rec[checkCol]
+	}
+
+	return &res, nil
+}
+
+// amount returns the amount in the columns, nil if the sum is empty.
+func amount(rec []string, withoutTaxCol, taxCol, withTaxCol int) (*model.Amount, error) {
+	if rec[withTaxCol] == "" {
+		return nil, nil
+	}
+
+	var res model.Amount
+	for _, c := range []struct {
+		dst *decimal.Decimal
+		col int
+	}{
+		{&res.WithoutTax, withoutTaxCol},
+		{&res.Tax, taxCol},
+		{&res.WithTax, withTaxCol},
+	} {
+		d, err := stringToDecimal(rec[c.col])
+		if err != nil {
+			return nil, fmt.Errorf("parse column %d: %w", c.col, err)
+		}
+		*c.dst = d
+	}
+
+	return &res, nil
+}
+
+// hasAny tells whether any of the columns is not empty.
+func hasAny(rec []string, cols ...int) bool {
+	for _, col := range cols {
+		if rec[col] != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func optionalDecimal(s string) (*decimal.Decimal, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	d, err := stringToDecimal(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+func optionalDecimalString(d *decimal.Decimal) string {
+	if d == nil {
+		return ""
+	}
+
+	return format.Amount(*d)
+}
+
+func dateString(d *model.Date) string {
+	if d == nil {
+		return ""
+	}
+
+	return d.Format(datefmt)
+}
@@ -0,0 +1,180 @@
+package csv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarnoan/vesimittari/meter"
+	"github.com/jarnoan/vesimittari/model"
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/shopspring/decimal"
+)
+
+const modelTestData = `Nimi,Tili,Puhelin,Sähköposti,Osoite,Postinumero,Kunta,Kiinteistötunnus,Asukkaita,Vakituinen,Liittynyt,Eronnut,Paikka,Mittari,Edellinen,Edellinen pvm,Lukema,Pvm,Tarkistus,Kulutus,a,b,c,d,e,f,g,h,i,Yhteensä,Viite
+Pää,,,,,,,,,,,,1,M0,900,1.1.2022,1000,1.7.2022,,100,,,,,,,,,,,1000012
+Aho,FI21 1234 5600 0007 85,+358401234567,aho@example.com,Tie 1,12345,Kylä,91-404-1-123,2,k,,,2,M1,100,1.1.2022,140,1.7.2022,141,40,"75,00","18,00","93,00","5,95","59,51","14,28","73,79","Vakuutus 33,92 + ALV 0 % 0,00 = 33,92; Huolto 10,18 + ALV 25,5 % 2,60 = 12,78","46,70","213,49",1000038
+Aho,,,,,,,,,,19.10.2026,19.10.2026,,,,,,,,-10,"-15,00","-3,60","-18,60","0,00","0,00","0,00","0,00",,"0,00","-18,60",1000067
+Lähtenyt,,,,,,,,,,,1.12.2021,4,M3,10,1.4.2021,30,1.12.2021,,20,,,,,,,,,,,
+Mökki,,,,,,,,,,,,,,,,,,,,,,,,,,,"Vakuutus 33,91 + ALV 0 % 0,00 = 33,91","33,91","33,91",1000054
+###,,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Päivä,19.10.2026,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Maksuaika,14,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Päämittari,"30,00",,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Vesi,"1,525",,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+ALV,"25,5",24,1.9.2024,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Viesti,Hei,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+`
+
+func TestCSVFile_Dataset(t *testing.T) {
+	for _, format := range []model.Format{model.JSON, model.YAML} {
+		t.Run(format.String(), func(t *testing.T) {
+			f, err := Read(strings.NewReader(modelTestData))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d, err := f.Dataset()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(d.Members) != 5 || d.Members[1].Meter.Reading.Check != "141" || d.Members[2].Bill.Total.String() != "-18.6" {
+				t.Errorf("unexpected dataset %+v", d)
+			}
+
+			var buf bytes.Buffer
+			if err := model.Write(&buf, d, format); err != nil {
+				t.Fatal(err)
+			}
+			d, err = model.Read(&buf, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out bytes.Buffer
+			if err := FromDataset(d).Write(&out); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != modelTestData {
+				t.Errorf("round trip changed the data:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestFromDataset_defaultHeader(t *testing.T) {
+	d, err := model.Read(strings.NewReader(`{
+		"members": [{"name": "Aho", "meter": {"site": "2", "number": "M1", "reading": {"counter": 140, "date": "2022-07-01"}}}],
+		"parameters": {"date": "2022-07-15", "payment_days": 14, "main_meter_fee": "30", "water_price": "1.5", "vat": "25.5", "message": ""}
+	}`), model.JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := FromDataset(d)
+	if len(f.headerRow) != colReference+1 || f.headerRow[colName] != "Nimi" {
+		t.Errorf("header = %v", f.headerRow)
+	}
+	rdg, err := f.meterRows[0].Reading()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rdg.Counter != 140 || rdg.Date.Format(datefmt) != "1.7.2022" {
+		t.Errorf("reading = %+v", rdg)
+	}
+	if problems := f.Validate(); len(problems) != 0 {
+		t.Errorf("problems: %v", problems)
+	}
+}
+
+func TestCSVFile_Write_exchange(t *testing.T) {
+	f, err := Read(strings.NewReader(modelTestData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex := meter.Exchange{Date: time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC), FinalCounter: 150, NewNumber: "M9", StartCounter: 0}
+	if err := f.meterRows[1].ExchangeMeter(ex); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	written := buf.String()
+	f, err = Read(&buf)
+	if err != nil {
+		t.Fatalf("read the file with the exchange column: %v", err)
+	}
+	if got := f.headerRow[colExchange]; got != exchangeHeader {
+		t.Errorf("header of the exchange column = %q", got)
+	}
+
+	d, err := f.Dataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := d.Members[1].Meter.Exchange
+	if got == nil || got.OldNumber != "M1" || got.FinalCounter != 150 || d.Members[1].Meter.Number != "M9" {
+		t.Fatalf("exchange = %+v", got)
+	}
+
+	var out bytes.Buffer
+	if err := FromDataset(d).Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != written {
+		t.Errorf("round trip changed the data:\n%s", out.String())
+	}
+}
+
+func TestCSVFile_Dataset_lines(t *testing.T) {
+	f, err := Read(strings.NewReader(modelTestData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cv, err := f.CommonVariables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cv.MonthlyFee = decimal.NewFromInt(10)
+	cv.MonthConvention = period.Actual365
+	r := &f.meterRows[1]
+	r.rec[colPrevDate] = "1.7.2024"
+	r.rec[colDate] = "1.10.2024"
+	if err := r.UpdateBilling("1000038", cv, nil); err != nil {
+		t.Fatal(err)
+	}
+	f.SetDate(time.Date(2024, 10, 2, 0, 0, 0, 0, time.UTC))
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	written := buf.String()
+	f, err = Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := f.Dataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := d.Members[1].Bill
+	if len(b.WaterLines) != 2 || len(b.BasicLines) != 2 || !b.WaterLines[0].VAT.Equal(decimal.NewFromInt(24)) {
+		t.Fatalf("bill = %+v", b)
+	}
+	if b.Date == nil || b.Date.Format(datefmt) != "2.10.2024" {
+		t.Errorf("bill date = %v, want 2.10.2024", b.Date)
+	}
+
+	var out bytes.Buffer
+	if err := FromDataset(d).Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != written {
+		t.Errorf("round trip changed the data:\n%s", out.String())
+	}
+}
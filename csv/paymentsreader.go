@@ -0,0 +1,54 @@
+package csv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jarnoan/vesimittari/payment"
+	"github.com/jarnoan/vesimittari/reference"
+	"github.com/shopspring/decimal"
+)
+
+// ReadPayments reads the received payments from a CSV file.
+// The expected columns are date, reference and amount.
+func ReadPayments(rdr io.Reader) ([]payment.Payment, error) {
+	r := csv.NewReader(rdr)
+
+	// read header row
+	if _, err := r.Read(); err != nil {
+		return nil, fmt.Errorf("read header row: %w", err)
+	}
+
+	// read payment rows
+	var res []payment.Payment
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			// all rows read
+			return res, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read row: %w", err)
+		}
+
+		date, err := time.Parse(datefmt, row[0])
+		if err != nil {
+			return nil, fmt.Errorf("read date column: %w", err)
+		}
+
+		amount, err := decimal.NewFromString(strings.Replace(row[2], ",", ".", 1))
+		if err != nil {
+			return nil, fmt.Errorf("read amount column: %w", err)
+		}
+
+		res = append(res, payment.Payment{
+			Date:      date,
+			Reference: reference.Number(strings.TrimLeft(strings.ReplaceAll(row[1], " ", ""), "0")),
+			Amount:    amount,
+		})
+	}
+}
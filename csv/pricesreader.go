@@ -0,0 +1,81 @@
+package csv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+// ReadPrices reads the water price components from a CSV file.
+// The expected columns are label, price €/m³, vat % and optionally the
+// yearly volume in m³ above which the price applies. Rows with the same
+// label form the tiers of one component.
+func ReadPrices(rdr io.Reader) ([]updater.PriceComponent, error) {
+	r := csv.NewReader(rdr)
+	r.FieldsPerRecord = -1
+
+	// read header row
+	if _, err := r.Read(); err != nil {
+		return nil, fmt.Errorf("read header row: %w", err)
+	}
+
+	// read price rows
+	var res []updater.PriceComponent
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			// all rows read
+			return res, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read row: %w", err)
+		}
+		if len(row) < 3 {
+			return nil, fmt.Errorf("row %v has %d columns, want at least 3", row, len(row))
+		}
+
+		price, err := decimal.NewFromString(row[1])
+		if err != nil {
+			return nil, fmt.Errorf("read price column: %w", err)
+		}
+
+		vat, err := decimal.NewFromString(row[2])
+		if err != nil {
+			return nil, fmt.Errorf("read VAT column: %w", err)
+		}
+
+		above := decimal.Zero
+		if len(row) > 3 && row[3] != "" {
+			above, err = decimal.NewFromString(row[3])
+			if err != nil {
+				return nil, fmt.Errorf("read volume column: %w", err)
+			}
+		}
+
+		idx := -1
+		for i, pc := range res {
+			if pc.Label == row[0] {
+				idx = i
+			}
+		}
+
+		switch {
+		case idx < 0 && above.IsZero():
+			res = append(res, updater.PriceComponent{Label: row[0], VAT: vat, Price: price})
+		case idx < 0:
+			return nil, fmt.Errorf("tier of %s before its base price", row[0])
+		case above.IsZero():
+			return nil, fmt.Errorf("duplicate base price for %s", row[0])
+		default:
+			tiers := res[idx].Tiers
+			if len(tiers) > 0 && !above.GreaterThan(tiers[len(tiers)-1].Above) {
+				return nil, fmt.Errorf("tiers of %s are not in ascending order", row[0])
+			}
+			res[idx].Tiers = append(tiers, updater.PriceTier{Above: above, Price: price})
+		}
+	}
+}
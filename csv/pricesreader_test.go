@@ -0,0 +1,35 @@
+package csv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadPrices(t *testing.T) {
+	in := `label,price,vat,above
+Vesi,1.50,24
+Jätevesi,2.10,24,
+Vesi,2.00,24,200
+`
+	pcs, err := ReadPrices(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pcs) != 2 {
+		t.Fatalf("got %d components, want 2", len(pcs))
+	}
+	if pcs[0].Label != "Vesi" || len(pcs[0].Tiers) != 1 || pcs[0].Tiers[0].Above.IntPart() != 200 {
+		t.Errorf("first component = %+v", pcs[0])
+	}
+	if pcs[1].Label != "Jätevesi" || len(pcs[1].Tiers) != 0 {
+		t.Errorf("second component = %+v", pcs[1])
+	}
+}
+
+func TestReadPrices_tierBeforeBase(t *testing.T) {
+	in := "label,price,vat,above\nVesi,2.00,24,200\n"
+	if _, err := ReadPrices(strings.NewReader(in)); err == nil {
+		t.Error("ReadPrices succeeded, want error")
+	}
+}
@@ -0,0 +1,294 @@
+package csv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jarnoan/vesimittari/contact"
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/jarnoan/vesimittari/property"
+	"github.com/jarnoan/vesimittari/reference"
+	"github.com/shopspring/decimal"
+)
+
+// Problem is a problem found in the data file.
+type Problem struct {
+	Row    int    // row of the file, the header being row 1
+	Column string // name of the column, empty if not about a single column
+	Msg    string
+}
+
+func (p Problem) String() string {
+	if p.Column == "" {
+		return fmt.Sprintf("row %d: %s", p.Row, p.Msg)
+	}
+
+	return fmt.Sprintf("row %d, %s: %s", p.Row, p.Column, p.Msg)
+}
+
+// Validate checks the meter rows and the parameter rows and returns all
+// the problems found, so that they can be fixed before billing.
+func (f *CSVFile) Validate() []Problem {
+	var res []Problem
+	add := func(row, col int, format string, args ...interface{}) {
+		p := Problem{Row: row, Msg: fmt.Sprintf(format, args...)}
+		if col >= 0 {
+			p.Column = f.columnName(col)
+		}
+		res = append(res, p)
+	}
+
+	// use is a row using a meter or a property during the membership
+	type use struct {
+		row        int
+		membership period.Period
+	}
+	// overlapping returns the first of the uses whose membership overlaps
+	overlapping := func(uses []use, membership period.Period) (use, bool) {
+		for _, u := range uses {
+			if u.membership.Overlaps(membership) {
+				return u, true
+			}
+		}
+		return use{}, false
+	}
+
+	meters := make(map[string][]use)
+	refs := make(map[string]int)
+	properties := make(map[property.ID][]use)
+	for i := range f.meterRows {
+		row := i + 2
+		rec := f.meterRows[i].rec
+		if len(rec) <= colReference {
+			add(row, -1, "%d columns, want %d", len(rec), colReference+1)
+			continue
+		}
+
+		if strings.TrimSpace(rec[colName]) == "" {
+			add(row, colName, "missing")
+		}
+
+		for _, c := range normalizedColumns {
+			if rec[c.col] == "" {
+				continue
+			}
+			if _, err := c.normalize(rec[c.col]); err != nil {
+				add(row, c.col, "%s", err)
+			}
+		}
+
+		// parseDate parses the date in the column, zero if the column is empty
+		parseDate := func(col int) (time.Time, bool) {
+			if rec[col] == "" {
+				return time.Time{}, true
+			}
+			d, err := time.Parse(datefmt, rec[col])
+			if err != nil {
+				add(row, col, "%q is not a date like 31.12.2022", rec[col])
+				return time.Time{}, false
+			}
+			return d, true
+		}
+
+		joinDate, joinOK := parseDate(colJoinDate)
+		leaveDate, leaveOK := parseDate(colLeaveDate)
+		if joinOK && leaveOK && !leaveDate.IsZero() && leaveDate.Before(joinDate) {
+			add(row, colLeaveDate, "%s is before the join date %s", rec[colLeaveDate], rec[colJoinDate])
+		}
+		membership := period.New(joinDate, leaveDate)
+
+		// A row with a meter needs the site and the latest reading
+		if rec[colMeter] != "" {
+			for _, col := range []int{colSite, colCounter, colDate} {
+				if rec[col] == "" {
+					add(row, col, "missing")
+				}
+			}
+
+			// The meter of a member who has left may be in use by the next owner
+			if prev, ok := overlapping(meters[rec[colMeter]], membership); ok {
+				add(row, colMeter, "meter %s is also on row %d", rec[colMeter], prev.row)
+			}
+			meters[rec[colMeter]] = append(meters[rec[colMeter]], use{row, membership})
+		}
+
+		for _, col := range []int{colPrevCounter, colCounter, colConsumption} {
+			if rec[col] == "" {
+				continue
+			}
+			n, err := strconv.Atoi(rec[col])
+			if err != nil {
+				add(row, col, "%q is not a whole number", rec[col])
+			} else if n < 0 && col != colConsumption {
+				add(row, col, "%d is negative", n)
+			}
+		}
+
+		// Only a credit note has a negative consumption, the difference
+		// to the original bill
+		if cons, err := strconv.Atoi(rec[colConsumption]); err == nil && cons < 0 && !f.meterRows[i].correction() {
+			add(row, colConsumption, "%d is negative", cons)
+		}
+
+		prevDate, prevOK := parseDate(colPrevDate)
+		date, dateOK := parseDate(colDate)
+		if prevOK && dateOK && !date.IsZero() && date.Before(prevDate) {
+			add(row, colDate, "%s is before the previous date %s", rec[colDate], rec[colPrevDate])
+		}
+
+		for _, col := range []int{colMonths, colWaterFeeWithoutTax, colWaterTax, colWaterFeeWithTax,
+			colBasicFeeWithoutTax, colBasicFeeTax, colBasicFeeWithTax, colExtraCost, colTotal} {
+			if _, err := stringToDecimal(rec[col]); err != nil {
+				add(row, col, "%q is not a number", rec[col])
+			}
+		}
+		if _, err := parseExtraDescription(rec[colExtraDescription]); err != nil {
+			add(row, colExtraDescription, "%s", err)
+		}
+		if _, err := f.meterRows[i].Exchange(); err != nil {
+			add(row, colExchange, "%s", err)
+		}
+		if desc := f.meterRows[i].optional(colLines); desc != "" {
+			if _, err := parseLinesDescription(desc); err != nil {
+				add(row, colLines, "%s", err)
+			}
+		}
+		if _, err := f.meterRows[i].BillDate(); err != nil {
+			add(row, colBillDate, "%s", err)
+		}
+
+		if ref := rec[colReference]; ref != "" {
+			if !reference.Number(ref).Valid() {
+				add(row, colReference, "%s is not a valid reference number", ref)
+			}
+			if prev, ok := refs[ref]; ok {
+				add(row, colReference, "reference %s is also on row %d", ref, prev)
+			}
+			refs[ref] = row
+		}
+
+		// Properties are compared in the normalised form
+		if rec[colPropertyID] != "" {
+			if id, err := property.Parse(rec[colPropertyID]); err == nil {
+				if prev, ok := overlapping(properties[id], membership); ok {
+					add(row, colPropertyID, "property %s is also on row %d", id, prev.row)
+				}
+				properties[id] = append(properties[id], use{row, membership})
+			}
+		}
+	}
+
+	row := len(f.meterRows) + 2
+	if len(f.separatorRow) == 0 || f.separatorRow[0] != "###" {
+		add(row, -1, "missing separator ###")
+	}
+
+	params := []struct {
+		rec   []string
+		what  string
+		check func(string) error
+	}{
+		{f.dateRow, "billing date", func(s string) error { _, err := time.Parse(datefmt, s); return err }},
+		{f.paymentTimeRow, "payment days", func(s string) error {
+			n, err := strconv.Atoi(s)
+			if err == nil && n < 0 {
+				return fmt.Errorf("%d is negative", n)
+			}
+			return err
+		}},
+		{f.mainMeterFeeRow, "main meter fee", checkDecimal},
+		{f.waterPriceRow, "water price", checkDecimal},
+		{f.vatRow, "VAT", checkDecimal},
+		{f.messageRow, "message", nil},
+	}
+	for _, p := range params {
+		row++
+		if len(p.rec) < 2 {
+			add(row, -1, "%s: missing value", p.what)
+			continue
+		}
+		if p.check == nil {
+			continue
+		}
+		if err := p.check(p.rec[1]); err != nil {
+			add(row, -1, "%s: invalid value %q: %s", p.what, p.rec[1], err)
+		}
+	}
+
+	// The previous VAT and the date it changed are optional
+	if len(f.vatRow) > 3 && f.vatRow[3] != "" {
+		vatRow := row - 1
+		if err := checkDecimal(f.vatRow[2]); err != nil {
+			add(vatRow, -1, "previous VAT: invalid value %q: %s", f.vatRow[2], err)
+		}
+		if _, err := time.Parse(datefmt, f.vatRow[3]); err != nil {
+			add(vatRow, -1, "VAT change date: invalid value %q: %s", f.vatRow[3], err)
+		}
+	}
+
+	return res
+}
+
+// normalizedColumns are the columns that have a standard format and the
+// functions that check and normalise them.
+var normalizedColumns = []struct {
+	col       int
+	normalize func(string) (string, error)
+}{
+	{colBankAccount, contact.NormalizeIBAN},
+	{colPhone, contact.NormalizePhone},
+	{colEmail, contact.NormalizeEmail},
+	{colPropertyID, normalizePropertyID},
+}
+
+func normalizePropertyID(s string) (string, error) {
+	id, err := property.Parse(s)
+	if err != nil {
+		return "", err
+	}
+
+	return id.String(), nil
+}
+
+// Normalize writes the bank accounts, phone numbers, email addresses and
+// property IDs of the members in their standard format. Invalid values are
+// left as they are. It returns the number of values changed.
+func (f *CSVFile) Normalize() int {
+	var res int
+	for i := range f.meterRows {
+		rec := f.meterRows[i].rec
+		for _, c := range normalizedColumns {
+			if c.col >= len(rec) || rec[c.col] == "" {
+				continue
+			}
+			if v, err := c.normalize(rec[c.col]); err == nil && v != rec[c.col] {
+				rec[c.col] = v
+				res++
+			}
+		}
+	}
+
+	return res
+}
+
+// columnName returns the name of the column in the header row, or the
+// column letter of a spreadsheet if the header has no name for it.
+func (f *CSVFile) columnName(col int) string {
+	if col < len(f.headerRow) && strings.TrimSpace(f.headerRow[col]) != "" {
+		return strings.TrimSpace(f.headerRow[col])
+	}
+
+	var letters string
+	for n := col + 1; n > 0; n = (n - 1) / 26 {
+		letters = string(rune('A'+(n-1)%26)) + letters
+	}
+
+	return "column " + letters
+}
+
+func checkDecimal(s string) error {
+	_, err := decimal.NewFromString(strings.Replace(s, ",", ".", 1))
+	return err
+}
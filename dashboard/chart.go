@@ -0,0 +1,54 @@
+package dashboard
+
+// Size of the chart in SVG units.
+const (
+	chartWidth  = 600
+	chartHeight = 200
+)
+
+// Chart is a bar chart of the consumptions of a member.
+type Chart struct {
+	Width, Height int
+	Max           int // m³ of the highest bar
+	Bars          []Bar
+}
+
+// Bar is a bar of the chart with its position.
+type Bar struct {
+	Interval
+	X, Y, Width, Height int
+}
+
+// newChart returns the chart of the consumptions, the oldest first.
+func newChart(ivs []Interval) Chart {
+	res := Chart{Width: chartWidth, Height: chartHeight}
+	if len(ivs) == 0 {
+		return res
+	}
+
+	for _, iv := range ivs {
+		if iv.Consumption > res.Max {
+			res.Max = iv.Consumption
+		}
+	}
+
+	w := chartWidth / len(ivs)
+	if w < 2 {
+		w = 2
+	}
+	for i, iv := range ivs {
+		h := 0
+		if res.Max > 0 {
+			h = iv.Consumption * chartHeight / res.Max
+		}
+		res.Bars = append(res.Bars, Bar{
+			Interval: iv,
+			X:        i * w,
+			Y:        chartHeight - h,
+			Width:    w * 4 / 5, // gap between the bars
+			Height:   h,
+		})
+	}
+
+	return res
+}
@@ -0,0 +1,224 @@
+// Package dashboard serves a web dashboard of the database for the board
+// of the cooperative. The pages are rendered on the server and use no
+// scripts.
+//
+// The token is asked as the password of HTTP basic authentication so that
+// the pages can be opened in a browser.
+package dashboard
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jarnoan/vesimittari/format"
+	"github.com/jarnoan/vesimittari/payment"
+	"github.com/jarnoan/vesimittari/store"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+var funcs = template.FuncMap{
+	"amount": format.Amount,
+	"percent": func(d decimal.Decimal) string {
+		return format.Fixed(d, 1) + " %"
+	},
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2.1.2006")
+	},
+}
+
+// pages are the templates of the pages, each with the layout.
+var pages = map[string]*template.Template{
+	"index":   page("index.html"),
+	"member":  page("member.html"),
+	"billing": page("billing.html"),
+}
+
+func page(name string) *template.Template {
+	return template.Must(template.New(name).Funcs(funcs).ParseFS(templateFS, "templates/layout.html", "templates/"+name))
+}
+
+// Options are the options of the dashboard.
+type Options struct {
+	Token           string                            // password of the board
+	Updater         updater.Options                   // of the dry-run billing
+	AdditionalCosts []updater.AdditionalCost          // billed by the dry-run billing
+	Payments        func() ([]payment.Payment, error) // received payments, nil if not known
+}
+
+// Server serves the dashboard.
+type Server struct {
+	store *store.Store
+	opts  Options
+	mux   *http.ServeMux
+	mu    sync.Mutex // serialises the dry-run billings
+}
+
+// New constructs a new dashboard for the database.
+func New(s *store.Store, opts Options) *Server {
+	res := &Server{store: s, opts: opts, mux: http.NewServeMux()}
+	res.mux.HandleFunc("/", res.index)
+	res.mux.HandleFunc("/members/", res.member)
+	res.mux.HandleFunc("/billing", res.billing)
+
+	return res
+}
+
+// ServeHTTP serves the request if it has the token as the password.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, password, ok := r.BasicAuth()
+	if s.opts.Token == "" || !ok || subtle.ConstantTimeCompare([]byte(password), []byte(s.opts.Token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="vesimittari", charset="UTF-8"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+// index shows the members, the water balance and the unpaid bills.
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.view(w, r, "index", func(d *store.Data) (interface{}, error) {
+		members, err := members(d, s.opts.Updater.MeterDigits)
+		if err != nil {
+			return nil, err
+		}
+		balance, err := waterBalance(d, s.opts.Updater.MeterDigits)
+		if err != nil {
+			return nil, err
+		}
+
+		var payments []payment.Payment
+		if s.opts.Payments != nil {
+			if payments, err = s.opts.Payments(); err != nil {
+				return nil, err
+			}
+		}
+		unpaid, err := unpaidBills(d, payments, time.Now())
+		if err != nil {
+			return nil, err
+		}
+
+		return struct {
+			Members     []Member
+			Balance     *Balance
+			Unpaid      []Unpaid
+			HasPayments bool
+		}{members, balance, unpaid, s.opts.Payments != nil}, nil
+	})
+}
+
+// member shows the consumption chart of the member at /members/{id}.
+func (s *Server) member(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/members/"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.view(w, r, "member", func(d *store.Data) (interface{}, error) {
+		mr, err := d.Record(id)
+		if err != nil {
+			return nil, err
+		}
+		ivs, err := consumptions(d, mr, s.opts.Updater.MeterDigits)
+		if err != nil {
+			return nil, err
+		}
+
+		return struct {
+			Name  string
+			Chart Chart
+		}{mr.Name(), newChart(ivs)}, nil
+	})
+}
+
+// billing bills the members with the readings in the database in a
+// transaction that is discarded and shows the bills.
+func (s *Server) billing(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.view(w, r, "billing", func(d *store.Data) (interface{}, error) {
+		opts := s.opts.Updater
+		opts.UpdateMeterReadings = false
+		bills, err := updater.New(nil, opts).Update(d, s.opts.AdditionalCosts)
+		if err != nil {
+			return nil, err
+		}
+
+		var total decimal.Decimal
+		for _, b := range bills {
+			total = total.Add(b.Total())
+		}
+
+		return struct {
+			Bills []updater.Bill
+			Total decimal.Decimal
+		}{bills, total}, nil
+	})
+}
+
+// view renders the page with the data returned by f in a transaction that
+// is discarded.
+func (s *Server) view(w http.ResponseWriter, r *http.Request, name string, f func(d *store.Data) (interface{}, error)) {
+	d, err := s.store.Begin()
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	defer d.Rollback()
+
+	data, err := f(d)
+	if errors.Is(err, store.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := pages[name].ExecuteTemplate(&buf, "layout", data); err != nil {
+		serverError(w, fmt.Errorf("render %s: %w", name, err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := buf.WriteTo(w); err != nil {
+		log.Printf("%s %s: write response: %s", r.Method, r.URL.Path, err)
+	}
+}
+
+// serverError logs the error and responds with it.
+func serverError(w http.ResponseWriter, err error) {
+	log.Print(err)
+	http.Error(w, fmt.Sprintf("Error: %s", err), http.StatusInternalServerError)
+}
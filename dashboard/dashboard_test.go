@@ -0,0 +1,270 @@
+package dashboard
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarnoan/vesimittari/csv"
+	"github.com/jarnoan/vesimittari/meter"
+	"github.com/jarnoan/vesimittari/model"
+	"github.com/jarnoan/vesimittari/payment"
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/jarnoan/vesimittari/store"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+const testData = `Nimi,Tilinumero,Puhelin,Sähköposti,Katuosoite,Postinumero,Postitoimipaikka,Kiinteistötunnus,Asukkaita,Vakituinen asunto,Liittynyt,Eronnut,Mittauspaikka,Mittari,Edellinen lukema,Edellinen pvm,Lukema,Pvm,Tarkistus,Kulutus,Vesimaksu alv 0,Vesimaksu alv,Vesimaksu,Kk,Perusmaksu alv 0,Perusmaksu alv,Perusmaksu,Lisämaksujen selite,Lisämaksut,Yhteensä,Viite
+Pää,,,,,,,,,,,,1,M0,900,1.1.2022,1000,1.7.2022,,100,,,,,,,,,,,1000012
+Aho,,,,,,,,,,,,2,M1,100,1.1.2022,140,1.7.2022,,40,"61,00","14,64","75,64","5,95","59,51","14,28","73,79",,"0,00","149,43",1000025
+Bäck,,,,,,,,,,,,3,M2,50,1.1.2022,110,1.7.2022,,60,"91,50","21,96","113,46","5,95","59,51","14,28","73,79",,"0,00","187,25",1000038
+###,,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Päivä,1.7.2022,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Maksuaika,14,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Päämittari,"30,00",,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Vesi,"1,525",,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+ALV,24,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Viesti,Hei,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+`
+
+func testServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	f, err := csv.Read(strings.NewReader(testData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := f.Dataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := s.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Import(ds); err != nil {
+		t.Fatal(err)
+	}
+
+	// A third reading of Aho for the chart
+	mrs, err := d.MeterRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mrs[1].AddReading(meter.Reading{Counter: 165, Date: time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(New(s, Options{
+		Token: "secret",
+		Updater: updater.Options{
+			MonthConvention: period.Actual365,
+			Date:            time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC),
+		},
+		Payments: func() ([]payment.Payment, error) {
+			return []payment.Payment{{Reference: "1000025", Amount: decimal.RequireFromString("149.43")}}, nil
+		},
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// get makes the request with the token and returns the status and the body.
+func get(t *testing.T, srv *httptest.Server, method, path string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("hallitus", "secret")
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp.StatusCode, string(body)
+}
+
+func TestServer_auth(t *testing.T) {
+	srv := testServer(t)
+
+	resp, err := srv.Client().Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+		t.Errorf("status %d, want %d with a challenge", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	u, _ := url.Parse(srv.URL)
+	u.User = url.UserPassword("hallitus", "wrong")
+	resp, err = srv.Client().Get(u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong password: status %d", resp.StatusCode)
+	}
+}
+
+func TestServer_index(t *testing.T) {
+	srv := testServer(t)
+
+	status, body := get(t, srv, http.MethodGet, "/")
+	if status != http.StatusOK {
+		t.Fatalf("status %d: %s", status, body)
+	}
+	for _, want := range []string{
+		`<a href="/members/2">Aho</a>`,
+		"<td>1.10.2022</td><td class=\"num\">165</td>",
+		"<tr><th>Päämittari</th><td class=\"num\">100 m³</td></tr>",
+		"<tr><th>Alamittarit (1)</th><td class=\"num\">60 m³</td></tr>",
+		"40 m³ (40,0 %)",
+		"<td>1000038</td>",
+		`<form method="post" action="/billing">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page does not contain %s", want)
+		}
+	}
+	if strings.Contains(body, "<td>1000025</td>") {
+		t.Error("paid bill is shown as unpaid")
+	}
+	if strings.Contains(body, "<script") {
+		t.Error("page has a script")
+	}
+
+	if status, _ := get(t, srv, http.MethodGet, "/other"); status != http.StatusNotFound {
+		t.Errorf("other page: status %d", status)
+	}
+}
+
+func TestServer_member(t *testing.T) {
+	srv := testServer(t)
+
+	status, body := get(t, srv, http.MethodGet, "/members/2")
+	if status != http.StatusOK {
+		t.Fatalf("status %d: %s", status, body)
+	}
+	if strings.Count(body, "<rect ") != 2 || !strings.Contains(body, "1.7.2022 – 1.10.2022: 25 m³") {
+		t.Errorf("unexpected chart:\n%s", body)
+	}
+
+	for _, path := range []string{"/members/999", "/members/x"} {
+		if status, _ := get(t, srv, http.MethodGet, path); status != http.StatusNotFound {
+			t.Errorf("%s: status %d", path, status)
+		}
+	}
+}
+
+func TestServer_billing(t *testing.T) {
+	srv := testServer(t)
+
+	status, body := get(t, srv, http.MethodPost, "/billing")
+	if status != http.StatusOK {
+		t.Fatalf("status %d: %s", status, body)
+	}
+	if !strings.Contains(body, "<td>1000041</td>") || !strings.Contains(body, "<td>Aho</td>") {
+		t.Errorf("unexpected bills:\n%s", body)
+	}
+
+	// The dry run is not stored
+	if _, body := get(t, srv, http.MethodGet, "/"); strings.Contains(body, "1000041") {
+		t.Error("dry-run bill stored")
+	}
+
+	if status, _ := get(t, srv, http.MethodGet, "/billing"); status != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d", status)
+	}
+}
+
+func TestUnpaidBills(t *testing.T) {
+	s, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	f, err := csv.Read(strings.NewReader(testData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := f.Dataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The bills of the data were issued on 1.7.2022
+	for _, m := range ds.Members {
+		if m.Bill != nil {
+			m.Bill.Date = model.NewDate(time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC))
+		}
+	}
+	d, err := s.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Rollback()
+	if err := d.Import(ds); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second billing on 1.10.2022 with a new reading of Aho
+	mrs, err := d.MeterRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mrs[1].AddReading(meter.Reading{Counter: 165, Date: time.Date(2022, 9, 30, 0, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatal(err)
+	}
+	opts := updater.Options{MonthConvention: period.Actual365, Date: time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)}
+	if _, err := updater.New(nil, opts).Update(d, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	payments := []payment.Payment{{Reference: "1000025", Amount: decimal.RequireFromString("149.43")}}
+	unpaid, err := unpaidBills(d, payments, time.Date(2022, 10, 10, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The earlier bill of Bäck is overdue, the new bills are not yet due
+	want := map[string]time.Time{
+		"1000038": time.Date(2022, 7, 15, 0, 0, 0, 0, time.UTC),
+		"1000041": time.Date(2022, 10, 15, 0, 0, 0, 0, time.UTC),
+		"1000054": time.Date(2022, 10, 15, 0, 0, 0, 0, time.UTC),
+	}
+	if len(unpaid) != len(want) {
+		t.Fatalf("got %d unpaid bills, want %d: %+v", len(unpaid), len(want), unpaid)
+	}
+	for _, u := range unpaid {
+		due, ok := want[string(u.Bill.Reference)]
+		if !ok || !u.DueDate.Equal(due) || u.Overdue != due.Before(time.Date(2022, 10, 10, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("unexpected unpaid bill %s due %s, overdue %v", u.Bill.Reference, u.DueDate, u.Overdue)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package dashboard
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jarnoan/vesimittari/meter"
+	"github.com/jarnoan/vesimittari/payment"
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/jarnoan/vesimittari/reference"
+	"github.com/jarnoan/vesimittari/store"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+// Member is a member with the latest reading of the meter.
+type Member struct {
+	ID          int64
+	Name        string
+	Membership  period.Period
+	Site        meter.SiteNumber
+	Number      meter.Number
+	Reading     *meter.Reading
+	Consumption *Interval // between the two latest readings
+}
+
+// Interval is the consumption between two readings.
+type Interval struct {
+	Period      period.Period
+	Consumption int // m³
+}
+
+// Balance is the water balance between the main meter and the sub-meters
+// over the latest reading period of the main meter. The difference is
+// water lost in leaks or used without a meter.
+type Balance struct {
+	Period    period.Period
+	Main      int // m³
+	SubMeters int // m³ of the sub-meters read within the period
+	Meters    int // number of the sub-meters read within the period
+}
+
+// Difference returns the consumption of the main meter not measured by
+// the sub-meters.
+func (b Balance) Difference() int {
+	return b.Main - b.SubMeters
+}
+
+// Percent returns the difference as a percentage of the main meter.
+func (b Balance) Percent() decimal.Decimal {
+	if b.Main == 0 {
+		return decimal.Zero
+	}
+
+	return decimal.NewFromInt(int64(b.Difference()*100)).DivRound(decimal.NewFromInt(int64(b.Main)), 1)
+}
+
+// Unpaid is a bill not paid in full.
+type Unpaid struct {
+	payment.Balance
+	DueDate time.Time
+	Overdue bool
+}
+
+// members returns the members with the latest readings of their meters.
+func members(d *store.Data, digits int) ([]Member, error) {
+	mrs, err := d.MeterRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Member
+	for _, mr := range mrs {
+		m := Member{Name: mr.Name()}
+		if m.ID, err = d.ID(mr); err != nil {
+			return nil, err
+		}
+		if m.Membership, err = mr.Membership(); err != nil {
+			return nil, err
+		}
+		if m.Site, err = mr.SiteNumber(); err != nil {
+			return nil, err
+		}
+		if m.Number, err = mr.MeterNumber(); err != nil {
+			return nil, err
+		}
+
+		if m.Number != "" {
+			rdgs, err := d.Readings(mr)
+			if err != nil {
+				return nil, err
+			}
+			if len(rdgs) > 0 {
+				m.Reading = &rdgs[0]
+			}
+			if m.Consumption, err = latestConsumption(d, mr, digits); err != nil {
+				return nil, err
+			}
+		}
+
+		res = append(res, m)
+	}
+
+	return res, nil
+}
+
+// consumptions returns the consumptions between the readings of the
+// member, the oldest first. A meter exchange between the readings is
+// included. Intervals where the counter decreased are skipped.
+func consumptions(d *store.Data, mr updater.MeterRecord, digits int) ([]Interval, error) {
+	rdgs, err := d.Readings(mr)
+	if err != nil {
+		return nil, err
+	}
+	exs, err := d.Exchanges(mr)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Interval
+	for i := len(rdgs) - 1; i > 0; i-- {
+		prev, cur := rdgs[i], rdgs[i-1]
+		p := period.New(prev.Date, cur.Date)
+
+		cons, err := meter.Consumption(prev.Counter, cur.Counter, digits)
+		for _, ex := range exs {
+			if !ex.Date.Before(prev.Date) && ex.Date.Before(cur.Date) {
+				cons, err = ex.Consumption(prev.Counter, cur.Counter, digits)
+				break
+			}
+		}
+		if err != nil {
+			continue
+		}
+		res = append(res, Interval{p, cons})
+	}
+
+	return res, nil
+}
+
+// latestConsumption returns the consumption between the two latest
+// readings of the member, nil if the meter has not been read twice.
+func latestConsumption(d *store.Data, mr updater.MeterRecord, digits int) (*Interval, error) {
+	ivs, err := consumptions(d, mr, digits)
+	if err != nil || len(ivs) == 0 {
+		return nil, err
+	}
+
+	return &ivs[len(ivs)-1], nil
+}
+
+// waterBalance returns the water balance of the latest reading period of
+// the main meter, the first member. It is nil if the main meter has not
+// been read twice.
+func waterBalance(d *store.Data, digits int) (*Balance, error) {
+	mrs, err := d.MeterRecords()
+	if err != nil {
+		return nil, err
+	}
+	if len(mrs) == 0 {
+		return nil, nil
+	}
+
+	main, err := latestConsumption(d, mrs[0], digits)
+	if err != nil || main == nil {
+		return nil, err
+	}
+	res := &Balance{Period: main.Period, Main: main.Consumption}
+
+	for _, mr := range mrs[1:] {
+		num, err := mr.MeterNumber()
+		if err != nil {
+			return nil, err
+		}
+		if num == "" {
+			continue
+		}
+
+		iv, err := latestConsumption(d, mr, digits)
+		if err != nil {
+			return nil, err
+		}
+		if iv == nil || !iv.Period.End.After(res.Period.Start) || iv.Period.End.After(res.Period.End) {
+			continue
+		}
+		res.SubMeters += iv.Consumption
+		res.Meters++
+	}
+
+	return res, nil
+}
+
+// unpaidBills returns the bills in the history of the members that have
+// not been paid in full. Each bill is due the payment days after the date
+// it was issued, or after the billing date if the date is not known.
+func unpaidBills(d *store.Data, payments []payment.Payment, now time.Time) ([]Unpaid, error) {
+	mrs, err := d.MeterRecords()
+	if err != nil {
+		return nil, err
+	}
+	date, err := d.Date()
+	if err != nil {
+		return nil, err
+	}
+	days, err := d.PaymentDays()
+	if err != nil {
+		return nil, err
+	}
+
+	var bills []updater.Bill
+	due := make(map[reference.Number]time.Time)
+	for _, mr := range mrs {
+		dbs, err := d.Bills(mr)
+		if err != nil {
+			return nil, fmt.Errorf("get bills of %s: %w", mr.Name(), err)
+		}
+		for _, db := range dbs {
+			if len(db.Lines) == 0 {
+				continue
+			}
+			issued := db.Date
+			if issued.IsZero() {
+				issued = date
+			}
+			bills = append(bills, db.Bill)
+			due[db.Reference] = issued.AddDate(0, 0, days)
+		}
+	}
+
+	var res []Unpaid
+	balances, _ := payment.Reconcile(bills, payments)
+	for _, b := range balances {
+		if b.Unpaid().IsPositive() {
+			dd := due[b.Bill.Reference]
+			res = append(res, Unpaid{Balance: b, DueDate: dd, Overdue: now.After(dd)})
+		}
+	}
+
+	return res, nil
+}
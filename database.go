@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/jarnoan/vesimittari/config"
+	"github.com/jarnoan/vesimittari/csv"
+	"github.com/jarnoan/vesimittari/store"
+)
+
+// openDatabase opens the configured database.
+func openDatabase(cfg config.Config) (*store.Store, error) {
+	if cfg.Database == "" {
+		return nil, fmt.Errorf("no database given")
+	}
+
+	return store.Open(cfg.Database)
+}
+
+// runDBImport replaces the contents of the database with the data. The
+// configured water prices replace the single price of the data file.
+func runDBImport(cfg config.Config, args []string) error {
+	newFlagSet("db-import").Parse(args)
+
+	csvf, _, err := readData(cfg)
+	if err != nil {
+		return err
+	}
+	ds, err := csvf.Dataset()
+	if err != nil {
+		return fmt.Errorf("convert data: %w", err)
+	}
+
+	s, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	d, err := s.Begin()
+	if err != nil {
+		return err
+	}
+	if err := d.Import(ds); err != nil {
+		d.Rollback()
+		return fmt.Errorf("import data: %w", err)
+	}
+	if prices := cfg.WaterPrices(); len(prices) > 0 {
+		if err := d.SetWaterPrices(prices); err != nil {
+			d.Rollback()
+			return err
+		}
+	}
+
+	return d.Commit()
+}
+
+// runDBExport writes the contents of the database as the data.
+func runDBExport(cfg config.Config, args []string) error {
+	newFlagSet("db-export").Parse(args)
+
+	s, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	d, err := s.Begin()
+	if err != nil {
+		return err
+	}
+	defer d.Rollback()
+
+	ds, err := d.Dataset()
+	if err != nil {
+		return fmt.Errorf("export data: %w", err)
+	}
+
+	file, err := createData(cfg)
+	if err != nil {
+		return err
+	}
+
+	return writeData(file, csv.FromDataset(ds))
+}
@@ -0,0 +1,133 @@
+package datafile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrChanged is returned when the file has been changed since it was read.
+var ErrChanged = errors.New("file has changed since it was read")
+
+// File is a file that is read and then replaced with a new version.
+type File struct {
+	name   string
+	sum    [sha256.Size]byte // of the content read
+	mode   os.FileMode
+	exists bool // false for a new file not created yet
+}
+
+// Read reads the content of the file.
+func Read(name string) (*File, []byte, error) {
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &File{name: name, sum: sha256.Sum256(content), mode: info.Mode().Perm(), exists: true}, content, nil
+}
+
+// New returns a file that does not exist yet. Replace creates it, unless
+// it has been created by somebody else in the meantime.
+func New(name string) *File {
+	return &File{name: name, mode: 0o644}
+}
+
+// Name returns the name of the file.
+func (f *File) Name() string {
+	return f.name
+}
+
+// Replace replaces the content of the file. The previous version is kept
+// as a backup named after the file and the current time to the
+// nanosecond, with a counter added if the name is taken. The new content
+// is written to a temporary file that is renamed over the file, so the
+// file is never left partially written. Replace refuses to overwrite the
+// file if it has been changed since it was read.
+func (f *File) Replace(write func(io.Writer) error) error {
+	current, err := os.ReadFile(f.name)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && !f.exists:
+	case err != nil:
+		return err
+	case !f.exists || sha256.Sum256(current) != f.sum:
+		return fmt.Errorf("%s: %w", f.name, ErrChanged)
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+
+	if f.exists {
+		backup, err := backupName(f.name, time.Now())
+		if err != nil {
+			return fmt.Errorf("write backup: %w", err)
+		}
+		if err := writeAtomic(backup, current, f.mode); err != nil {
+			return fmt.Errorf("write backup: %w", err)
+		}
+	}
+
+	if err := writeAtomic(f.name, buf.Bytes(), f.mode); err != nil {
+		return err
+	}
+	f.sum = sha256.Sum256(buf.Bytes())
+	f.exists = true
+
+	return nil
+}
+
+// backupName returns a name for a backup of the file that is not taken.
+func backupName(name string, now time.Time) (string, error) {
+	base := fmt.Sprintf("%s.%s", name, now.Format("20060102T150405.000000000"))
+	res := base + ".bak"
+	for i := 1; ; i++ {
+		_, err := os.Lstat(res)
+		if errors.Is(err, os.ErrNotExist) {
+			return res, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		res = fmt.Sprintf("%s.%d.bak", base, i)
+	}
+}
+
+// writeAtomic writes the content to a temporary file in the directory of
+// the file and renames it to the name of the file.
+func writeAtomic(name string, content []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly after the rename
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
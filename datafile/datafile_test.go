@@ -0,0 +1,157 @@
+package datafile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jarnoan/vesimittari/csv"
+)
+
+func TestFile_Replace(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "data.csv")
+	if err := os.WriteFile(name, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, content, err := Read(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "old" {
+		t.Fatalf("read %q", content)
+	}
+
+	if err := f.Replace(func(w io.Writer) error {
+		_, err := io.WriteString(w, "new")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := os.ReadFile(name); string(got) != "new" {
+		t.Errorf("file contains %q, want new", got)
+	}
+	if info, _ := os.Stat(name); info.Mode().Perm() != 0o600 {
+		t.Errorf("file mode %s, want 0600", info.Mode())
+	}
+
+	backups, _ := filepath.Glob(name + ".*.bak")
+	if len(backups) != 1 {
+		t.Fatalf("got %d backups, want 1", len(backups))
+	}
+	if got, _ := os.ReadFile(backups[0]); string(got) != "old" {
+		t.Errorf("backup contains %q, want old", got)
+	}
+
+	// Each replacement keeps its own backup
+	if err := f.Replace(func(w io.Writer) error {
+		_, err := io.WriteString(w, "newer")
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if backups, _ := filepath.Glob(name + ".*.bak"); len(backups) != 2 {
+		t.Errorf("got %d backups after two replacements, want 2", len(backups))
+	}
+
+	// Somebody else edits the file
+	if err := os.WriteFile(name, []byte("other"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	err = f.Replace(func(w io.Writer) error {
+		_, err := io.WriteString(w, "newest")
+		return err
+	})
+	if !errors.Is(err, ErrChanged) {
+		t.Errorf("got error %v, want ErrChanged", err)
+	}
+	if got, _ := os.ReadFile(name); string(got) != "other" {
+		t.Errorf("changed file was overwritten with %q", got)
+	}
+}
+
+func TestFile_Replace_csv(t *testing.T) {
+	data := `Nimi,Mittari,Lukema
+Aho,M1,"1,5"
+###,,
+Päivä,1.7.2022,
+Maksuaika,14,
+Päämittari,"30,00",
+Vesi,"1,525",
+ALV,24,
+Viesti,Hei,
+`
+	name := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(name, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, content, err := Read(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csvf, err := csv.Read(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Replace(func(w io.Writer) error { return csvf.Write(w) }); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, got, err := Read(name); err != nil || string(got) != data {
+		t.Errorf("replaced file contains %q, %v, want %q", got, err, data)
+	}
+}
+
+func TestBackupName(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "data.csv")
+	now := time.Date(2026, 10, 19, 12, 0, 0, 5, time.UTC)
+
+	first, err := backupName(name, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := name + ".20261019T120000.000000005.bak"; first != want {
+		t.Errorf("backup name %q, want %q", first, want)
+	}
+	if err := os.WriteFile(first, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := backupName(name, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := name + ".20261019T120000.000000005.1.bak"; second != want {
+		t.Errorf("backup name %q for a taken name, want %q", second, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "data.csv")
+	write := func(w io.Writer) error {
+		_, err := io.WriteString(w, "new")
+		return err
+	}
+
+	f := New(name)
+	if err := f.Replace(write); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(name); string(got) != "new" {
+		t.Errorf("file contains %q, want new", got)
+	}
+	if backups, _ := filepath.Glob(name + ".*.bak"); len(backups) != 0 {
+		t.Errorf("backups %v of a new file", backups)
+	}
+
+	if err := New(name).Replace(write); !errors.Is(err, ErrChanged) {
+		t.Errorf("got error %v for an existing file, want ErrChanged", err)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/jarnoan/vesimittari/config"
+	"github.com/jarnoan/vesimittari/csv"
+	"github.com/jarnoan/vesimittari/datafile"
+	"github.com/jarnoan/vesimittari/model"
+)
+
+// runExport writes the data as a JSON or YAML dataset to stdout.
+func runExport(cfg config.Config, args []string) error {
+	fs := newFlagSet("export")
+	var format model.Format
+	fs.Var(&format, "format", "format of the dataset: json or yaml")
+	fs.Parse(args)
+
+	csvf, _, err := readData(cfg)
+	if err != nil {
+		return err
+	}
+
+	d, err := csvf.Dataset()
+	if err != nil {
+		return fmt.Errorf("convert data: %w", err)
+	}
+
+	stdout := bufio.NewWriter(os.Stdout)
+	if err := model.Write(stdout, d, format); err != nil {
+		return fmt.Errorf("write dataset: %w", err)
+	}
+
+	return stdout.Flush()
+}
+
+// runImport reads a JSON or YAML dataset from the file given as argument,
+// or from stdin, and writes it as the data.
+func runImport(cfg config.Config, args []string) error {
+	fs := newFlagSet("import")
+	var format model.Format
+	fs.Var(&format, "format", "format of the dataset: json or yaml")
+	fs.Parse(args)
+
+	var r io.Reader = os.Stdin
+	if fs.NArg() > 0 {
+		file, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return fmt.Errorf("open dataset: %w", err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	d, err := model.Read(r, format)
+	if err != nil {
+		return err
+	}
+	csvf := csv.FromDataset(d)
+
+	file, err := createData(cfg)
+	if err != nil {
+		return err
+	}
+
+	if problems := csvf.Validate(); len(problems) > 0 {
+		for _, p := range problems {
+			log.Print(p)
+		}
+		return fmt.Errorf("%d problems found in the dataset", len(problems))
+	}
+
+	return writeData(file, csvf)
+}
+
+// createData returns the data file to be replaced or created by imported
+// data, nil if there is none.
+func createData(cfg config.Config) (*datafile.File, error) {
+	if cfg.Data == "" {
+		return nil, nil
+	}
+
+	file, _, err := datafile.Read(cfg.Data)
+	if errors.Is(err, os.ErrNotExist) {
+		return datafile.New(cfg.Data), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read data: %w", err)
+	}
+
+	return file, nil
+}
@@ -0,0 +1,25 @@
+// Package format formats numbers the Finnish way, with a decimal comma.
+package format
+
+import (
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Amount formats the amount with two decimals and a decimal comma.
+func Amount(d decimal.Decimal) string {
+	return Fixed(d, 2)
+}
+
+// Percent formats the percentage with the decimals it has and a decimal
+// comma, like a VAT rate of 24 or 25,5.
+func Percent(d decimal.Decimal) string {
+	return strings.Replace(d.String(), ".", ",", 1)
+}
+
+// Fixed formats the decimal with the given number of decimals and a
+// decimal comma.
+func Fixed(d decimal.Decimal, places int32) string {
+	return strings.Replace(d.StringFixed(places), ".", ",", 1)
+}
@@ -0,0 +1,38 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestAmount(t *testing.T) {
+	for s, want := range map[string]string{
+		"0":        "0,00",
+		"12.5":     "12,50",
+		"-3.456":   "-3,46",
+		"1234.005": "1234,01",
+	} {
+		if got := Amount(decimal.RequireFromString(s)); got != want {
+			t.Errorf("Amount(%s) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestPercent(t *testing.T) {
+	for s, want := range map[string]string{
+		"24":   "24",
+		"25.5": "25,5",
+		"10.0": "10",
+	} {
+		if got := Percent(decimal.RequireFromString(s)); got != want {
+			t.Errorf("Percent(%s) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestFixed(t *testing.T) {
+	if got := Fixed(decimal.RequireFromString("40"), 1); got != "40,0" {
+		t.Errorf("Fixed(40, 1) = %q, want 40,0", got)
+	}
+}
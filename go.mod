@@ -3,8 +3,13 @@ module github.com/jarnoan/vesimittari
 go 1.18
 
 require (
-	github.com/PuerkitoBio/goquery v1.8.0 // indirect
+	github.com/PuerkitoBio/goquery v1.8.0
+	github.com/mattn/go-sqlite3 v1.14.33
+	github.com/shopspring/decimal v1.3.1
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
 	github.com/andybalholm/cascadia v1.3.1 // indirect
-	github.com/shopspring/decimal v1.3.1 // indirect
 	golang.org/x/net v0.0.0-20210916014120-12bc252f5db8 // indirect
 )
@@ -0,0 +1,127 @@
+package invoice
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	pdfFontSize     = 9
+	pdfLeading      = 11
+	pdfMargin       = 50
+	pdfPageWidth    = 595 // A4 in points
+	pdfPageHeight   = 842
+	pdfLinesPerPage = (pdfPageHeight - 2*pdfMargin) / pdfLeading
+)
+
+// WritePDF writes the plain text as an A4 PDF document. The text is set
+// in a monospaced font so that columns aligned with spaces stay aligned.
+func WritePDF(w io.Writer, text string) error {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+
+	var pages [][]string
+	for len(lines) > pdfLinesPerPage {
+		pages = append(pages, lines[:pdfLinesPerPage])
+		lines = lines[pdfLinesPerPage:]
+	}
+	pages = append(pages, lines)
+
+	// Objects 1-3 are the catalog, the page tree and the font,
+	// each page is followed by its content stream
+	objs := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+	}
+	var kids []string
+	for _, page := range pages {
+		pageObj := len(objs) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageObj))
+		objs = append(objs, fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, pageObj+1))
+
+		content := pageContent(page)
+		objs = append(objs, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+	objs[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+	fmt.Fprint(cw, "%PDF-1.4\n")
+	offsets := make([]int, len(objs))
+	for i, obj := range objs {
+		offsets[i] = cw.n
+		fmt.Fprintf(cw, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := cw.n
+	fmt.Fprintf(cw, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(cw, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(cw, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xref)
+
+	if cw.err != nil {
+		return cw.err
+	}
+
+	return bw.Flush()
+}
+
+// pageContent returns the content stream drawing the lines on a page.
+func pageContent(lines []string) string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", pdfFontSize, pdfLeading, pdfMargin, pdfPageHeight-pdfMargin)
+	for _, l := range lines {
+		fmt.Fprintf(&b, "(%s) Tj T*\n", pdfString(l))
+	}
+	b.WriteString("ET")
+
+	return b.String()
+}
+
+// pdfString encodes the text in WinAnsiEncoding and escapes it for a
+// PDF string literal. Characters that cannot be encoded are replaced.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '€':
+			b.WriteString(`\200`)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, `\%03o`, r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+
+	return b.String()
+}
+
+// countingWriter counts the bytes written for the cross-reference table.
+type countingWriter struct {
+	w   io.Writer
+	n   int
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.err != nil {
+		return 0, cw.err
+	}
+
+	n, err := cw.w.Write(p)
+	cw.n += n
+	cw.err = err
+
+	return n, err
+}
@@ -0,0 +1,27 @@
+package invoice
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWritePDF(t *testing.T) {
+	text := strings.Repeat("Vesimaksu (10 m³) 12,40 €\n", 100)
+
+	var buf bytes.Buffer
+	if err := WritePDF(&buf, text); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "%PDF-1.4\n") || !strings.HasSuffix(out, "%%EOF\n") {
+		t.Errorf("output is not a PDF document")
+	}
+	if !strings.Contains(out, `(Vesimaksu \(10 m\263\) 12,40 \200) Tj`) {
+		t.Errorf("output does not contain the encoded text")
+	}
+	if !strings.Contains(out, "/Count 2") {
+		t.Errorf("output does not have 2 pages")
+	}
+}
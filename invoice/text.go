@@ -0,0 +1,121 @@
+package invoice
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jarnoan/vesimittari/format"
+	"github.com/jarnoan/vesimittari/updater"
+)
+
+// Seller is the sender of the bills.
+type Seller struct {
+	Name       string
+	Address    string
+	BusinessID string
+	IBAN       string
+}
+
+// header returns the lines of the seller that are set.
+func (s Seller) header() string {
+	var res strings.Builder
+	for _, l := range []struct{ label, value string }{
+		{"", s.Name},
+		{"", s.Address},
+		{"Y-tunnus ", s.BusinessID},
+	} {
+		if l.value != "" {
+			fmt.Fprintf(&res, "%s%s\n", l.label, l.value)
+		}
+	}
+
+	return res.String()
+}
+
+// WriteText writes the bill as plain text with a line for each item.
+// The due date is left out if it is zero.
+func WriteText(w io.Writer, s Seller, b updater.Bill, dueDate time.Time) error {
+	title := "Lasku"
+	if b.Total().IsNegative() {
+		title = "Hyvityslasku"
+	}
+
+	if h := s.header(); h != "" {
+		if _, err := fmt.Fprintf(w, "%s\n", h); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "%s\n%s\nViite %s\n", title, b.Name, b.Reference); err != nil {
+		return err
+	}
+	if !dueDate.IsZero() && !b.Total().IsNegative() {
+		if _, err := fmt.Fprintf(w, "Eräpäivä %s\n", dueDate.Format("2.1.2006")); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(w); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "\tMäärä\tA-hinta\tVeroton\tALV %%\tALV\tYhteensä\t\n")
+	for _, l := range b.Lines {
+		qty := ""
+		if l.Unit != "" {
+			qty = fmt.Sprintf("%s %s", format.Amount(l.Quantity), l.Unit)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			l.Description, qty, format.Amount(l.UnitPrice), format.Amount(l.WithoutTax), format.Percent(l.VAT), format.Amount(l.Tax), format.Amount(l.WithTax))
+	}
+	fmt.Fprintf(tw, "Yhteensä\t\t\t\t\t\t%s\t\n", format.Amount(b.Total()))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if s.IBAN != "" && !b.Total().IsNegative() {
+		if _, err := fmt.Fprintf(w, "\nTilinumero %s\n", s.IBAN); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteSummary writes a short plain text summary of the bill for the body
+// of a message that has the bill attached.
+func WriteSummary(w io.Writer, s Seller, b updater.Bill, dueDate time.Time) error {
+	name := s.Name
+	if name == "" {
+		name = "Vesiosuuskunta"
+	}
+
+	if b.Total().IsNegative() {
+		_, err := fmt.Fprintf(w, `Hei,
+
+liitteenä on hyvityslasku %s, %s €.
+
+Ystävällisin terveisin
+%s
+`, b.Reference, format.Amount(b.Total().Neg()), name)
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, `Hei,
+
+liitteenä on vesilaskunne.
+
+Summa     %s €
+Viite     %s
+Eräpäivä  %s
+
+Käyttäkää maksaessanne viitettä.
+
+Ystävällisin terveisin
+%s
+`, format.Amount(b.Total()), b.Reference, dueDate.Format("2.1.2006"), name)
+	return err
+}
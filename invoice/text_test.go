@@ -0,0 +1,34 @@
+package invoice
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+func TestWriteText(t *testing.T) {
+	vat := decimal.NewFromInt(24)
+	b := updater.Bill{
+		Name:      "Virtanen",
+		Reference: "1232",
+		Lines: []updater.BillLine{
+			updater.NewBillLine(updater.WaterFee, "Vesi", decimal.NewFromInt(10), "m³", decimal.NewFromInt(2), vat),
+			updater.NewBillLine(updater.WaterFee, "Jätevesi", decimal.NewFromInt(10), "m³", decimal.NewFromInt(3), vat),
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, Seller{Name: "Vesiosuuskunta", IBAN: "FI21 1234 5600 0007 85"}, b, time.Date(2022, 7, 15, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"Vesiosuuskunta", "Tilinumero FI21 1234 5600 0007 85", "Viite 1232", "Eräpäivä 15.7.2022", "Vesi", "Jätevesi", "24,80", "37,20", "62,00"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
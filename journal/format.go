@@ -0,0 +1,47 @@
+package journal
+
+import (
+	"encoding/csv"
+	"io"
+
+	"github.com/jarnoan/vesimittari/format"
+	"github.com/shopspring/decimal"
+)
+
+// Write writes the entries as a CSV file with separate debit and credit
+// columns.
+func Write(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write([]string{"päivä", "tosite", "tili", "selite", "alv", "debet", "kredit"}); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		row := []string{e.Date.Format("2.1.2006"), string(e.Voucher), e.Account, e.Description,
+			vat(e), optionalAmount(e.Debit), optionalAmount(e.Credit)}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// optionalAmount formats the amount, leaving zero empty.
+func optionalAmount(d decimal.Decimal) string {
+	if d.IsZero() {
+		return ""
+	}
+
+	return format.Amount(d)
+}
+
+// vat formats the VAT percentage of a revenue entry with a decimal comma.
+func vat(e Entry) string {
+	if !e.Revenue {
+		return ""
+	}
+
+	return format.Percent(e.VAT)
+}
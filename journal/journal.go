@@ -0,0 +1,130 @@
+package journal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jarnoan/vesimittari/reference"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+// Accounts contains the account numbers of the journal entries.
+type Accounts struct {
+	Receivables     string // trade receivables
+	WaterSales      string // water fees
+	BasicFees       string // basic fees
+	AdditionalCosts string // additional costs passed through to the members
+	OtherRevenue    string // other fees, like corrections of the total
+	VATPayable      string // VAT payable
+}
+
+// DefaultAccounts are the accounts of a typical Finnish chart of accounts.
+var DefaultAccounts = Accounts{
+	Receivables:     "1700",
+	WaterSales:      "3000",
+	BasicFees:       "3010",
+	AdditionalCosts: "3020",
+	OtherRevenue:    "3090",
+	VATPayable:      "2939",
+}
+
+// Set sets the account of the named entry. The names are receivables,
+// water, basic, additional, other and vat.
+func (a *Accounts) Set(name, account string) error {
+	dst := map[string]*string{
+		"receivables": &a.Receivables,
+		"water":       &a.WaterSales,
+		"basic":       &a.BasicFees,
+		"additional":  &a.AdditionalCosts,
+		"other":       &a.OtherRevenue,
+		"vat":         &a.VATPayable,
+	}[name]
+	if dst == nil {
+		return fmt.Errorf("unknown account %q", name)
+	}
+	*dst = account
+
+	return nil
+}
+
+// revenue returns the revenue account of the kind of bill line.
+func (a Accounts) revenue(k updater.LineKind) string {
+	switch k {
+	case updater.WaterFee:
+		return a.WaterSales
+	case updater.BasicFee:
+		return a.BasicFees
+	case updater.AdditionalFee:
+		return a.AdditionalCosts
+	default:
+		return a.OtherRevenue
+	}
+}
+
+// Entry is a line of a journal voucher. Only one of Debit and Credit is
+// non-zero.
+type Entry struct {
+	Date        time.Time
+	Voucher     reference.Number // reference of the bill
+	Account     string
+	Description string
+	Revenue     bool            // entry of a revenue account
+	VAT         decimal.Decimal // % of a revenue entry
+	Debit       decimal.Decimal
+	Credit      decimal.Decimal
+}
+
+// Amount returns the debit as positive and the credit as negative.
+func (e Entry) Amount() decimal.Decimal {
+	return e.Debit.Sub(e.Credit)
+}
+
+// Entries returns a voucher for each bill. The total of the bill is debited
+// to the receivables and credited to the revenue accounts without tax, one
+// entry per kind of fee and VAT rate, and to the VAT payable. A credit note
+// reverses the sides.
+func Entries(bills []updater.Bill, date time.Time, a Accounts) []Entry {
+	var res []Entry
+	for _, b := range bills {
+		entry := func(account, desc string, revenue bool, vat, amount decimal.Decimal) {
+			if amount.IsZero() {
+				return
+			}
+			e := Entry{Date: date, Voucher: b.Reference, Account: account, Description: desc, Revenue: revenue, VAT: vat}
+			if amount.IsPositive() {
+				e.Debit = amount
+			} else {
+				e.Credit = amount.Neg()
+			}
+			res = append(res, e)
+		}
+
+		entry(a.Receivables, fmt.Sprintf("Lasku %s %s", b.Reference, b.Name), false, decimal.Zero, b.Total())
+
+		type key struct {
+			account string
+			vat     string
+		}
+		var keys []key
+		sums := make(map[key]decimal.Decimal)
+		vats := make(map[key]decimal.Decimal)
+		var tax decimal.Decimal
+		for _, l := range b.Lines {
+			k := key{a.revenue(l.Kind), l.VAT.String()}
+			if _, ok := sums[k]; !ok {
+				keys = append(keys, k)
+				vats[k] = l.VAT
+			}
+			sums[k] = sums[k].Add(l.WithoutTax)
+			tax = tax.Add(l.Tax)
+		}
+		for _, k := range keys {
+			entry(k.account, fmt.Sprintf("Lasku %s %s", b.Reference, b.Name), true, vats[k], sums[k].Neg())
+		}
+
+		entry(a.VATPayable, fmt.Sprintf("Lasku %s ALV", b.Reference), false, decimal.Zero, tax.Neg())
+	}
+
+	return res
+}
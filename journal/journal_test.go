@@ -0,0 +1,64 @@
+package journal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+func TestEntries(t *testing.T) {
+	vat := decimal.NewFromInt(24)
+	bills := []updater.Bill{
+		{Name: "Virtanen", Reference: "1232", Lines: []updater.BillLine{
+			updater.NewBillLine(updater.WaterFee, "Vesi", decimal.NewFromInt(10), "m³", decimal.NewFromInt(2), vat),
+			updater.NewBillLine(updater.WaterFee, "Jätevesi", decimal.NewFromInt(10), "m³", decimal.NewFromInt(3), vat),
+			updater.NewBillLine(updater.AdditionalFee, "Vakuutus", decimal.NewFromInt(1), "", decimal.NewFromInt(10), decimal.Zero),
+		}},
+		{Name: "Aho", Reference: "1245", Lines: []updater.BillLine{
+			updater.NewBillLine(updater.WaterFee, "Vesi", decimal.NewFromInt(-5), "m³", decimal.NewFromInt(2), vat),
+		}},
+	}
+	date := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	entries := Entries(bills, date, DefaultAccounts)
+
+	want := []struct {
+		account string
+		amount  string
+	}{
+		{"1700", "72"},
+		{"3000", "-50"},
+		{"3020", "-10"},
+		{"2939", "-12"},
+		{"1700", "-12.4"},
+		{"3000", "10"},
+		{"2939", "2.4"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+
+	var sum decimal.Decimal
+	for i, w := range want {
+		e := entries[i]
+		if e.Account != w.account || e.Amount().String() != w.amount {
+			t.Errorf("entry %d: got %s %s, want %s %s", i, e.Account, e.Amount(), w.account, w.amount)
+		}
+		sum = sum.Add(e.Amount())
+	}
+	if !sum.IsZero() {
+		t.Errorf("debits and credits differ by %s", sum)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, entries); err != nil {
+		t.Fatal(err)
+	}
+	if want := "1.7.2022,1232,3000,Lasku 1232 Virtanen,24,,\"50,00\"\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("output does not contain %q:\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,112 @@
+package mailer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Log records the messages sent to each member so that a bill is not sent
+// twice. The log is a CSV file with the columns time, reference, name,
+// email and result.
+type Log struct {
+	f    *os.File
+	w    *csv.Writer
+	sent map[string]bool
+}
+
+// OpenLog opens the log file for appending, creating it if necessary.
+func OpenLog(name string) (*Log, error) {
+	sent, err := readLog(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open send log: %w", err)
+	}
+
+	return &Log{f: f, w: csv.NewWriter(f), sent: sent}, nil
+}
+
+// ReadLog reads the log file without opening it for writing, like for a
+// dry run. Nothing can be recorded to the log.
+func ReadLog(name string) (*Log, error) {
+	sent, err := readLog(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Log{sent: sent}, nil
+}
+
+// readLog returns the references of the bills sent successfully according
+// to the log file, none if it does not exist.
+func readLog(name string) (map[string]bool, error) {
+	sent := make(map[string]bool)
+
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return sent, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open send log: %w", err)
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 5
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read send log: %w", err)
+		}
+		if rec[4] == "ok" {
+			sent[rec[1]] = true
+		}
+	}
+
+	return sent, nil
+}
+
+// Sent tells whether the bill with the reference has been sent successfully.
+func (l *Log) Sent(ref string) bool {
+	return l.sent[ref]
+}
+
+// Record adds the result of sending the bill to the log.
+func (l *Log) Record(ref, name, email string, sendErr error) error {
+	if l.w == nil {
+		return fmt.Errorf("send log is read-only")
+	}
+
+	result := "ok"
+	if sendErr != nil {
+		result = sendErr.Error()
+	} else {
+		l.sent[ref] = true
+	}
+
+	if err := l.w.Write([]string{time.Now().Format(time.RFC3339), ref, name, email, result}); err != nil {
+		return fmt.Errorf("write send log: %w", err)
+	}
+	l.w.Flush()
+
+	return l.w.Error()
+}
+
+// Close closes the log file.
+func (l *Log) Close() error {
+	if l.f == nil {
+		return nil
+	}
+
+	return l.f.Close()
+}
@@ -0,0 +1,127 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"time"
+
+	"github.com/jarnoan/vesimittari/contact"
+)
+
+// Config contains the settings of the SMTP server.
+type Config struct {
+	Addr     string // host:port
+	Username string // no authentication if empty
+	Password string
+	From     string // sender address
+}
+
+// Message is an email message with an optional attachment.
+type Message struct {
+	To             string
+	Subject        string
+	Body           string // plain text
+	Attachment     []byte
+	AttachmentName string
+	AttachmentType string // MIME type of the attachment
+}
+
+// Mailer sends messages through an SMTP server.
+type Mailer struct {
+	cfg Config
+}
+
+// New constructs a new mailer.
+func New(cfg Config) *Mailer {
+	return &Mailer{cfg}
+}
+
+// Send sends the message. It refuses to send to an invalid address, so
+// that the address cannot add headers to the message.
+func (m *Mailer) Send(msg Message) error {
+	to, err := contact.NormalizeEmail(msg.To)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+
+	data, err := msg.bytes(m.cfg.From, time.Now())
+	if err != nil {
+		return fmt.Errorf("build message: %w", err)
+	}
+
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		host, _, err := net.SplitHostPort(m.cfg.Addr)
+		if err != nil {
+			return fmt.Errorf("parse server address: %w", err)
+		}
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, host)
+	}
+
+	if err := smtp.SendMail(m.cfg.Addr, auth, m.cfg.From, []string{msg.To}, data); err != nil {
+		return fmt.Errorf("send mail to %s: %w", msg.To, err)
+	}
+
+	return nil
+}
+
+// bytes returns the message in MIME format.
+func (msg Message) bytes(from string, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+	tw, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	qw := quotedprintable.NewWriter(tw)
+	if _, err := qw.Write([]byte(msg.Body)); err != nil {
+		return nil, err
+	}
+	if err := qw.Close(); err != nil {
+		return nil, err
+	}
+
+	if msg.Attachment != nil {
+		aw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {msg.AttachmentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": msg.AttachmentName})},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// Base64 lines must not exceed 76 characters
+		enc := base64.StdEncoding.EncodeToString(msg.Attachment)
+		for len(enc) > 76 {
+			fmt.Fprintf(aw, "%s\r\n", enc[:76])
+			enc = enc[76:]
+		}
+		fmt.Fprintf(aw, "%s\r\n", enc)
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,162 @@
+package mailer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeServer accepts a single SMTP session and returns the received data.
+func fakeServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	data := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { fmt.Fprintf(conn, "%s\r\n", s) }
+		reply("220 localhost")
+
+		var body strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					body.WriteString(l)
+				}
+				reply("250 ok")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				data <- body.String()
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), data
+}
+
+func TestSend(t *testing.T) {
+	addr, data := fakeServer(t)
+
+	m := New(Config{Addr: addr, From: "vesiosuuskunta@example.com"})
+	err := m.Send(Message{
+		To:             "jäsen@example.com",
+		Subject:        "Vesilasku",
+		Body:           "Eräpäivä 15.1.2024",
+		Attachment:     []byte("%PDF-1.4"),
+		AttachmentName: "lasku.pdf",
+		AttachmentType: "application/pdf",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := <-data
+	for _, want := range []string{
+		"To: jäsen@example.com",
+		"Subject: Vesilasku",
+		"Er=C3=A4p=C3=A4iv=C3=A4 15.1.2024",
+		"filename=lasku.pdf",
+		"JVBERi0xLjQ=",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("message does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestLog(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "sent.csv")
+
+	l, err := OpenLog(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Record("1232", "Matti", "matti@example.com", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Record("1245", "Maija", "maija@example.com", errors.New("refused")); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	l, err = OpenLog(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if !l.Sent("1232") {
+		t.Error("1232 not sent")
+	}
+	if l.Sent("1245") {
+		t.Error("failed 1245 sent")
+	}
+
+	r, err := ReadLog(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if !r.Sent("1232") || r.Sent("1245") {
+		t.Error("read-only log differs")
+	}
+	if err := r.Record("1258", "Pekka", "pekka@example.com", nil); err == nil {
+		t.Error("recorded to a read-only log")
+	}
+}
+
+func TestSend_invalidAddress(t *testing.T) {
+	addr, data := fakeServer(t)
+
+	m := New(Config{Addr: addr, From: "vesiosuuskunta@example.com"})
+	for _, to := range []string{
+		"",
+		"jäsen",
+		"jäsen@example.com\r\nBcc: muut@example.com",
+		"Jäsen <jäsen@example.com>",
+	} {
+		if err := m.Send(Message{To: to, Subject: "Vesilasku"}); err == nil {
+			t.Errorf("sent to %q", to)
+		}
+	}
+
+	select {
+	case got := <-data:
+		t.Errorf("message sent:\n%s", got)
+	default:
+	}
+}
@@ -2,14 +2,22 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"sort"
+	"strings"
 	"time"
+	"unicode"
 
+	"github.com/jarnoan/vesimittari/config"
 	"github.com/jarnoan/vesimittari/csv"
-	"github.com/jarnoan/vesimittari/scraper"
+	"github.com/jarnoan/vesimittari/datafile"
+	"github.com/jarnoan/vesimittari/invoice"
+	"github.com/jarnoan/vesimittari/journal"
 	"github.com/jarnoan/vesimittari/updater"
 )
 
@@ -19,44 +27,185 @@ type CounterData struct {
 	Customer string
 }
 
+// command is a subcommand of the program.
+type command struct {
+	usage string
+	run   func(cfg config.Config, args []string) error
+}
+
+var commands = map[string]command{
+	"read":      {"read the meters and add the readings to the data", runRead},
+	"bill":      {"bill the members with the latest readings", runBill},
+	"handover":  {"hand a meter site over to a new owner", runHandover},
+	"exchange":  {"replace the meter of a member", runExchange},
+	"correct":   {"correct a bill that has already been sent", runCorrect},
+	"validate":  {"check the data for errors", runValidate},
+	"export":    {"write the data as a JSON or YAML dataset", runExport},
+	"import":    {"read the data from a JSON or YAML dataset", runImport},
+	"db-import": {"copy the data into the database", runDBImport},
+	"db-export": {"copy the data from the database", runDBExport},
+	"serve":     {"serve the database as a JSON API and a dashboard", runServe},
+	"invoice":   {"write or email the invoices of the latest bills", runInvoice},
+	"report":    {"write reports of the billing runs", runReport},
+	"reconcile": {"match payments to the bills and write reminders", runReconcile},
+}
+
 func main() {
-	var (
-		opts        updater.Options
-		addCostsCSV string
-	)
-	flag.StringVar(&addCostsCSV, "add", "", "additional costs CSV file")
-	flag.BoolVar(&opts.UpdateMeterReadings, "meter", true, "update meter readings")
-	flag.BoolVar(&opts.Verbose, "v", true, "log verbosely")
-	flag.Parse()
-
-	acs, err := additionalCosts(addCostsCSV)
-	if err != nil {
-		log.Fatalf("read additional costs csv: %s", err)
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	fs.Usage = usage
+	cfgFile := fs.String("config", "vesimittari.yaml", "configuration file, also VESIMITTARI_CONFIG")
+	dataFile := fs.String("data", "", "data file to edit in place instead of reading stdin and writing stdout")
+	dbFile := fs.String("db", "", "SQLite database file, also VESIMITTARI_DATABASE")
+	fs.Parse(os.Args[1:])
+
+	// The default configuration file is optional but a given one is not
+	required := false
+	fs.Visit(func(f *flag.Flag) { required = required || f.Name == "config" })
+	if env, ok := os.LookupEnv("VESIMITTARI_CONFIG"); ok && !required {
+		*cfgFile = env
+		required = true
 	}
 
-	csvf, err := csv.Read(os.Stdin)
+	cfg, err := config.Load(*cfgFile, required)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if *dataFile != "" {
+		cfg.Data = *dataFile
+	}
+	if *dbFile != "" {
+		cfg.Database = *dbFile
+	}
 
-	scr := scraper.New()
-	upd := updater.New(scr, opts)
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
 
-	if err := upd.Update(csvf, acs); err != nil {
-		log.Fatal(err)
+	if err := cmd.run(cfg, fs.Args()[1:]); err != nil {
+		log.Fatalf("%s: %s", fs.Arg(0), err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-config file] [-data file] [-db file] <command> [flags]\n\nCommands:\n", os.Args[0])
+
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nWithout a data file the data is read from stdin and commands that change it write it to stdout.\n")
+	fmt.Fprintf(os.Stderr, "A data file is replaced atomically and its previous version is kept as a timestamped backup.\n")
+	fmt.Fprintf(os.Stderr, "The settings of the configuration file are overridden by the environment variables and the flags.\n")
+}
+
+// newFlagSet returns the flag set of the command.
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ExitOnError)
+}
+
+// updaterFlags defines the flags of the updater options with the
+// defaults from the configuration.
+func updaterFlags(fs *flag.FlagSet, opts *updater.Options, cfg config.Config) {
+	opts.MonthConvention = cfg.MonthConvention()
+	opts.WaterPrices = cfg.WaterPrices()
+	opts.VAT = cfg.Billing.VAT
+	opts.MainMeterFee = cfg.Billing.MainMeterFee
+
+	fs.IntVar(&opts.MeterDigits, "digits", cfg.Billing.Digits, "number of digits in meter counters for detecting wrap-around")
+	fs.BoolVar(&opts.Verbose, "v", true, "log verbosely")
+	fs.Var(&opts.MonthConvention, "months", "month convention for basic fee proration: actual/365 or actual/360")
+}
+
+// seller returns the seller of the bills from the configuration.
+func seller(cfg config.Config) invoice.Seller {
+	return invoice.Seller{
+		Name:       cfg.Seller.Name,
+		Address:    cfg.Seller.Address,
+		BusinessID: cfg.Seller.BusinessID,
+		IBAN:       cfg.Seller.IBAN,
+	}
+}
+
+// accounts returns the journal accounts from the CSV file if given,
+// otherwise from the configuration.
+func accounts(cfg config.Config, filename string) (journal.Accounts, error) {
+	if filename == "" {
+		return cfg.JournalAccounts(), nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return journal.Accounts{}, fmt.Errorf("open %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	res, err := csv.ReadAccounts(file)
+	if err != nil {
+		return journal.Accounts{}, fmt.Errorf("read %s: %w", filename, err)
+	}
+
+	return res, nil
+}
+
+// dateFlag defines a flag for a date in the format d.m.yyyy.
+func dateFlag(fs *flag.FlagSet, t *time.Time, name, usage string) {
+	fs.Func(name, usage+" (d.m.yyyy)", func(s string) (err error) {
+		*t, err = time.Parse("2.1.2006", s)
+		return err
+	})
+}
+
+// readData reads the data from the configured data file, or from stdin if
+// there is none. The file is nil when reading stdin.
+func readData(cfg config.Config) (*csv.CSVFile, *datafile.File, error) {
+	if cfg.Data == "" {
+		res, err := csv.Read(os.Stdin)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read data: %w", err)
+		}
+		return res, nil, nil
+	}
+
+	file, content, err := datafile.Read(cfg.Data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read data: %w", err)
+	}
+
+	res, err := csv.Read(bytes.NewReader(content))
+	if err != nil {
+		return nil, nil, fmt.Errorf("read %s: %w", cfg.Data, err)
+	}
+
+	return res, file, nil
+}
+
+// writeData replaces the data file with the data, or writes the data to
+// stdout if there is no file.
+func writeData(file *datafile.File, csvf *csv.CSVFile) error {
+	if file != nil {
+		if err := file.Replace(func(w io.Writer) error { return csvf.Write(w) }); err != nil {
+			return fmt.Errorf("write data: %w", err)
+		}
+		return nil
 	}
 
-	// Write the new data
 	stdout := bufio.NewWriter(os.Stdout)
-	defer stdout.Flush()
 	if err := csvf.Write(stdout); err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("write data: %w", err)
 	}
+
+	return stdout.Flush()
 }
 
-func additionalCosts(filename string) ([]updater.AdditionalCost, error) {
+func readCSV(filename string) (*csv.CSVFile, error) {
 	if filename == "" {
-		return nil, nil
+		return nil, fmt.Errorf("no file given")
 	}
 
 	file, err := os.Open(filename)
@@ -65,10 +214,77 @@ func additionalCosts(filename string) ([]updater.AdditionalCost, error) {
 	}
 	defer file.Close()
 
-	res, err := csv.ReadAdditionalCosts(file)
+	res, err := csv.Read(file)
 	if err != nil {
 		return nil, fmt.Errorf("read %s: %w", filename, err)
 	}
 
 	return res, nil
 }
+
+// dueDate returns the due date of the bills of the latest billing.
+func dueDate(csvf *csv.CSVFile) (time.Time, error) {
+	billDate, err := csvf.Date()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return dueDateFrom(csvf, billDate)
+}
+
+// billDueDate returns the due date of the latest bill of the row. A bill
+// written before the dates of the bills were kept is taken as issued on
+// the billing date.
+func billDueDate(csvf *csv.CSVFile, r *csv.MeterRow) (time.Time, error) {
+	date, err := r.BillDate()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("get bill date of %s: %w", r.Name(), err)
+	}
+	if date.IsZero() {
+		return dueDate(csvf)
+	}
+
+	return dueDateFrom(csvf, date)
+}
+
+// dueDateFrom returns the due date of a bill sent on the bill date.
+func dueDateFrom(csvf *csv.CSVFile, billDate time.Time) (time.Time, error) {
+	paymentDays, err := csvf.PaymentDays()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return billDate.AddDate(0, 0, paymentDays), nil
+}
+
+// recordBills returns the latest bills of the records that have any lines.
+func recordBills(d updater.Data) ([]updater.Bill, error) {
+	mrs, err := d.MeterRecords()
+	if err != nil {
+		return nil, fmt.Errorf("read meter records: %w", err)
+	}
+
+	var res []updater.Bill
+	for _, mr := range mrs {
+		b, err := mr.Bill()
+		if err != nil {
+			return nil, fmt.Errorf("get bill of %s: %w", mr.Name(), err)
+		}
+		if len(b.Lines) > 0 {
+			res = append(res, b)
+		}
+	}
+
+	return res, nil
+}
+
+// fileName returns the name with the characters other than letters and
+// digits replaced so that it can be used in a file name.
+func fileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+}
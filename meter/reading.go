@@ -1,9 +1,63 @@
 package meter
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type Reading struct {
 	Counter  int
 	Date     time.Time
 	Customer string
+	Digits   int // number of digits in the counter, 0 if unknown
+}
+
+// Exchange describes a replacement of a water meter.
+type Exchange struct {
+	Date         time.Time
+	OldNumber    Number // number of the old meter, set when the exchange is recorded
+	FinalCounter int    // last counter of the old meter
+	NewNumber    Number // number of the new meter
+	StartCounter int    // counter of the new meter when installed
+	Digits       int    // number of digits in the counters, 0 if unknown
+}
+
+// Consumption returns the consumption from the counter of the old meter
+// to the counter of the new meter: the consumption of the old meter up to
+// the final counter and that of the new meter from the start counter.
+func (ex Exchange) Consumption(prev, cur, digits int) (int, error) {
+	old, err := Consumption(prev, ex.FinalCounter, digits)
+	if err != nil {
+		return 0, fmt.Errorf("old meter %s: %w", ex.OldNumber, err)
+	}
+
+	cons, err := Consumption(ex.StartCounter, cur, digits)
+	if err != nil {
+		return 0, fmt.Errorf("new meter %s: %w", ex.NewNumber, err)
+	}
+
+	return old + cons, nil
+}
+
+// Consumption returns the consumption between two counter values.
+// A counter with a fixed number of digits wraps around to zero after
+// all nines, so a decrease is taken as a wrap-around if digits is known.
+func Consumption(prev, cur, digits int) (int, error) {
+	if cur >= prev {
+		return cur - prev, nil
+	}
+
+	if digits <= 0 {
+		return 0, fmt.Errorf("counter decreased from %d to %d", prev, cur)
+	}
+
+	limit := 1
+	for i := 0; i < digits; i++ {
+		limit *= 10
+	}
+	if prev >= limit {
+		return 0, fmt.Errorf("counter %d has more than %d digits", prev, digits)
+	}
+
+	return cur + limit - prev, nil
 }
@@ -0,0 +1,46 @@
+package meter
+
+import "testing"
+
+func TestConsumption(t *testing.T) {
+	tests := []struct {
+		name      string
+		prev, cur int
+		digits    int
+		want      int
+		wantErr   bool
+	}{
+		{"increase", 100, 120, 0, 20, false},
+		{"no change", 100, 100, 5, 0, false},
+		{"decrease", 100, 90, 0, 0, true},
+		{"wrap-around", 99990, 15, 5, 25, false},
+		{"too many digits", 123456, 15, 5, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Consumption(tt.prev, tt.cur, tt.digits)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Consumption() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Consumption() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchange_Consumption(t *testing.T) {
+	ex := Exchange{OldNumber: "M1", FinalCounter: 99995, NewNumber: "M2", StartCounter: 3}
+
+	got, err := ex.Consumption(99990, 10, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 12 {
+		t.Errorf("Consumption() = %d, want 12", got)
+	}
+
+	if _, err := ex.Consumption(99990, 2, 0); err == nil {
+		t.Error("Consumption() below the start counter succeeded")
+	}
+}
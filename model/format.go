@@ -0,0 +1,79 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the file format of a dataset.
+// It implements flag.Value.
+type Format int
+
+const (
+	JSON Format = iota
+	YAML
+)
+
+var formatNames = map[Format]string{
+	JSON: "json",
+	YAML: "yaml",
+}
+
+func (f Format) String() string {
+	return formatNames[f]
+}
+
+// Set parses the format from its name.
+func (f *Format) Set(s string) error {
+	for format, name := range formatNames {
+		if name == s {
+			*f = format
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown format %q", s)
+}
+
+// Write writes the dataset in the format.
+func Write(w io.Writer, d Dataset, f Format) error {
+	if f == YAML {
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(d); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(d)
+}
+
+// Read reads a dataset in the format. Unknown fields are errors so that
+// misspelt names are not silently ignored.
+func Read(r io.Reader, f Format) (Dataset, error) {
+	var res Dataset
+
+	if f == YAML {
+		dec := yaml.NewDecoder(r)
+		dec.KnownFields(true)
+		if err := dec.Decode(&res); err != nil {
+			return Dataset{}, fmt.Errorf("parse dataset: %w", err)
+		}
+		return res, nil
+	}
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&res); err != nil {
+		return Dataset{}, fmt.Errorf("parse dataset: %w", err)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,164 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Dataset is the full data of the cooperative in a form that does not
+// depend on the column layout of the data file.
+type Dataset struct {
+	Header     []string   `json:"header,omitempty" yaml:"header,omitempty"` // column names of the data file
+	Members    []Member   `json:"members" yaml:"members"`
+	Parameters Parameters `json:"parameters" yaml:"parameters"`
+}
+
+// Member is a row of the data file: a member with the meter and the
+// latest bill, or a correction bill of a member.
+type Member struct {
+	Name               string `json:"name" yaml:"name"`
+	BankAccount        string `json:"bank_account,omitempty" yaml:"bank_account,omitempty"`
+	Phone              string `json:"phone,omitempty" yaml:"phone,omitempty"`
+	Email              string `json:"email,omitempty" yaml:"email,omitempty"`
+	StreetAddress      string `json:"street_address,omitempty" yaml:"street_address,omitempty"`
+	PostalCode         string `json:"postal_code,omitempty" yaml:"postal_code,omitempty"`
+	City               string `json:"city,omitempty" yaml:"city,omitempty"`
+	PropertyID         string `json:"property_id,omitempty" yaml:"property_id,omitempty"`
+	Tenants            string `json:"tenants,omitempty" yaml:"tenants,omitempty"`
+	PermanentResidency string `json:"permanent_residency,omitempty" yaml:"permanent_residency,omitempty"`
+	JoinDate           *Date  `json:"join_date,omitempty" yaml:"join_date,omitempty"`
+	LeaveDate          *Date  `json:"leave_date,omitempty" yaml:"leave_date,omitempty"`
+	Meter              *Meter `json:"meter,omitempty" yaml:"meter,omitempty"`
+	Bill               *Bill  `json:"bill,omitempty" yaml:"bill,omitempty"`
+}
+
+// Meter is the water meter of a member with its two latest readings.
+type Meter struct {
+	Site            string    `json:"site,omitempty" yaml:"site,omitempty"`
+	Number          string    `json:"number,omitempty" yaml:"number,omitempty"`
+	PreviousReading *Reading  `json:"previous_reading,omitempty" yaml:"previous_reading,omitempty"`
+	Reading         *Reading  `json:"reading,omitempty" yaml:"reading,omitempty"`
+	Consumption     *int      `json:"consumption,omitempty" yaml:"consumption,omitempty"` // m³ between the readings
+	Exchange        *Exchange `json:"exchange,omitempty" yaml:"exchange,omitempty"`       // latest replacement of the meter
+}
+
+// Exchange is a replacement of a meter. The number of the new meter is
+// the number of the meter.
+type Exchange struct {
+	Date         Date   `json:"date" yaml:"date"`
+	OldNumber    string `json:"old_number" yaml:"old_number"`
+	FinalCounter int    `json:"final_counter" yaml:"final_counter"` // of the old meter
+	StartCounter int    `json:"start_counter" yaml:"start_counter"` // of the new meter
+}
+
+// Reading is a reading of a meter.
+type Reading struct {
+	Counter int    `json:"counter" yaml:"counter"`
+	Date    *Date  `json:"date,omitempty" yaml:"date,omitempty"`
+	Check   string `json:"check,omitempty" yaml:"check,omitempty"` // reading reported by the member
+}
+
+// Bill is the latest bill of a member. The amounts are in euros. The
+// optional decimals are null in YAML, as omitempty would drop zeros.
+type Bill struct {
+	Reference       string           `json:"reference,omitempty" yaml:"reference,omitempty"`
+	Date            *Date            `json:"date,omitempty" yaml:"date,omitempty"` // issued, nil if not known
+	Months          *decimal.Decimal `json:"months,omitempty" yaml:"months"`       // of basic fee
+	WaterFee        *Amount          `json:"water_fee,omitempty" yaml:"water_fee,omitempty"`
+	BasicFee        *Amount          `json:"basic_fee,omitempty" yaml:"basic_fee,omitempty"`
+	WaterLines      []Line           `json:"water_lines,omitempty" yaml:"water_lines,omitempty"` // itemised water fee
+	BasicLines      []Line           `json:"basic_lines,omitempty" yaml:"basic_lines,omitempty"` // itemised basic fee
+	AdditionalCosts []Cost           `json:"additional_costs,omitempty" yaml:"additional_costs,omitempty"`
+	AdditionalTotal *decimal.Decimal `json:"additional_total,omitempty" yaml:"additional_total"` // with tax
+	Total           *decimal.Decimal `json:"total,omitempty" yaml:"total"`
+}
+
+// Amount is a billed amount with and without tax.
+type Amount struct {
+	WithoutTax decimal.Decimal `json:"without_tax" yaml:"without_tax"`
+	Tax        decimal.Decimal `json:"tax" yaml:"tax"`
+	WithTax    decimal.Decimal `json:"with_tax" yaml:"with_tax"`
+}
+
+// Line is an itemised line of the water fee or the basic fee of a bill.
+type Line struct {
+	Description string          `json:"description" yaml:"description"`
+	Quantity    decimal.Decimal `json:"quantity" yaml:"quantity"`
+	Unit        string          `json:"unit" yaml:"unit"`
+	UnitPrice   decimal.Decimal `json:"unit_price" yaml:"unit_price"` // € without tax
+	VAT         decimal.Decimal `json:"vat" yaml:"vat"`               // %
+	Amount      `yaml:",inline"`
+}
+
+// Cost is an additional cost on a bill.
+type Cost struct {
+	Description string          `json:"description" yaml:"description"`
+	VAT         decimal.Decimal `json:"vat" yaml:"vat"` // %
+	Amount      `yaml:",inline"`
+}
+
+// Parameters are the parameters of the billing.
+type Parameters struct {
+	Date         Date            `json:"date" yaml:"date"` // billing date
+	PaymentDays  int             `json:"payment_days" yaml:"payment_days"`
+	MainMeterFee decimal.Decimal `json:"main_meter_fee" yaml:"main_meter_fee"` // € per month without tax
+	WaterPrice   decimal.Decimal `json:"water_price" yaml:"water_price"`       // €/m³ without tax
+	VAT          decimal.Decimal `json:"vat" yaml:"vat"`                       // %
+	VATChange    *VATChange      `json:"vat_change,omitempty" yaml:"vat_change,omitempty"`
+	Message      string          `json:"message" yaml:"message"`
+}
+
+// VATChange is a change of the VAT percentage during the billing period.
+type VATChange struct {
+	Date     Date            `json:"date" yaml:"date"`
+	Previous decimal.Decimal `json:"previous" yaml:"previous"` // %
+}
+
+// Date is a calendar date written like 2022-12-31.
+type Date struct {
+	time.Time
+}
+
+const dateFormat = "2006-01-02"
+
+// NewDate returns a pointer to the date, nil if the time is zero.
+func NewDate(t time.Time) *Date {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &Date{t}
+}
+
+func (d Date) MarshalText() ([]byte, error) {
+	return []byte(d.Format(dateFormat)), nil
+}
+
+func (d *Date) UnmarshalText(text []byte) error {
+	t, err := time.Parse(dateFormat, string(text))
+	if err != nil {
+		return fmt.Errorf("parse date: %w", err)
+	}
+	d.Time = t
+
+	return nil
+}
+
+// MarshalJSON overrides the method of time.Time to write only the date.
+func (d Date) MarshalJSON() ([]byte, error) {
+	text, _ := d.MarshalText()
+	return json.Marshal(string(text))
+}
+
+// UnmarshalJSON overrides the method of time.Time to read only the date.
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("parse date: %w", err)
+	}
+
+	return d.UnmarshalText([]byte(s))
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jarnoan/vesimittari/config"
+	"github.com/jarnoan/vesimittari/contact"
+	"github.com/jarnoan/vesimittari/csv"
+	"github.com/jarnoan/vesimittari/invoice"
+	"github.com/jarnoan/vesimittari/journal"
+	"github.com/jarnoan/vesimittari/mailer"
+	"github.com/jarnoan/vesimittari/payment"
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/jarnoan/vesimittari/report"
+	"github.com/jarnoan/vesimittari/statement"
+	"github.com/jarnoan/vesimittari/updater"
+)
+
+// runInvoice writes or emails the invoices of the latest bills in the data.
+func runInvoice(cfg config.Config, args []string) error {
+	var (
+		textFile string
+		pdfDir   string
+		send     bool
+		smtpCfg  mailer.Config
+		sendLog  string
+		dryRun   bool
+		resend   bool
+	)
+	fs := newFlagSet("invoice")
+	fs.StringVar(&textFile, "text", "", "write the bills as text to this file")
+	fs.StringVar(&pdfDir, "pdf", "", "write the bills as PDF files to this directory")
+	fs.BoolVar(&send, "send", false, "email the bills to the members")
+	addr := cfg.SMTP.Addr
+	if addr == "" {
+		addr = "localhost:25"
+	}
+	from := cfg.SMTP.From
+	if from == "" {
+		from = cfg.Seller.Email
+	}
+	fs.StringVar(&smtpCfg.Addr, "smtp", addr, "SMTP server address (host:port)")
+	fs.StringVar(&smtpCfg.Username, "smtp-user", cfg.SMTP.Username, "SMTP user name, no authentication if empty; the password is read from the configuration")
+	fs.StringVar(&smtpCfg.From, "from", from, "sender address of the emails")
+	fs.StringVar(&sendLog, "send-log", "lahetetyt.csv", "log of sent emails, used to avoid sending a bill twice")
+	fs.BoolVar(&dryRun, "dry-run", false, "only log the emails that would be sent")
+	fs.BoolVar(&resend, "resend", false, "send also the bills that have already been sent")
+	fs.Parse(args)
+
+	csvf, _, err := readData(cfg)
+	if err != nil {
+		return err
+	}
+
+	due, err := dueDate(csvf)
+	if err != nil {
+		return err
+	}
+
+	bills, err := recordBills(csvf)
+	if err != nil {
+		return err
+	}
+
+	if textFile != "" {
+		if err := writeInvoices(textFile, seller(cfg), bills, due); err != nil {
+			return fmt.Errorf("write invoices: %w", err)
+		}
+	}
+
+	if pdfDir != "" {
+		if err := writeInvoicePDFs(pdfDir, seller(cfg), bills, due); err != nil {
+			return fmt.Errorf("write invoices: %w", err)
+		}
+	}
+
+	if send {
+		if smtpCfg.From == "" {
+			return fmt.Errorf("sender address is required for sending")
+		}
+		smtpCfg.Password = cfg.SMTP.Password
+		if err := sendInvoices(csvf, seller(cfg), mailer.New(smtpCfg), sendLog, dryRun, resend); err != nil {
+			return fmt.Errorf("send invoices: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// writeInvoicePDFs writes each bill as a PDF file to the directory.
+func writeInvoicePDFs(dir string, s invoice.Seller, bills []updater.Bill, due time.Time) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for _, b := range bills {
+		var text, pdf bytes.Buffer
+		if err := invoice.WriteText(&text, s, b, due); err != nil {
+			return fmt.Errorf("write bill of %s: %w", b.Name, err)
+		}
+		if err := invoice.WritePDF(&pdf, text.String()); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, "lasku-"+string(b.Reference)+".pdf"), pdf.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runReport writes reports of the billing runs. The data is the latest
+// run and the data files of earlier runs are given as arguments.
+func runReport(cfg config.Config, args []string) error {
+	var (
+		vatFile     string
+		vatPeriod   period.Period
+		stmtDir     string
+		stmtYear    int
+		paymentsCSV string
+		journalFile string
+		accountsCSV string
+	)
+	fs := newFlagSet("report")
+	fs.StringVar(&vatFile, "vat", "", "write the VAT summary of the billing runs to this file")
+	dateFlag(fs, &vatPeriod.Start, "from", "first billing date of the VAT summary, default the billing date of the data")
+	fs.Func("to", "last billing date of the VAT summary (d.m.yyyy)", func(s string) error {
+		t, err := time.Parse("2.1.2006", s)
+		vatPeriod.End = t.AddDate(0, 0, 1)
+		return err
+	})
+	fs.StringVar(&stmtDir, "statements", "", "write annual statements of the members to this directory")
+	fs.IntVar(&stmtYear, "year", time.Now().Year()-1, "year of the annual statements")
+	fs.StringVar(&paymentsCSV, "payments", "", "received payments CSV file (date, reference, amount) for the paid fees of the annual statements")
+	fs.StringVar(&journalFile, "journal", "", "write journal entries of the latest bills to this file for bookkeeping")
+	fs.StringVar(&accountsCSV, "accounts", "", "account numbers of the journal entries CSV file (entry, account)")
+	fs.Parse(args)
+
+	csvf, _, err := readData(cfg)
+	if err != nil {
+		return err
+	}
+
+	if vatFile != "" {
+		if err := writeVATReport(vatFile, vatPeriod, csvf, fs.Args()); err != nil {
+			return fmt.Errorf("write VAT report: %w", err)
+		}
+	}
+
+	if stmtDir != "" {
+		payments, err := readPayments(paymentsCSV)
+		if err != nil {
+			return err
+		}
+		if err := writeStatements(stmtDir, stmtYear, csvf, fs.Args(), payments); err != nil {
+			return fmt.Errorf("write statements: %w", err)
+		}
+	}
+
+	if journalFile != "" {
+		bills, err := recordBills(csvf)
+		if err != nil {
+			return err
+		}
+		a, err := accounts(cfg, accountsCSV)
+		if err != nil {
+			return err
+		}
+		if err := writeJournal(journalFile, a, bills, csvf); err != nil {
+			return fmt.Errorf("write journal: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func writeInvoices(filename string, s invoice.Seller, bills []updater.Bill, due time.Time) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	for _, b := range bills {
+		if err := invoice.WriteText(w, s, b, due); err != nil {
+			return fmt.Errorf("write bill of %s: %w", b.Name, err)
+		}
+		fmt.Fprintln(w)
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("write %s: %w", filename, err)
+	}
+
+	return file.Close()
+}
+
+// sendInvoices emails the latest bill as a PDF to each member with an
+// email address. Bills that are in the send log are skipped unless resend
+// is set.
+func sendInvoices(csvf *csv.CSVFile, s invoice.Seller, m *mailer.Mailer, logFile string, dryRun, resend bool) error {
+	mrs, err := csvf.MeterRecords()
+	if err != nil {
+		return fmt.Errorf("read meter records: %w", err)
+	}
+
+	// A dry run skips the same bills without writing to the log
+	open := mailer.OpenLog
+	if dryRun {
+		open = mailer.ReadLog
+	}
+	sent, err := open(logFile)
+	if err != nil {
+		return err
+	}
+	defer sent.Close()
+
+	for _, mr := range mrs {
+		b, err := mr.Bill()
+		if err != nil {
+			return fmt.Errorf("get bill of %s: %w", mr.Name(), err)
+		}
+		if len(b.Lines) == 0 {
+			continue
+		}
+
+		email := mr.(*csv.MeterRow).Email()
+		if email == "" {
+			log.Printf("no email address for %s, bill %s not sent", b.Name, b.Reference)
+			continue
+		}
+		email, err = contact.NormalizeEmail(email)
+		if err != nil {
+			log.Printf("bill %s of %s not sent: %s", b.Reference, b.Name, err)
+			continue
+		}
+		if sent.Sent(string(b.Reference)) && !resend {
+			log.Printf("bill %s already sent to %s", b.Reference, email)
+			continue
+		}
+
+		due, err := billDueDate(csvf, mr.(*csv.MeterRow))
+		if err != nil {
+			return err
+		}
+
+		var text, body, pdf bytes.Buffer
+		if err := invoice.WriteText(&text, s, b, due); err != nil {
+			return err
+		}
+		if err := invoice.WritePDF(&pdf, text.String()); err != nil {
+			return err
+		}
+		if err := invoice.WriteSummary(&body, s, b, due); err != nil {
+			return err
+		}
+
+		if dryRun {
+			log.Printf("would send bill %s to %s <%s>", b.Reference, b.Name, email)
+			continue
+		}
+
+		sendErr := m.Send(mailer.Message{
+			To:             email,
+			Subject:        fmt.Sprintf("Vesilasku, viite %s", b.Reference),
+			Body:           body.String(),
+			Attachment:     pdf.Bytes(),
+			AttachmentName: fmt.Sprintf("lasku-%s.pdf", b.Reference),
+			AttachmentType: "application/pdf",
+		})
+		if sendErr != nil {
+			log.Printf("send bill %s to %s: %s", b.Reference, email, sendErr)
+		} else {
+			log.Printf("sent bill %s to %s <%s>", b.Reference, b.Name, email)
+		}
+		if err := sent.Record(string(b.Reference), b.Name, email, sendErr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeJournal writes the journal entries of the bills dated on the
+// billing date.
+func writeJournal(filename string, a journal.Accounts, bills []updater.Bill, csvf *csv.CSVFile) error {
+	date, err := csvf.Date()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	if err := journal.Write(file, journal.Entries(bills, date, a)); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// writeStatements writes the annual statements of the members billed
+// during the year. The data and the earlier data files are the billing runs.
+func writeStatements(dir string, year int, csvf *csv.CSVFile, files []string, payments []payment.Payment) error {
+	datas, err := billingRuns(csvf, files)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	var runs []statement.Run
+	for _, data := range datas {
+		run, err := statementRun(data)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, run)
+	}
+
+	names, err := statement.Members(runs, year)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		s, err := statement.Annual(runs, payments, name, year)
+		if err != nil {
+			return err
+		}
+
+		var text bytes.Buffer
+		if err := statement.WriteText(&text, s); err != nil {
+			return err
+		}
+
+		base := filepath.Join(dir, fmt.Sprintf("vuosikooste-%d-%s", year, fileName(name)))
+		if err := os.WriteFile(base+".txt", text.Bytes(), 0o644); err != nil {
+			return err
+		}
+
+		var pdf bytes.Buffer
+		if err := invoice.WritePDF(&pdf, text.String()); err != nil {
+			return err
+		}
+		if err := os.WriteFile(base+".pdf", pdf.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// billingRuns returns the data and the data files of earlier billing runs.
+func billingRuns(csvf *csv.CSVFile, files []string) ([]*csv.CSVFile, error) {
+	res := []*csv.CSVFile{csvf}
+	for _, f := range files {
+		data, err := readCSV(f)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, data)
+	}
+
+	return res, nil
+}
+
+// writeVATReport writes the VAT summary of the billing runs within the
+// period. A zero period covers the billing date of the data only.
+func writeVATReport(filename string, p period.Period, csvf *csv.CSVFile, files []string) error {
+	if p.Start.IsZero() && p.End.IsZero() {
+		date, err := csvf.Date()
+		if err != nil {
+			return err
+		}
+		p = period.New(date, date.AddDate(0, 0, 1))
+	}
+
+	datas, err := billingRuns(csvf, files)
+	if err != nil {
+		return err
+	}
+
+	var runs []report.Run
+	for _, data := range datas {
+		date, err := data.Date()
+		if err != nil {
+			return err
+		}
+		mrs, err := data.MeterRecords()
+		if err != nil {
+			return fmt.Errorf("read meter records: %w", err)
+		}
+		run := report.Run{Date: date}
+		for _, mr := range mrs {
+			run.Records = append(run.Records, mr.(*csv.MeterRow))
+		}
+		runs = append(runs, run)
+	}
+
+	s, err := report.VAT(runs, p)
+	if err != nil {
+		return err
+	}
+	if !s.Difference().IsZero() {
+		log.Printf("VAT summary differs from the billed totals by %s", s.Difference())
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	if err := report.WriteVAT(file, s); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// statementRun returns the billing run of the data for the statements.
+func statementRun(csvf *csv.CSVFile) (statement.Run, error) {
+	date, err := csvf.Date()
+	if err != nil {
+		return statement.Run{}, err
+	}
+
+	mrs, err := csvf.MeterRecords()
+	if err != nil {
+		return statement.Run{}, fmt.Errorf("read meter records: %w", err)
+	}
+
+	res := statement.Run{Date: date}
+	for _, mr := range mrs {
+		res.Records = append(res.Records, mr.(*csv.MeterRow))
+	}
+
+	return res, nil
+}
@@ -0,0 +1,64 @@
+package payment
+
+import (
+	"time"
+
+	"github.com/jarnoan/vesimittari/reference"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+// Payment is a payment received to the bank account of the cooperative.
+type Payment struct {
+	Date      time.Time
+	Reference reference.Number
+	Amount    decimal.Decimal // €
+}
+
+// ByReference groups the payments by their reference numbers.
+func ByReference(ps []Payment) map[reference.Number][]Payment {
+	res := make(map[reference.Number][]Payment)
+	for _, p := range ps {
+		res[p.Reference] = append(res[p.Reference], p)
+	}
+
+	return res
+}
+
+// Balance is the payment status of a bill.
+type Balance struct {
+	Bill     updater.Bill
+	Payments []Payment
+	Paid     decimal.Decimal // €
+}
+
+// Unpaid returns the amount of the bill still unpaid. It is negative if
+// the bill has been overpaid.
+func (b Balance) Unpaid() decimal.Decimal {
+	return b.Bill.Total().Sub(b.Paid)
+}
+
+// Reconcile matches the payments to the bills by reference. It returns the
+// balance of each bill and the payments that match none of the bills.
+func Reconcile(bills []updater.Bill, ps []Payment) ([]Balance, []Payment) {
+	byRef := ByReference(ps)
+
+	var res []Balance
+	for _, b := range bills {
+		bal := Balance{Bill: b, Payments: byRef[b.Reference]}
+		for _, p := range bal.Payments {
+			bal.Paid = bal.Paid.Add(p.Amount)
+		}
+		delete(byRef, b.Reference)
+		res = append(res, bal)
+	}
+
+	var unmatched []Payment
+	for _, p := range ps {
+		if _, ok := byRef[p.Reference]; ok {
+			unmatched = append(unmatched, p)
+		}
+	}
+
+	return res, unmatched
+}
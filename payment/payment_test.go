@@ -0,0 +1,64 @@
+package payment
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jarnoan/vesimittari/reference"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+func TestByReference(t *testing.T) {
+	ps := []Payment{
+		{Reference: "101", Amount: decimal.NewFromInt(10)},
+		{Reference: "102", Amount: decimal.NewFromInt(20)},
+		{Reference: "101", Amount: decimal.NewFromInt(30)},
+	}
+
+	got := ByReference(ps)
+	if len(got) != 2 {
+		t.Fatalf("got %d references, want 2", len(got))
+	}
+	if r := got["101"]; len(r) != 2 || !r[0].Amount.Equal(decimal.NewFromInt(10)) || !r[1].Amount.Equal(decimal.NewFromInt(30)) {
+		t.Errorf("payments of 101 = %+v", r)
+	}
+	if r := got["102"]; len(r) != 1 {
+		t.Errorf("payments of 102 = %+v", r)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	date := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	bill := func(ref string, total int64) updater.Bill {
+		return updater.Bill{Name: ref, Reference: reference.Number("10" + ref), Lines: []updater.BillLine{
+			updater.NewBillLine(updater.WaterFee, "Vesi", decimal.NewFromInt(total), "m³", decimal.NewFromInt(1), decimal.Zero),
+		}}
+	}
+	bills := []updater.Bill{bill("1", 100), bill("2", 50), bill("3", 80)}
+	ps := []Payment{
+		{Date: date, Reference: "101", Amount: decimal.NewFromInt(40)},
+		{Date: date, Reference: "101", Amount: decimal.NewFromInt(20)},
+		{Date: date, Reference: "102", Amount: decimal.NewFromInt(60)},
+		{Date: date, Reference: "109", Amount: decimal.NewFromInt(5)},
+	}
+
+	balances, unmatched := Reconcile(bills, ps)
+	if len(balances) != 3 {
+		t.Fatalf("got %d balances, want 3", len(balances))
+	}
+	for i, want := range []struct {
+		paid, unpaid int64
+	}{{60, 40}, {60, -10}, {0, 80}} {
+		b := balances[i]
+		if !b.Paid.Equal(decimal.NewFromInt(want.paid)) || !b.Unpaid().Equal(decimal.NewFromInt(want.unpaid)) {
+			t.Errorf("balance of %s: paid %s, unpaid %s, want %d and %d", b.Bill.Reference, b.Paid, b.Unpaid(), want.paid, want.unpaid)
+		}
+	}
+	if len(balances[0].Payments) != 2 {
+		t.Errorf("payments of 101 = %+v", balances[0].Payments)
+	}
+	if len(unmatched) != 1 || unmatched[0].Reference != "109" {
+		t.Errorf("unmatched = %+v, want the payment to 109", unmatched)
+	}
+}
@@ -0,0 +1,132 @@
+package period
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Period is a date interval. Start is inclusive and End exclusive,
+// so consecutive meter readings form adjacent periods.
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+// New constructs a new period between the dates.
+func New(start, end time.Time) Period {
+	return Period{Start: start, End: end}
+}
+
+// Days returns the number of calendar days in the period.
+// Time of day and time zone are ignored.
+func (p Period) Days() int {
+	days := int(date(p.End).Sub(date(p.Start)).Hours() / 24)
+	if days < 0 {
+		return 0
+	}
+
+	return days
+}
+
+// Clip returns the part of p that is within q. A zero Start or End in q
+// leaves that end of p unbounded. The result has no days if the periods
+// do not overlap.
+func (p Period) Clip(q Period) Period {
+	res := p
+	if !q.Start.IsZero() && date(q.Start).After(date(res.Start)) {
+		res.Start = q.Start
+	}
+	if !q.End.IsZero() && date(q.End).Before(date(res.End)) {
+		res.End = q.End
+	}
+
+	return res
+}
+
+// Contains tells whether the day of t is within p. A zero Start or End
+// means the period is unbounded at that end.
+func (p Period) Contains(t time.Time) bool {
+	d := date(t)
+	if !p.Start.IsZero() && d.Before(date(p.Start)) {
+		return false
+	}
+	if !p.End.IsZero() && !d.Before(date(p.End)) {
+		return false
+	}
+
+	return true
+}
+
+// Overlaps tells whether the periods have a day in common. A zero Start
+// or End means the period is unbounded at that end.
+func (p Period) Overlaps(q Period) bool {
+	if p.empty() || q.empty() {
+		return false
+	}
+	if !p.End.IsZero() && !q.Start.IsZero() && !date(q.Start).Before(date(p.End)) {
+		return false
+	}
+	if !q.End.IsZero() && !p.Start.IsZero() && !date(p.Start).Before(date(q.End)) {
+		return false
+	}
+
+	return true
+}
+
+// empty tells whether the period is bounded at both ends and has no days.
+func (p Period) empty() bool {
+	return !p.Start.IsZero() && !p.End.IsZero() && !date(p.Start).Before(date(p.End))
+}
+
+// Months returns the length of the period in months using the convention.
+func (p Period) Months(c Convention) decimal.Decimal {
+	return decimal.NewFromInt(int64(p.Days())).Div(c.daysPerMonth())
+}
+
+// date truncates t to midnight UTC of the same calendar day.
+func date(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Convention defines how many days are counted as one month.
+// It implements flag.Value.
+type Convention int
+
+const (
+	// Actual365 counts a month as 365/12 days.
+	Actual365 Convention = iota
+	// Actual360 counts a month as 30 days.
+	Actual360
+)
+
+var conventionNames = map[Convention]string{
+	Actual365: "actual/365",
+	Actual360: "actual/360",
+}
+
+func (c Convention) daysPerMonth() decimal.Decimal {
+	if c == Actual360 {
+		return decimal.NewFromInt(30)
+	}
+
+	return decimal.NewFromInt(365).Div(decimal.NewFromInt(12))
+}
+
+func (c Convention) String() string {
+	return conventionNames[c]
+}
+
+// Set parses the convention from its name.
+func (c *Convention) Set(s string) error {
+	for conv, name := range conventionNames {
+		if name == s {
+			*c = conv
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown month convention %q", s)
+}
@@ -0,0 +1,141 @@
+package period
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestPeriod_Days(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end string
+		days       int
+	}{
+		{"same day", "2022-03-01", "2022-03-01", 0},
+		{"month end", "2022-01-31", "2022-02-01", 1},
+		{"year boundary", "2021-12-15", "2022-01-15", 31},
+		{"leap year", "2024-02-01", "2024-03-01", 29},
+		{"long gap", "2021-01-15", "2022-02-15", 396},
+		{"reversed", "2022-02-01", "2022-01-01", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(mustDate(t, tt.start), mustDate(t, tt.end))
+			if got := p.Days(); got != tt.days {
+				t.Errorf("Days() = %d, want %d", got, tt.days)
+			}
+		})
+	}
+}
+
+func TestPeriod_Months(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end string
+		conv       Convention
+		months     string
+	}{
+		{"one day", "2022-01-31", "2022-02-01", Actual365, "0.03"},
+		{"year boundary", "2021-12-15", "2022-01-15", Actual365, "1.02"},
+		{"full year", "2021-03-01", "2022-03-01", Actual365, "12.00"},
+		{"long gap", "2021-01-15", "2022-02-15", Actual365, "13.02"},
+		{"thirty days", "2022-01-01", "2022-01-31", Actual360, "1.00"},
+		{"long gap 360", "2021-01-15", "2022-02-15", Actual360, "13.20"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(mustDate(t, tt.start), mustDate(t, tt.end))
+			want := decimal.RequireFromString(tt.months)
+			if got := p.Months(tt.conv).Round(2); !got.Equal(want) {
+				t.Errorf("Months(%v) = %v, want %v", tt.conv, got, want)
+			}
+		})
+	}
+}
+
+func TestPeriod_Clip(t *testing.T) {
+	p := New(mustDate(t, "2022-01-01"), mustDate(t, "2022-07-01"))
+	tests := []struct {
+		name       string
+		start, end string
+		days       int
+	}{
+		{"open", "", "", 181},
+		{"joined", "2022-06-01", "", 30},
+		{"left", "", "2022-02-01", 31},
+		{"joined and left", "2022-03-01", "2022-04-01", 31},
+		{"left before", "", "2021-12-01", 0},
+		{"joined after", "2022-08-01", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var q Period
+			if tt.start != "" {
+				q.Start = mustDate(t, tt.start)
+			}
+			if tt.end != "" {
+				q.End = mustDate(t, tt.end)
+			}
+			if got := p.Clip(q).Days(); got != tt.days {
+				t.Errorf("Clip(%v).Days() = %d, want %d", q, got, tt.days)
+			}
+		})
+	}
+}
+
+func TestPeriod_Overlaps(t *testing.T) {
+	p := New(mustDate(t, "2022-01-01"), mustDate(t, "2022-07-01"))
+	tests := []struct {
+		name       string
+		start, end string
+		want       bool
+	}{
+		{"open", "", "", true},
+		{"joined within", "2022-06-01", "", true},
+		{"joined at end", "2022-07-01", "", false},
+		{"left at start", "", "2022-01-01", false},
+		{"left within", "", "2022-01-02", true},
+		{"empty within", "2022-03-01", "2022-03-01", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var q Period
+			if tt.start != "" {
+				q.Start = mustDate(t, tt.start)
+			}
+			if tt.end != "" {
+				q.End = mustDate(t, tt.end)
+			}
+			if got := p.Overlaps(q); got != tt.want {
+				t.Errorf("Overlaps(%v) = %t, want %t", q, got, tt.want)
+			}
+			if got := q.Overlaps(p); got != tt.want {
+				t.Errorf("%v.Overlaps() = %t, want %t", q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvention_Set(t *testing.T) {
+	var c Convention
+	if err := c.Set("actual/360"); err != nil {
+		t.Fatal(err)
+	}
+	if c != Actual360 {
+		t.Errorf("Set(actual/360) = %v", c)
+	}
+	if err := c.Set("monthly"); err == nil {
+		t.Error("Set(monthly) succeeded, want error")
+	}
+}
+
+func mustDate(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
@@ -0,0 +1,70 @@
+package property
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ID is a Finnish property identifier, kiinteistötunnus, like 91-404-1-123.
+type ID struct {
+	Municipality int // kuntanumero
+	District     int // kylän tai kaupunginosan numero
+	Group        int // ryhmän tai korttelin numero
+	Unit         int // yksikön tai tontin numero
+}
+
+// widths are the number of digits in each part of the long form.
+var widths = [4]int{3, 3, 4, 4}
+
+// Parse parses the hyphenated form, like 91-404-1-123 or 091-404-0001-0123,
+// or the 14-digit long form, like 09140400010123.
+func Parse(s string) (ID, error) {
+	s = strings.TrimSpace(s)
+
+	var parts []string
+	if strings.Contains(s, "-") {
+		parts = strings.Split(s, "-")
+		if len(parts) != 4 {
+			return ID{}, fmt.Errorf("property ID %q does not have four parts", s)
+		}
+	} else {
+		if len(s) != 14 {
+			return ID{}, fmt.Errorf("property ID %q is not hyphenated and does not have 14 digits", s)
+		}
+		rest := s
+		for _, w := range widths {
+			parts = append(parts, rest[:w])
+			rest = rest[w:]
+		}
+	}
+
+	var nums [4]int
+	for i, p := range parts {
+		if p == "" || len(p) > widths[i] || strings.Trim(p, "0123456789") != "" {
+			return ID{}, fmt.Errorf("property ID %q has an invalid part %q", s, p)
+		}
+		nums[i], _ = strconv.Atoi(p) // digits only
+	}
+
+	res := ID{Municipality: nums[0], District: nums[1], Group: nums[2], Unit: nums[3]}
+	if !municipalities[res.Municipality] {
+		return ID{}, fmt.Errorf("property ID %q has an unknown municipality code %03d", s, res.Municipality)
+	}
+	if res.Unit == 0 {
+		return ID{}, fmt.Errorf("property ID %q has no unit number", s)
+	}
+
+	return res, nil
+}
+
+// String returns the ID in the hyphenated form without leading zeros,
+// like 91-404-1-123.
+func (id ID) String() string {
+	return fmt.Sprintf("%d-%d-%d-%d", id.Municipality, id.District, id.Group, id.Unit)
+}
+
+// Long returns the ID in the 14-digit long form, like 09140400010123.
+func (id ID) Long() string {
+	return fmt.Sprintf("%03d%03d%04d%04d", id.Municipality, id.District, id.Group, id.Unit)
+}
@@ -0,0 +1,36 @@
+package property
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in        string
+		str, long string
+		ok        bool
+	}{
+		{"91-404-1-123", "91-404-1-123", "09140400010123", true},
+		{"091-404-0001-0123", "91-404-1-123", "09140400010123", true},
+		{"09140400010123", "91-404-1-123", "09140400010123", true},
+		{" 837-12-34-5 ", "837-12-34-5", "83701200340005", true},
+		{"999-404-1-123", "", "", false},
+		{"91-404-1", "", "", false},
+		{"91-404-1-0", "", "", false},
+		{"91-4044-1-123", "", "", false},
+		{"0914040001012", "", "", false},
+		{"91-40x-1-123", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			id, err := Parse(tt.in)
+			if (err == nil) != tt.ok {
+				t.Fatalf("Parse(%q) error %v", tt.in, err)
+			}
+			if err != nil {
+				return
+			}
+			if id.String() != tt.str || id.Long() != tt.long {
+				t.Errorf("Parse(%q) = %s, %s, want %s, %s", tt.in, id, id.Long(), tt.str, tt.long)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package property
+
+// municipalities are the codes of the current Finnish municipalities as
+// assigned by Statistics Finland.
+var municipalities = map[int]bool{
+	5: true, 9: true, 10: true, 16: true, 18: true, 19: true, 20: true, 35: true, 43: true, 46: true, 47: true, 49: true,
+	50: true, 51: true, 52: true, 60: true, 61: true, 62: true, 65: true, 69: true, 71: true, 72: true, 74: true, 75: true,
+	76: true, 77: true, 78: true, 79: true, 81: true, 82: true, 86: true, 90: true, 91: true, 92: true, 97: true, 98: true,
+	102: true, 103: true, 105: true, 106: true, 108: true, 109: true, 111: true, 139: true, 140: true, 142: true, 143: true, 145: true,
+	146: true, 148: true, 149: true, 151: true, 152: true, 153: true, 165: true, 167: true, 169: true, 170: true, 171: true, 172: true,
+	176: true, 177: true, 178: true, 179: true, 181: true, 182: true, 186: true, 202: true, 204: true, 205: true, 208: true, 211: true,
+	213: true, 214: true, 216: true, 217: true, 218: true, 224: true, 226: true, 230: true, 231: true, 232: true, 233: true, 235: true,
+	236: true, 239: true, 240: true, 241: true, 244: true, 245: true, 249: true, 250: true, 256: true, 257: true, 260: true, 261: true,
+	263: true, 265: true, 271: true, 272: true, 273: true, 275: true, 276: true, 280: true, 284: true, 285: true, 286: true, 287: true,
+	288: true, 290: true, 291: true, 295: true, 297: true, 300: true, 301: true, 304: true, 305: true, 309: true, 312: true, 316: true,
+	317: true, 318: true, 320: true, 322: true, 398: true, 399: true, 400: true, 402: true, 403: true, 405: true, 407: true, 408: true,
+	410: true, 416: true, 417: true, 418: true, 420: true, 421: true, 422: true, 423: true, 425: true, 426: true, 430: true, 433: true,
+	434: true, 435: true, 436: true, 438: true, 440: true, 441: true, 444: true, 445: true, 475: true, 478: true, 480: true, 481: true,
+	483: true, 484: true, 489: true, 491: true, 494: true, 495: true, 498: true, 499: true, 500: true, 503: true, 504: true, 505: true,
+	507: true, 508: true, 529: true, 531: true, 535: true, 536: true, 538: true, 541: true, 543: true, 545: true, 560: true, 561: true,
+	562: true, 563: true, 564: true, 576: true, 577: true, 578: true, 580: true, 581: true, 583: true, 584: true, 588: true, 592: true,
+	593: true, 595: true, 598: true, 599: true, 601: true, 604: true, 607: true, 608: true, 609: true, 611: true, 614: true, 615: true,
+	616: true, 619: true, 620: true, 623: true, 624: true, 625: true, 626: true, 630: true, 631: true, 635: true, 636: true, 638: true,
+	678: true, 680: true, 681: true, 683: true, 684: true, 686: true, 687: true, 689: true, 691: true, 694: true, 697: true, 698: true,
+	700: true, 702: true, 704: true, 707: true, 710: true, 729: true, 732: true, 734: true, 736: true, 738: true, 739: true, 740: true,
+	742: true, 743: true, 746: true, 747: true, 748: true, 749: true, 751: true, 753: true, 755: true, 758: true, 759: true, 761: true,
+	762: true, 765: true, 766: true, 768: true, 771: true, 777: true, 778: true, 781: true, 783: true, 785: true, 790: true, 791: true,
+	831: true, 832: true, 833: true, 834: true, 837: true, 844: true, 845: true, 846: true, 848: true, 849: true, 850: true, 851: true,
+	853: true, 854: true, 857: true, 858: true, 859: true, 886: true, 887: true, 889: true, 890: true, 892: true, 893: true, 895: true,
+	905: true, 908: true, 915: true, 918: true, 921: true, 922: true, 924: true, 925: true, 927: true, 931: true, 934: true, 935: true,
+	936: true, 941: true, 946: true, 976: true, 977: true, 980: true, 981: true, 989: true, 992: true,
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jarnoan/vesimittari/config"
+	"github.com/jarnoan/vesimittari/csv"
+	"github.com/jarnoan/vesimittari/format"
+	"github.com/jarnoan/vesimittari/invoice"
+	"github.com/jarnoan/vesimittari/payment"
+	"github.com/jarnoan/vesimittari/reminder"
+	"github.com/shopspring/decimal"
+)
+
+// runReconcile matches the payments to the latest bills and writes the
+// reminders of the overdue bills.
+func runReconcile(cfg config.Config, args []string) error {
+	var (
+		paymentsCSV  string
+		remindersDir string
+		opts         reminder.Options
+		refRate      string
+		fee          string
+	)
+	fs := newFlagSet("reconcile")
+	fs.StringVar(&paymentsCSV, "payments", "", "received payments CSV file (date, reference, amount)")
+	fs.StringVar(&remindersDir, "reminders", "", "write payment reminders of overdue bills to this directory")
+	fs.StringVar(&refRate, "reference-rate", "0", "reference rate % for late interest (viitekorko)")
+	fs.StringVar(&fee, "reminder-fee", "5", "reminder fee €")
+	fs.IntVar(&opts.PaymentDays, "reminder-days", 14, "days to pay a reminder")
+	fs.Parse(args)
+
+	var err error
+	if opts.ReferenceRate, err = decimal.NewFromString(refRate); err != nil {
+		return fmt.Errorf("parse reference rate: %w", err)
+	}
+	if opts.Fee, err = decimal.NewFromString(fee); err != nil {
+		return fmt.Errorf("parse reminder fee: %w", err)
+	}
+	opts.Date = time.Now()
+
+	payments, err := readPayments(paymentsCSV)
+	if err != nil {
+		return err
+	}
+
+	csvf, _, err := readData(cfg)
+	if err != nil {
+		return err
+	}
+
+	bills, err := recordBills(csvf)
+	if err != nil {
+		return err
+	}
+
+	balances, unmatched := payment.Reconcile(bills, payments)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Viite\tNimi\tLaskutettu\tMaksettu\tAvoinna\n")
+	for _, b := range balances {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", b.Bill.Reference, b.Bill.Name,
+			format.Amount(b.Bill.Total()), format.Amount(b.Paid), format.Amount(b.Unpaid()))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, p := range unmatched {
+		log.Printf("payment %s on %s with unknown reference %q", format.Amount(p.Amount), p.Date.Format("2.1.2006"), p.Reference)
+	}
+
+	if remindersDir != "" {
+		if err := writeReminders(remindersDir, payments, csvf, opts); err != nil {
+			return fmt.Errorf("write reminders: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// readPayments reads the payments from the CSV file, none if not given.
+func readPayments(filename string) ([]payment.Payment, error) {
+	if filename == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	res, err := csv.ReadPayments(file)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", filename, err)
+	}
+
+	return res, nil
+}
+
+// writeReminders writes the reminders of the bills that have not been
+// paid by their due dates as text and PDF files to the directory.
+func writeReminders(dir string, payments []payment.Payment, csvf *csv.CSVFile, opts reminder.Options) error {
+	bills, err := dueBills(csvf)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for _, r := range reminder.Overdue(bills, payments, opts) {
+		log.Printf("overdue: %s, reference %s, unpaid %s, %d days late", r.Bill.Name, r.Bill.Reference, r.Unpaid, r.DaysLate)
+
+		var text bytes.Buffer
+		if err := reminder.WriteText(&text, r); err != nil {
+			return err
+		}
+
+		base := filepath.Join(dir, "muistutus-"+string(r.Bill.Reference))
+		if err := os.WriteFile(base+".txt", text.Bytes(), 0o644); err != nil {
+			return err
+		}
+
+		var pdf bytes.Buffer
+		if err := invoice.WritePDF(&pdf, text.String()); err != nil {
+			return err
+		}
+		if err := os.WriteFile(base+".pdf", pdf.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dueBills returns the latest bills of the records with their due dates.
+func dueBills(csvf *csv.CSVFile) ([]reminder.Due, error) {
+	mrs, err := csvf.MeterRecords()
+	if err != nil {
+		return nil, fmt.Errorf("read meter records: %w", err)
+	}
+
+	var res []reminder.Due
+	for _, mr := range mrs {
+		b, err := mr.Bill()
+		if err != nil {
+			return nil, fmt.Errorf("get bill of %s: %w", mr.Name(), err)
+		}
+		if len(b.Lines) == 0 {
+			continue
+		}
+
+		due, err := billDueDate(csvf, mr.(*csv.MeterRow))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, reminder.Due{Bill: b, Date: due})
+	}
+
+	return res, nil
+}
@@ -12,13 +12,33 @@ func (n Number) Next() Number {
 	}
 	next := strconv.FormatInt(baseN+1, 10)
 
+	return Number(next + strconv.Itoa(checkDigit(next)))
+}
+
+// Valid tells whether the number is a valid Finnish reference number: 4 to
+// 20 digits of which the last one is the check digit.
+func (n Number) Valid() bool {
+	if len(n) < 4 || len(n) > 20 {
+		return false
+	}
+	for _, c := range n {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return int(n[len(n)-1]-'0') == checkDigit(string(n[:len(n)-1]))
+}
+
+// checkDigit returns the check digit of the base part of a reference number.
+func checkDigit(base string) int {
 	weights := []int{7, 3, 1}
 	sum := 0
-	for i := 0; i < len(next); i++ {
-		digit := int(next[len(next)-i-1] - '0')
+	for i := 0; i < len(base); i++ {
+		digit := int(base[len(base)-i-1] - '0')
 		weight := weights[i%3]
 		sum += digit * weight
 	}
-	checkDigit := (10 - (sum % 10)) % 10
-	return Number(next + strconv.Itoa(checkDigit))
+
+	return (10 - (sum % 10)) % 10
 }
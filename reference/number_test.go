@@ -18,3 +18,24 @@ func TestNumber_Next(t *testing.T) {
 		})
 	}
 }
+
+func TestNumber_Valid(t *testing.T) {
+	tests := []struct {
+		n    Number
+		want bool
+	}{
+		{"123453", true},
+		{"13504687", true},
+		{"123454", false},
+		{"12a453", false},
+		{"123", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.n), func(t *testing.T) {
+			if got := tt.n.Valid(); got != tt.want {
+				t.Errorf("Valid(%v) = %v, want %v", tt.n, got, tt.want)
+			}
+		})
+	}
+}
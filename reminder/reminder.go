@@ -0,0 +1,153 @@
+package reminder
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/jarnoan/vesimittari/format"
+	"github.com/jarnoan/vesimittari/payment"
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+// StatutoryMargin is the margin added to the reference rate to get the
+// statutory late interest rate (korkolaki 4 §), in percentage points.
+var StatutoryMargin = decimal.NewFromInt(7)
+
+var (
+	hundred = decimal.NewFromInt(100)
+	year    = decimal.NewFromInt(365)
+)
+
+// Options contains the terms of the reminders.
+type Options struct {
+	Date          time.Time       // date of the reminders
+	ReferenceRate decimal.Decimal // % (viitekorko)
+	Fee           decimal.Decimal // € per reminder
+	PaymentDays   int             // days to pay the reminder
+}
+
+// Reminder is a reminder of an overdue bill.
+type Reminder struct {
+	Bill       updater.Bill
+	DueDate    time.Time       // original due date
+	Paid       decimal.Decimal // € paid so far
+	Unpaid     decimal.Decimal // € of the bill still unpaid
+	DaysLate   int             // days from the original due date
+	Interest   decimal.Decimal // € of late interest
+	Fee        decimal.Decimal // € reminder fee
+	NewDueDate time.Time
+}
+
+// Total returns the amount to pay.
+func (r Reminder) Total() decimal.Decimal {
+	return r.Unpaid.Add(r.Interest).Add(r.Fee)
+}
+
+// Due is a bill and its due date.
+type Due struct {
+	Bill updater.Bill
+	Date time.Time
+}
+
+// Overdue returns reminders for the bills that have not been fully paid by
+// their due dates. Payments are matched to the bills by reference. Late
+// interest accrues on the unpaid amount from the due date until it is paid
+// or until the date of the reminders.
+func Overdue(bills []Due, payments []payment.Payment, opts Options) []Reminder {
+	rate := opts.ReferenceRate.Add(StatutoryMargin).Div(hundred)
+	byRef := payment.ByReference(payments)
+
+	var res []Reminder
+	for _, d := range bills {
+		b, dueDate := d.Bill, d.Date
+		if !opts.Date.After(dueDate) {
+			continue // not due yet
+		}
+		if !b.Total().IsPositive() {
+			continue // nothing to pay
+		}
+
+		ps := byRef[b.Reference]
+		sort.SliceStable(ps, func(i, j int) bool { return ps[i].Date.Before(ps[j].Date) })
+
+		// Interest is calculated for each interval between payments after the due date
+		unpaid := b.Total()
+		var paid, interest decimal.Decimal
+		from := dueDate
+		for _, p := range ps {
+			if p.Date.After(opts.Date) {
+				break
+			}
+			if p.Date.After(from) {
+				interest = interest.Add(lateInterest(unpaid, rate, period.New(from, p.Date)))
+				from = p.Date
+			}
+			unpaid = unpaid.Sub(p.Amount)
+			paid = paid.Add(p.Amount)
+		}
+		if !unpaid.IsPositive() {
+			continue // paid in full
+		}
+		interest = interest.Add(lateInterest(unpaid, rate, period.New(from, opts.Date)))
+
+		res = append(res, Reminder{
+			Bill:       b,
+			DueDate:    dueDate,
+			Paid:       paid,
+			Unpaid:     unpaid,
+			DaysLate:   period.New(dueDate, opts.Date).Days(),
+			Interest:   interest.Round(2),
+			Fee:        opts.Fee,
+			NewDueDate: opts.Date.AddDate(0, 0, opts.PaymentDays),
+		})
+	}
+
+	return res
+}
+
+// lateInterest returns the interest of the amount for the days of the period.
+func lateInterest(amount, rate decimal.Decimal, p period.Period) decimal.Decimal {
+	if !amount.IsPositive() {
+		return decimal.Zero
+	}
+
+	return amount.Mul(rate).Mul(decimal.NewFromInt(int64(p.Days()))).Div(year)
+}
+
+// WriteText writes the reminder letter as plain text. The reference of
+// the original bill is kept so that the payment can be matched to it.
+func WriteText(w io.Writer, r Reminder) error {
+	_, err := fmt.Fprintf(w, `Maksumuistutus
+%s
+Viite %s
+
+Laskunne %s eräpäivä oli %s. Emme ole saaneet suoritustanne
+kokonaisuudessaan. Jos olette jo maksaneet laskun, tämä muistutus on aiheeton.
+
+Laskun summa            %10s
+Maksettu                %10s
+Maksamatta              %10s
+%-24s%10s
+Muistutusmaksu          %10s
+Maksettava yhteensä     %10s
+
+Uusi eräpäivä %s. Käyttäkää maksaessanne viitettä %s.
+`,
+		r.Bill.Name,
+		r.Bill.Reference,
+		r.Bill.Reference, r.DueDate.Format("2.1.2006"),
+		format.Amount(r.Bill.Total()),
+		format.Amount(r.Paid),
+		format.Amount(r.Unpaid),
+		fmt.Sprintf("Viivästyskorko %d pv", r.DaysLate), format.Amount(r.Interest),
+		format.Amount(r.Fee),
+		format.Amount(r.Total()),
+		r.NewDueDate.Format("2.1.2006"), r.Bill.Reference,
+	)
+
+	return err
+}
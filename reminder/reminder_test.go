@@ -0,0 +1,69 @@
+package reminder
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarnoan/vesimittari/payment"
+	"github.com/jarnoan/vesimittari/reference"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+func TestOverdue(t *testing.T) {
+	date := func(d, m int) time.Time { return time.Date(2022, time.Month(m), d, 0, 0, 0, 0, time.UTC) }
+	bill := func(ref string, total int64) updater.Bill {
+		return updater.Bill{Name: ref, Reference: reference.Number("10" + ref), Lines: []updater.BillLine{
+			updater.NewBillLine(updater.WaterFee, "Vesi", decimal.NewFromInt(total), "m³", decimal.NewFromInt(1), decimal.Zero),
+		}}
+	}
+	bills := []Due{
+		{Bill: bill("1", 100), Date: date(1, 3)},
+		{Bill: bill("2", 50), Date: date(1, 3)},
+		{Bill: bill("3", 80), Date: date(1, 3)},
+		{Bill: bill("4", 30), Date: date(31, 3)}, // not due yet
+	}
+	payments := []payment.Payment{
+		{Date: date(11, 3), Reference: "101", Amount: decimal.NewFromInt(40)},
+		{Date: date(20, 2), Reference: "102", Amount: decimal.NewFromInt(50)},
+		{Date: date(1, 4), Reference: "103", Amount: decimal.NewFromInt(80)}, // after the reminder date
+	}
+	opts := Options{
+		Date:          date(31, 3),
+		ReferenceRate: decimal.NewFromInt(3),
+		Fee:           decimal.NewFromInt(5),
+		PaymentDays:   14,
+	}
+
+	rs := Overdue(bills, payments, opts)
+	if len(rs) != 2 {
+		t.Fatalf("got %d reminders, want 2", len(rs))
+	}
+
+	r := rs[0]
+	if r.Bill.Reference != "101" || !r.Unpaid.Equal(decimal.NewFromInt(60)) || r.DaysLate != 30 {
+		t.Errorf("reminder = %+v", r)
+	}
+	if want := decimal.RequireFromString("0.6"); !r.Interest.Equal(want) {
+		t.Errorf("interest = %v, want %v", r.Interest, want)
+	}
+	if want := decimal.RequireFromString("65.6"); !r.Total().Equal(want) {
+		t.Errorf("total = %v, want %v", r.Total(), want)
+	}
+	if !r.NewDueDate.Equal(date(14, 4)) {
+		t.Errorf("new due date = %v", r.NewDueDate)
+	}
+	if rs[1].Bill.Reference != "103" {
+		t.Errorf("second reminder is for %s, want 103", rs[1].Bill.Reference)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "viitettä 101") || !strings.Contains(buf.String(), "65,60") {
+		t.Errorf("letter:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,151 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jarnoan/vesimittari/format"
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/jarnoan/vesimittari/reference"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+// Record defines the methods needed from a billed meter record.
+type Record interface {
+	Name() string
+	Bill() (updater.Bill, error)
+	Total() (decimal.Decimal, error) // total written to the data
+}
+
+// Run is the data of a single billing run.
+type Run struct {
+	Date    time.Time // billing date
+	Records []Record
+}
+
+// VATRate is the sum of the bill lines at a VAT rate.
+type VATRate struct {
+	VAT        decimal.Decimal // %
+	WithoutTax decimal.Decimal
+	Tax        decimal.Decimal
+	WithTax    decimal.Decimal
+}
+
+// VATSummary is the sum of the bills of the billing runs in a period by
+// VAT rate.
+type VATSummary struct {
+	Period period.Period
+	Bills  int
+	Rates  []VATRate
+	Billed decimal.Decimal // sum of the totals written to the data
+}
+
+// Total returns the sum of the rates with tax.
+func (s VATSummary) Total() decimal.Decimal {
+	var res decimal.Decimal
+	for _, r := range s.Rates {
+		res = res.Add(r.WithTax)
+	}
+
+	return res
+}
+
+// Difference returns the difference of the billed totals and the sum of
+// the rates. It is zero when the summary reconciles.
+func (s VATSummary) Difference() decimal.Decimal {
+	return s.Billed.Sub(s.Total())
+}
+
+// VAT returns the summary of the bills of the runs within the period.
+// A bill belongs to the first billing run it appears in, so the same bill
+// in the data of later runs is counted once, and not at all if it was
+// first billed before the period.
+func VAT(runs []Run, p period.Period) (VATSummary, error) {
+	runs = append([]Run(nil), runs...)
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Date.Before(runs[j].Date) })
+
+	res := VATSummary{Period: p}
+	rates := make(map[string]*VATRate)
+	seen := make(map[reference.Number]bool)
+
+	for _, run := range runs {
+		for _, r := range run.Records {
+			b, err := r.Bill()
+			if err != nil {
+				return VATSummary{}, fmt.Errorf("get bill of %s: %w", r.Name(), err)
+			}
+			if b.Reference == "" || len(b.Lines) == 0 || seen[b.Reference] {
+				continue
+			}
+			seen[b.Reference] = true
+			if !p.Contains(run.Date) {
+				continue
+			}
+			res.Bills++
+
+			total, err := r.Total()
+			if err != nil {
+				return VATSummary{}, fmt.Errorf("%s: %w", r.Name(), err)
+			}
+			res.Billed = res.Billed.Add(total)
+
+			for _, l := range b.Lines {
+				vr, ok := rates[l.VAT.String()]
+				if !ok {
+					vr = &VATRate{VAT: l.VAT}
+					rates[l.VAT.String()] = vr
+				}
+				vr.WithoutTax = vr.WithoutTax.Add(l.WithoutTax)
+				vr.Tax = vr.Tax.Add(l.Tax)
+				vr.WithTax = vr.WithTax.Add(l.WithTax)
+			}
+		}
+	}
+
+	for _, vr := range rates {
+		res.Rates = append(res.Rates, *vr)
+	}
+	sort.Slice(res.Rates, func(i, j int) bool { return res.Rates[i].VAT.LessThan(res.Rates[j].VAT) })
+
+	return res, nil
+}
+
+// WriteVAT writes the summary as plain text.
+func WriteVAT(w io.Writer, s VATSummary) error {
+	end := s.Period.End
+	if !end.IsZero() {
+		end = end.AddDate(0, 0, -1)
+	}
+	if _, err := fmt.Fprintf(w, "ALV-yhteenveto %s - %s\nLaskuja %d\n\n",
+		date(s.Period.Start), date(end), s.Bills); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "ALV %%\tVeroton\tALV\tYhteensä\t\n")
+	var sum VATRate
+	for _, r := range s.Rates {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", format.Percent(r.VAT), format.Amount(r.WithoutTax), format.Amount(r.Tax), format.Amount(r.WithTax))
+		sum.WithoutTax = sum.WithoutTax.Add(r.WithoutTax)
+		sum.Tax = sum.Tax.Add(r.Tax)
+		sum.WithTax = sum.WithTax.Add(r.WithTax)
+	}
+	fmt.Fprintf(tw, "Yhteensä\t%s\t%s\t%s\t\n", format.Amount(sum.WithoutTax), format.Amount(sum.Tax), format.Amount(sum.WithTax))
+	fmt.Fprintf(tw, "Laskutettu\t\t\t%s\t\n", format.Amount(s.Billed))
+	fmt.Fprintf(tw, "Erotus\t\t\t%s\t\n", format.Amount(s.Difference()))
+
+	return tw.Flush()
+}
+
+// date formats the date, leaving a zero date empty.
+func date(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format("2.1.2006")
+}
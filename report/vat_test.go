@@ -0,0 +1,97 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+type fakeRecord struct {
+	bill updater.Bill
+}
+
+func (r *fakeRecord) Name() string                    { return r.bill.Name }
+func (r *fakeRecord) Bill() (updater.Bill, error)     { return r.bill, nil }
+func (r *fakeRecord) Total() (decimal.Decimal, error) { return r.bill.Total(), nil }
+
+func TestVAT(t *testing.T) {
+	vat := decimal.NewFromInt(24)
+	aho := &fakeRecord{updater.Bill{Name: "Aho", Reference: "1232", Lines: []updater.BillLine{
+		updater.NewBillLine(updater.WaterFee, "Vesi", decimal.NewFromInt(10), "m³", decimal.NewFromInt(2), vat),
+		updater.NewBillLine(updater.AdditionalFee, "Vakuutus", decimal.NewFromInt(1), "", decimal.NewFromInt(10), decimal.Zero),
+	}}}
+	back := &fakeRecord{updater.Bill{Name: "Bäck", Reference: "1245", Lines: []updater.BillLine{
+		updater.NewBillLine(updater.BasicFee, "Perusmaksu", decimal.NewFromInt(6), "kk", decimal.NewFromInt(5), vat),
+	}}}
+	later := &fakeRecord{updater.Bill{Name: "Aho", Reference: "1258", Lines: []updater.BillLine{
+		updater.NewBillLine(updater.WaterFee, "Vesi", decimal.NewFromInt(10), "m³", decimal.NewFromInt(2), vat),
+	}}}
+
+	runs := []Run{
+		{Date: time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC), Records: []Record{aho, back}},
+		// The bill of Bäck appears again in the data of a later run
+		{Date: time.Date(2022, 12, 31, 0, 0, 0, 0, time.UTC), Records: []Record{back}},
+		{Date: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Records: []Record{later}},
+	}
+	p := period.New(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	s, err := VAT(runs, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Bills != 2 || len(s.Rates) != 2 {
+		t.Fatalf("got %d bills and %d rates, want 2 and 2", s.Bills, len(s.Rates))
+	}
+	if !s.Rates[0].VAT.IsZero() || !s.Rates[0].WithTax.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("unexpected 0 %% rate %+v", s.Rates[0])
+	}
+	if !s.Rates[1].WithoutTax.Equal(decimal.NewFromInt(50)) || !s.Rates[1].Tax.Equal(decimal.NewFromInt(12)) {
+		t.Errorf("unexpected 24 %% rate %+v", s.Rates[1])
+	}
+	if !s.Difference().IsZero() {
+		t.Errorf("difference %s, want 0", s.Difference())
+	}
+
+	var buf bytes.Buffer
+	if err := WriteVAT(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"1.1.2022 - 31.12.2022", "72,00", "Erotus"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestVAT_billedBeforePeriod(t *testing.T) {
+	vat := decimal.NewFromInt(24)
+	stale := &fakeRecord{updater.Bill{Name: "Bäck", Reference: "1245", Lines: []updater.BillLine{
+		updater.NewBillLine(updater.BasicFee, "Perusmaksu", decimal.NewFromInt(6), "kk", decimal.NewFromInt(5), vat),
+	}}}
+	aho := &fakeRecord{updater.Bill{Name: "Aho", Reference: "1258", Lines: []updater.BillLine{
+		updater.NewBillLine(updater.WaterFee, "Vesi", decimal.NewFromInt(10), "m³", decimal.NewFromInt(2), vat),
+	}}}
+
+	// The bill of Bäck was billed in 2022 and appears again in the data of
+	// a run of 2023, and the runs are not in order
+	runs := []Run{
+		{Date: time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC), Records: []Record{aho, stale}},
+		{Date: time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC), Records: []Record{stale}},
+	}
+	p := period.New(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	s, err := VAT(runs, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Bills != 1 || !s.Total().Equal(decimal.RequireFromString("24.8")) {
+		t.Errorf("got %d bills totalling %s, want 1 and 24.80", s.Bills, s.Total())
+	}
+}
@@ -23,12 +23,37 @@ var (
 	datefmt   = "2.1.2006"
 )
 
+// DefaultPortal is the login page of the meter reading portal.
+const DefaultPortal = "https://www.kulutus-web.com/Nokia/vesi/Suomi/"
+
 // Scraper can scrape consumption records from web.
-type Scraper struct{}
+type Scraper struct {
+	portal string // URL of the login page
+}
+
+// New constructs a new scraper for the portal with the login page URL.
+// The default portal is used if it is empty.
+func New(portal string) *Scraper {
+	if portal == "" {
+		portal = DefaultPortal
+	}
+
+	return &Scraper{portal}
+}
+
+// url returns the URL of the path on the portal host.
+func (s *Scraper) url(path string) (string, error) {
+	base, err := url.Parse(s.portal)
+	if err != nil {
+		return "", fmt.Errorf("parse portal url: %w", err)
+	}
 
-// New constructs a new scraper.
-func New() *Scraper {
-	return &Scraper{}
+	ref, err := url.Parse(path)
+	if err != nil {
+		return "", err
+	}
+
+	return base.ResolveReference(ref).String(), nil
 }
 
 // ReadConsumption reads the consumption data of a meter.
@@ -76,7 +101,7 @@ func (s *Scraper) getLoginPage(ctx context.Context, client *http.Client) error {
 	req, err := http.NewRequestWithContext(
 		ctx,
 		"GET",
-		"https://www.kulutus-web.com/Nokia/vesi/Suomi/",
+		s.portal,
 		nil,
 	)
 	if err != nil {
@@ -106,10 +131,14 @@ func (s *Scraper) postLoginForm(ctx context.Context, client *http.Client, site m
 		"MenuToTheLeftFrame": {"no"},
 		"kieli":              {"suomi"},
 	}
+	loginURL, err := s.url("/common/logincheck_old.asp")
+	if err != nil {
+		return nil, err
+	}
 	req, err := http.NewRequestWithContext(
 		ctx,
 		"POST",
-		"https://www.kulutus-web.com/common/logincheck_old.asp",
+		loginURL,
 		strings.NewReader(data.Encode()),
 	)
 	if err != nil {
@@ -145,7 +174,7 @@ func (s *Scraper) counterPageURL(doc *goquery.Document) (string, error) {
 		return "", fmt.Errorf("no href in link")
 	}
 
-	return "https://www.kulutus-web.com/common/" + ctrURL, nil
+	return s.url("/common/" + ctrURL)
 }
 
 func (s *Scraper) getCounterPage(ctx context.Context, client *http.Client, url string) (*goquery.Document, error) {
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/jarnoan/vesimittari/api"
+	"github.com/jarnoan/vesimittari/config"
+	"github.com/jarnoan/vesimittari/dashboard"
+	"github.com/jarnoan/vesimittari/payment"
+	"github.com/jarnoan/vesimittari/scraper"
+)
+
+// runServe serves the data of the database as a JSON API under /api/
+// and as a dashboard for the board.
+func runServe(cfg config.Config, args []string) error {
+	var (
+		opts        api.Options
+		addr        string
+		portal      string
+		paymentsCSV string
+	)
+	fs := newFlagSet("serve")
+	updaterFlags(fs, &opts.Updater, cfg)
+	fs.BoolVar(&opts.Updater.UpdateMeterReadings, "read", false, "read the meters before billing")
+	fs.StringVar(&portal, "portal", cfg.Portal, "login page URL of the meter reading portal")
+	fs.StringVar(&addr, "addr", cfg.Server.Addr, "address to listen, host:port")
+	fs.StringVar(&opts.Token, "token", cfg.Server.Token, "token required from the clients, also VESIMITTARI_SERVER_TOKEN")
+	fs.StringVar(&paymentsCSV, "payments", "", "received payments CSV file (date, reference, amount) for the unpaid bills of the dashboard")
+	fs.Parse(args)
+
+	if opts.Token == "" {
+		return fmt.Errorf("token is required")
+	}
+	if addr == "" {
+		addr = "localhost:8080"
+	}
+	opts.MeterReader = scraper.New(portal)
+	opts.AdditionalCosts = cfg.AdditionalCostList()
+
+	s, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	dash := dashboard.Options{Token: opts.Token, Updater: opts.Updater, AdditionalCosts: opts.AdditionalCosts}
+	if paymentsCSV != "" {
+		dash.Payments = func() ([]payment.Payment, error) { return readPayments(paymentsCSV) }
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", api.New(s, opts))
+	mux.Handle("/", dashboard.New(s, dash))
+
+	log.Printf("serving on %s", addr)
+	return http.ListenAndServe(addr, mux)
+}
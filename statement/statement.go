@@ -0,0 +1,275 @@
+package statement
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jarnoan/vesimittari/meter"
+	"github.com/jarnoan/vesimittari/payment"
+	"github.com/jarnoan/vesimittari/reference"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+// Record defines the methods needed from a meter record of a billing run.
+type Record interface {
+	Name() string
+	PreviousReading() (meter.Reading, error)
+	Reading() (meter.Reading, error)
+	Consumption() (int, error)
+	Bill() (updater.Bill, error)
+}
+
+// Run is the data of a single billing run.
+type Run struct {
+	Date    time.Time // billing date
+	Records []Record
+}
+
+// Period is a billed meter reading period.
+type Period struct {
+	Start       meter.Reading
+	End         meter.Reading
+	Consumption int // m³
+}
+
+// FeeTotal is the sum billed or paid for a kind of fee at a VAT rate.
+type FeeTotal struct {
+	Kind       updater.LineKind
+	VAT        decimal.Decimal // %
+	WithoutTax decimal.Decimal
+	Tax        decimal.Decimal
+	WithTax    decimal.Decimal
+}
+
+// Statement is the annual summary of the consumption and bills of a member.
+type Statement struct {
+	Name        string
+	Year        int
+	Periods     []Period
+	Fees        []FeeTotal      // billed
+	Consumption int             // m³
+	Total       decimal.Decimal // € with tax billed
+	Paid        []FeeTotal      // paid during the year
+	PaidTotal   decimal.Decimal // € with tax
+
+	PreviousConsumption int // m³ during the previous year
+	PreviousTotal       decimal.Decimal
+}
+
+// entry is a bill of a member with the run it was first seen in.
+type entry struct {
+	date   time.Time
+	period *Period
+	bill   updater.Bill
+}
+
+// Members returns the names of the members billed during the year.
+func Members(runs []Run, year int) ([]string, error) {
+	entries, err := collect(runs)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		if e.date.Year() == year && !seen[e.bill.Name] {
+			seen[e.bill.Name] = true
+			res = append(res, e.bill.Name)
+		}
+	}
+
+	return res, nil
+}
+
+// Annual returns the statement of the member for the year. A bill belongs
+// to the year of the first billing run it appears in, so the same bill in
+// the data of later runs is counted only once. The payments are matched to
+// the bills by reference and belong to the year they were paid in.
+func Annual(runs []Run, payments []payment.Payment, name string, year int) (Statement, error) {
+	entries, err := collect(runs)
+	if err != nil {
+		return Statement{}, err
+	}
+
+	res := Statement{Name: name, Year: year}
+	fees := make(map[string]*FeeTotal)
+	paid := make(map[string]*FeeTotal)
+	byRef := payment.ByReference(payments)
+	for _, e := range entries {
+		if e.bill.Name != name {
+			continue
+		}
+
+		for _, l := range paidLines(e.bill, byRef[e.bill.Reference], year) {
+			res.PaidTotal = res.PaidTotal.Add(l.WithTax)
+			addFee(paid, l)
+		}
+
+		switch e.date.Year() {
+		case year - 1:
+			res.PreviousTotal = res.PreviousTotal.Add(e.bill.Total())
+			if e.period != nil {
+				res.PreviousConsumption += e.period.Consumption
+			}
+		case year:
+			res.Total = res.Total.Add(e.bill.Total())
+			if e.period != nil {
+				res.Periods = append(res.Periods, *e.period)
+				res.Consumption += e.period.Consumption
+			}
+			for _, l := range e.bill.Lines {
+				addFee(fees, l)
+			}
+		}
+	}
+
+	res.Fees = sortedFees(fees)
+	res.Paid = sortedFees(paid)
+
+	return res, nil
+}
+
+// addFee adds the amounts of the line to the total of its kind and VAT rate.
+func addFee(fees map[string]*FeeTotal, l updater.BillLine) {
+	if l.WithTax.IsZero() {
+		return
+	}
+
+	k := fmt.Sprintf("%d/%s", l.Kind, l.VAT)
+	f, ok := fees[k]
+	if !ok {
+		f = &FeeTotal{Kind: l.Kind, VAT: l.VAT}
+		fees[k] = f
+	}
+	f.WithoutTax = f.WithoutTax.Add(l.WithoutTax)
+	f.Tax = f.Tax.Add(l.Tax)
+	f.WithTax = f.WithTax.Add(l.WithTax)
+}
+
+// sortedFees returns the totals by kind and VAT rate.
+func sortedFees(fees map[string]*FeeTotal) []FeeTotal {
+	var res []FeeTotal
+	for _, f := range fees {
+		res = append(res, *f)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Kind != res[j].Kind {
+			return res[i].Kind < res[j].Kind
+		}
+		return res[i].VAT.LessThan(res[j].VAT)
+	})
+
+	return res
+}
+
+// paidLines returns the part of the lines of the bill paid by the payments
+// made during the year. A payment is divided between the lines in
+// proportion to their amounts. Payments are counted in the order they were
+// made up to the total of the bill, so an overpayment is left out.
+func paidLines(b updater.Bill, ps []payment.Payment, year int) []updater.BillLine {
+	total := b.Total()
+	if !total.IsPositive() {
+		return nil
+	}
+
+	ps = append([]payment.Payment(nil), ps...)
+	sort.SliceStable(ps, func(i, j int) bool { return ps[i].Date.Before(ps[j].Date) })
+
+	var res []updater.BillLine
+	unpaid := total
+	for _, p := range ps {
+		amount := decimal.Min(p.Amount, unpaid)
+		if !amount.IsPositive() {
+			continue
+		}
+		unpaid = unpaid.Sub(amount)
+		if p.Date.Year() != year {
+			continue
+		}
+
+		// The last line gets what is left after rounding the others
+		left := amount
+		for i, l := range b.Lines {
+			withTax := l.WithTax.Mul(amount).DivRound(total, 2)
+			if i == len(b.Lines)-1 {
+				withTax = left
+			}
+			left = left.Sub(withTax)
+
+			withoutTax := withTax
+			if !l.WithTax.IsZero() {
+				withoutTax = l.WithoutTax.Mul(withTax).DivRound(l.WithTax, 2)
+			}
+			res = append(res, updater.BillLine{Kind: l.Kind, VAT: l.VAT, WithoutTax: withoutTax, Tax: withTax.Sub(withoutTax), WithTax: withTax})
+		}
+	}
+
+	return res
+}
+
+// collect returns the bills of the runs in the order of the billing dates.
+// A bill is identified by its reference.
+func collect(runs []Run) ([]entry, error) {
+	runs = append([]Run(nil), runs...)
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Date.Before(runs[j].Date) })
+
+	var res []entry
+	seen := make(map[reference.Number]bool)
+	for _, run := range runs {
+		for _, r := range run.Records {
+			b, err := r.Bill()
+			if err != nil {
+				return nil, fmt.Errorf("get bill of %s: %w", r.Name(), err)
+			}
+			if b.Reference == "" || len(b.Lines) == 0 || seen[b.Reference] {
+				continue
+			}
+			seen[b.Reference] = true
+
+			p, err := readingPeriod(r, b)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", r.Name(), err)
+			}
+			res = append(res, entry{date: run.Date, period: p, bill: b})
+		}
+	}
+
+	return res, nil
+}
+
+// readingPeriod returns the meter reading period of the bill, or nil if the
+// bill is not based on meter readings.
+func readingPeriod(r Record, b updater.Bill) (*Period, error) {
+	metered := false
+	for _, l := range b.Lines {
+		if l.Kind == updater.WaterFee {
+			metered = true
+		}
+	}
+	if !metered {
+		return nil, nil
+	}
+
+	start, err := r.PreviousReading()
+	if err != nil {
+		return nil, err
+	}
+	if start.Date.IsZero() {
+		return nil, nil
+	}
+
+	end, err := r.Reading()
+	if err != nil {
+		return nil, err
+	}
+
+	cons, err := r.Consumption()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Period{Start: start, End: end, Consumption: cons}, nil
+}
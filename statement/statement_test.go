@@ -0,0 +1,102 @@
+package statement
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarnoan/vesimittari/meter"
+	"github.com/jarnoan/vesimittari/payment"
+	"github.com/jarnoan/vesimittari/reference"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+type fakeRecord struct {
+	prev, cur meter.Reading
+	cons      int
+	bill      updater.Bill
+}
+
+func (r *fakeRecord) Name() string                            { return r.bill.Name }
+func (r *fakeRecord) PreviousReading() (meter.Reading, error) { return r.prev, nil }
+func (r *fakeRecord) Reading() (meter.Reading, error)         { return r.cur, nil }
+func (r *fakeRecord) Consumption() (int, error)               { return r.cons, nil }
+func (r *fakeRecord) Bill() (updater.Bill, error)             { return r.bill, nil }
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func record(ref reference.Number, from, to time.Time, prev, cur int) *fakeRecord {
+	vat := decimal.NewFromInt(24)
+	return &fakeRecord{
+		prev: meter.Reading{Counter: prev, Date: from},
+		cur:  meter.Reading{Counter: cur, Date: to},
+		cons: cur - prev,
+		bill: updater.Bill{Name: "Virtanen", Reference: ref, Lines: []updater.BillLine{
+			updater.NewBillLine(updater.WaterFee, "Vesi", decimal.NewFromInt(int64(cur-prev)), "m³", decimal.NewFromInt(2), vat),
+			updater.NewBillLine(updater.BasicFee, "Perusmaksu", decimal.NewFromInt(6), "kk", decimal.NewFromInt(5), vat),
+		}},
+	}
+}
+
+func TestAnnual(t *testing.T) {
+	jun22 := record("1003", date(2022, 1, 1), date(2022, 7, 1), 70, 100)
+	dec22 := record("1016", date(2022, 7, 1), date(2022, 12, 31), 100, 140)
+	jun23 := record("1029", date(2022, 12, 31), date(2023, 6, 30), 140, 170)
+	dec23 := record("1032", date(2023, 6, 30), date(2023, 12, 31), 170, 220)
+	runs := []Run{
+		{Date: date(2024, 1, 5), Records: []Record{dec23}},
+		{Date: date(2022, 7, 5), Records: []Record{jun22}},
+		{Date: date(2023, 1, 5), Records: []Record{dec22}},
+		{Date: date(2023, 7, 5), Records: []Record{jun23}},
+		// An unchanged bill appears again in the data of a later run
+		{Date: date(2023, 9, 1), Records: []Record{jun23}},
+	}
+
+	payments := []payment.Payment{
+		{Date: date(2023, 1, 20), Reference: "1016", Amount: decimal.RequireFromString("136.40")},
+		{Date: date(2023, 7, 20), Reference: "1029", Amount: decimal.RequireFromString("55.80")},
+		{Date: date(2023, 12, 1), Reference: "1016", Amount: decimal.NewFromInt(10)}, // overpaid
+		{Date: date(2024, 1, 20), Reference: "1029", Amount: decimal.RequireFromString("55.80")},
+	}
+
+	s, err := Annual(runs, payments, "Virtanen", 2023)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Periods) != 2 {
+		t.Fatalf("got %d periods, want 2", len(s.Periods))
+	}
+	if s.Consumption != 70 || s.PreviousConsumption != 30 {
+		t.Errorf("consumption %d, previous %d, want 70 and 30", s.Consumption, s.PreviousConsumption)
+	}
+	// 70 * 2 + 2 * 6 * 5 = 200 + 24 % VAT
+	if want := decimal.NewFromInt(248); !s.Total.Equal(want) {
+		t.Errorf("total %s, want %s", s.Total, want)
+	}
+	if len(s.Fees) != 2 || s.Fees[0].Kind != updater.WaterFee || !s.Fees[0].WithoutTax.Equal(decimal.NewFromInt(140)) {
+		t.Errorf("unexpected fees %+v", s.Fees)
+	}
+
+	// The whole bill 1016 and half of the bill 1029 were paid during the year
+	if want := decimal.RequireFromString("192.20"); !s.PaidTotal.Equal(want) {
+		t.Errorf("paid %s, want %s", s.PaidTotal, want)
+	}
+	if len(s.Paid) != 2 || !s.Paid[0].WithoutTax.Equal(decimal.NewFromInt(110)) || !s.Paid[1].WithTax.Equal(decimal.RequireFromString("55.80")) {
+		t.Errorf("unexpected paid fees %+v", s.Paid)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Vuosikooste 2023", "31.12.2022", "Vesimaksut", "248,00", "+40", "Maksetut maksut", "192,20"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
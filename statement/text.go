@@ -0,0 +1,81 @@
+package statement
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/jarnoan/vesimittari/format"
+	"github.com/jarnoan/vesimittari/updater"
+)
+
+var kindNames = map[updater.LineKind]string{
+	updater.WaterFee:      "Vesimaksut",
+	updater.BasicFee:      "Perusmaksut",
+	updater.AdditionalFee: "Lisämaksut",
+	updater.OtherFee:      "Muut maksut",
+}
+
+// WriteText writes the statement as plain text.
+func WriteText(w io.Writer, s Statement) error {
+	if _, err := fmt.Fprintf(w, "Vuosikooste %d\n%s\n\nMittarilukemat\n", s.Year, s.Name); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Alkaen\tLukema\tPäättyen\tLukema\tKulutus m³\t\n")
+	for _, p := range s.Periods {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%d\t\n",
+			p.Start.Date.Format("2.1.2006"), p.Start.Counter, p.End.Date.Format("2.1.2006"), p.End.Counter, p.Consumption)
+	}
+	fmt.Fprintf(tw, "Yhteensä\t\t\t\t%d\t\n", s.Consumption)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "\nLaskutetut maksut\n"); err != nil {
+		return err
+	}
+
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Maksu\tALV %%\tVeroton\tALV\tYhteensä\t\n")
+	for _, f := range s.Fees {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n",
+			kindNames[f.Kind], format.Percent(f.VAT), format.Amount(f.WithoutTax), format.Amount(f.Tax), format.Amount(f.WithTax))
+	}
+	fmt.Fprintf(tw, "Yhteensä\t\t\t\t%s\t\n", format.Amount(s.Total))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "\nMaksetut maksut\n"); err != nil {
+		return err
+	}
+
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Maksu\tALV %%\tVeroton\tALV\tYhteensä\t\n")
+	for _, f := range s.Paid {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n",
+			kindNames[f.Kind], format.Percent(f.VAT), format.Amount(f.WithoutTax), format.Amount(f.Tax), format.Amount(f.WithTax))
+	}
+	fmt.Fprintf(tw, "Yhteensä\t\t\t\t%s\t\n", format.Amount(s.PaidTotal))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "\nVertailu edelliseen vuoteen\n"); err != nil {
+		return err
+	}
+
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "\t%d\t%d\tMuutos\t\n", s.Year-1, s.Year)
+	fmt.Fprintf(tw, "Kulutus m³\t%d\t%d\t%+d\t\n", s.PreviousConsumption, s.Consumption, s.Consumption-s.PreviousConsumption)
+	change := s.Total.Sub(s.PreviousTotal)
+	sign := ""
+	if change.IsPositive() {
+		sign = "+"
+	}
+	fmt.Fprintf(tw, "Maksut €\t%s\t%s\t%s%s\t\n", format.Amount(s.PreviousTotal), format.Amount(s.Total), sign, format.Amount(change))
+
+	return tw.Flush()
+}
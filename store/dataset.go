@@ -0,0 +1,408 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jarnoan/vesimittari/model"
+	"github.com/jarnoan/vesimittari/reference"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+// Import replaces the data with the dataset. The bills of the dataset
+// have a line for the water fee, the basic fee and each additional cost.
+func (d *Data) Import(ds model.Dataset) error {
+	for _, table := range []string{"exchanges", "bill_lines", "bills", "readings", "meters", "members",
+		"price_tiers", "price_components", "vat_changes", "tariffs", "billing"} {
+		if _, err := d.tx.Exec("DELETE FROM " + table); err != nil {
+			return fmt.Errorf("clear %s: %w", table, err)
+		}
+	}
+
+	p := ds.Parameters
+	_, err := d.tx.Exec("INSERT INTO tariffs (id, main_meter_fee, vat) VALUES (1, ?, ?)", p.MainMeterFee.String(), p.VAT.String())
+	if err != nil {
+		return fmt.Errorf("add tariffs: %w", err)
+	}
+	if err := d.SetWaterPrices([]updater.PriceComponent{{Label: "Vesimaksu", VAT: p.VAT, Price: p.WaterPrice}}); err != nil {
+		return err
+	}
+	if p.VATChange != nil {
+		if err := d.SetVATChanges([]updater.VATChange{{Date: p.VATChange.Date.Time, Old: p.VATChange.Previous, New: p.VAT}}); err != nil {
+			return err
+		}
+	}
+	_, err = d.tx.Exec("INSERT INTO billing (id, date, payment_days, message) VALUES (1, ?, ?, ?)",
+		p.Date.Format(dateFormat), p.PaymentDays, p.Message)
+	if err != nil {
+		return fmt.Errorf("add billing: %w", err)
+	}
+
+	for i, m := range ds.Members {
+		if err := d.importMember(i, m); err != nil {
+			return fmt.Errorf("member %d %s: %w", i+1, m.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// importMember adds the member at the position.
+func (d *Data) importMember(pos int, m model.Member) error {
+	res, err := d.tx.Exec(`INSERT INTO members (position, name, bank_account, phone, email, street_address,
+			postal_code, city, property_id, tenants, permanent_residency, join_date, leave_date)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		pos, m.Name, m.BankAccount, m.Phone, m.Email, m.StreetAddress, m.PostalCode, m.City, m.PropertyID,
+		m.Tenants, m.PermanentResidency, modelDate(m.JoinDate), modelDate(m.LeaveDate))
+	if err != nil {
+		return fmt.Errorf("add member: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("add member: %w", err)
+	}
+
+	var cons decimal.Decimal
+	if mt := m.Meter; mt != nil {
+		var consumption sql.NullInt64
+		if mt.Consumption != nil {
+			consumption = sql.NullInt64{Int64: int64(*mt.Consumption), Valid: true}
+			cons = decimal.NewFromInt(consumption.Int64)
+		}
+		res, err := d.tx.Exec("INSERT INTO meters (member_id, site, number, consumption) VALUES (?, ?, ?, ?)",
+			id, mt.Site, mt.Number, consumption)
+		if err != nil {
+			return fmt.Errorf("add meter: %w", err)
+		}
+		meterID, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("add meter: %w", err)
+		}
+
+		for _, rdg := range []*model.Reading{mt.PreviousReading, mt.Reading} {
+			if rdg == nil {
+				continue
+			}
+			_, err := d.tx.Exec("INSERT INTO readings (meter_id, date, counter, checked) VALUES (?, ?, ?, ?)",
+				meterID, modelDate(rdg.Date), rdg.Counter, rdg.Check)
+			if err != nil {
+				return fmt.Errorf("add reading: %w", err)
+			}
+		}
+
+		if ex := mt.Exchange; ex != nil {
+			_, err := d.tx.Exec(`INSERT INTO exchanges (meter_id, date, old_number, final_counter, new_number, start_counter)
+				VALUES (?, ?, ?, ?, ?, ?)`,
+				meterID, ex.Date.Format(dateFormat), ex.OldNumber, ex.FinalCounter, mt.Number, ex.StartCounter)
+			if err != nil {
+				return fmt.Errorf("add exchange: %w", err)
+			}
+		}
+	}
+
+	if b := m.Bill; b != nil {
+		var months sql.NullString
+		if b.Months != nil {
+			months = sql.NullString{String: b.Months.String(), Valid: true}
+		}
+		billID, err := d.insertBill(id, updater.Bill{Name: m.Name, Reference: reference.Number(b.Reference), Lines: billLines(b, cons)}, months, modelDate(b.Date), b.Total != nil)
+		if err != nil {
+			return err
+		}
+		if (b.WaterFee != nil && len(b.WaterLines) == 0) || (b.BasicFee != nil && len(b.BasicLines) == 0) {
+			if _, err := d.tx.Exec("UPDATE bills SET itemised = 0 WHERE id = ?", billID); err != nil {
+				return fmt.Errorf("mark bill of %s: %w", m.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// billLines returns the lines of the bill of a dataset. A bill without
+// itemised lines gets a single line for the water fee and the basic fee.
+func billLines(b *model.Bill, cons decimal.Decimal) []updater.BillLine {
+	var res []updater.BillLine
+	for _, l := range b.WaterLines {
+		res = append(res, billLine(updater.WaterFee, l))
+	}
+	for _, l := range b.BasicLines {
+		res = append(res, billLine(updater.BasicFee, l))
+	}
+
+	amountLine := func(kind updater.LineKind, desc string, qty decimal.Decimal, unit string, a model.Amount) updater.BillLine {
+		l := updater.BillLine{Kind: kind, Description: desc, Quantity: qty, Unit: unit,
+			WithoutTax: a.WithoutTax, Tax: a.Tax, WithTax: a.WithTax}
+		if !qty.IsZero() {
+			l.UnitPrice = a.WithoutTax.DivRound(qty, 4)
+		}
+		if !a.WithoutTax.IsZero() {
+			l.VAT = a.Tax.Mul(decimal.NewFromInt(100)).DivRound(a.WithoutTax, 1)
+		}
+		return l
+	}
+
+	if b.WaterFee != nil && len(b.WaterLines) == 0 {
+		res = append(res, amountLine(updater.WaterFee, "Vesimaksu", cons, "m³", *b.WaterFee))
+	}
+	if b.BasicFee != nil && len(b.BasicLines) == 0 {
+		var months decimal.Decimal
+		if b.Months != nil {
+			months = *b.Months
+		}
+		res = append(res, amountLine(updater.BasicFee, "Perusmaksu", months, "kk", *b.BasicFee))
+	}
+
+	for _, c := range b.AdditionalCosts {
+		res = append(res, updater.BillLine{
+			Kind:        updater.AdditionalFee,
+			Description: c.Description,
+			Quantity:    one,
+			UnitPrice:   c.WithoutTax,
+			VAT:         c.VAT,
+			WithoutTax:  c.WithoutTax,
+			Tax:         c.Tax,
+			WithTax:     c.WithTax,
+		})
+	}
+
+	// Without descriptions the tax of the additional costs is not known
+	if len(b.AdditionalCosts) == 0 && b.AdditionalTotal != nil && !b.AdditionalTotal.IsZero() {
+		res = append(res, updater.NewBillLine(updater.AdditionalFee, "Lisämaksut", one, "", *b.AdditionalTotal, decimal.Zero))
+	}
+
+	return res
+}
+
+// billLine returns the bill line of an itemised line of a dataset.
+func billLine(kind updater.LineKind, l model.Line) updater.BillLine {
+	return updater.BillLine{
+		Kind:        kind,
+		Description: l.Description,
+		Quantity:    l.Quantity,
+		Unit:        l.Unit,
+		UnitPrice:   l.UnitPrice,
+		VAT:         l.VAT,
+		WithoutTax:  l.WithoutTax,
+		Tax:         l.Tax,
+		WithTax:     l.WithTax,
+	}
+}
+
+// modelLine returns the bill line as an itemised line of a dataset.
+func modelLine(l updater.BillLine) model.Line {
+	return model.Line{
+		Description: l.Description,
+		Quantity:    l.Quantity,
+		Unit:        l.Unit,
+		UnitPrice:   l.UnitPrice,
+		VAT:         l.VAT,
+		Amount:      model.Amount{WithoutTax: l.WithoutTax, Tax: l.Tax, WithTax: l.WithTax},
+	}
+}
+
+// Dataset returns the data as a dataset with the latest readings and
+// bills of the members.
+func (d *Data) Dataset() (model.Dataset, error) {
+	var res model.Dataset
+
+	mrs, err := d.MeterRecords()
+	if err != nil {
+		return model.Dataset{}, err
+	}
+	for _, mr := range mrs {
+		m, err := d.member(mr.(*record))
+		if err != nil {
+			return model.Dataset{}, fmt.Errorf("member %s: %w", mr.Name(), err)
+		}
+		res.Members = append(res.Members, m)
+	}
+
+	p := &res.Parameters
+	if p.Date.Time, err = d.Date(); err != nil {
+		return model.Dataset{}, err
+	}
+	if p.PaymentDays, err = d.PaymentDays(); err != nil {
+		return model.Dataset{}, err
+	}
+	cv, err := d.CommonVariables()
+	if err != nil {
+		return model.Dataset{}, err
+	}
+	// The dataset has a single water price and VAT change like the data
+	// file: the first component and the latest change of the general rate
+	p.MainMeterFee = cv.MainMeterFee
+	p.WaterPrice = cv.WaterPrices[0].Price
+	p.VAT = cv.VAT
+	for _, c := range cv.VATChanges {
+		if c.New.Equal(cv.VAT) {
+			p.VATChange = &model.VATChange{Date: model.Date{Time: c.Date}, Previous: c.Old}
+		}
+	}
+	if p.Message, err = d.Message(); err != nil {
+		return model.Dataset{}, err
+	}
+
+	return res, nil
+}
+
+// member returns the member of the record as a member of a dataset.
+func (d *Data) member(r *record) (model.Member, error) {
+	var res model.Member
+	err := d.tx.QueryRow(`SELECT name, bank_account, phone, email, street_address, postal_code, city,
+			property_id, tenants, permanent_residency
+		FROM members WHERE id = ?`, r.id).
+		Scan(&res.Name, &res.BankAccount, &res.Phone, &res.Email, &res.StreetAddress, &res.PostalCode, &res.City,
+			&res.PropertyID, &res.Tenants, &res.PermanentResidency)
+	if err != nil {
+		return model.Member{}, fmt.Errorf("query member: %w", err)
+	}
+
+	membership, err := r.Membership()
+	if err != nil {
+		return model.Member{}, err
+	}
+	res.JoinDate = model.NewDate(membership.Start)
+	res.LeaveDate = model.NewDate(membership.End)
+
+	var site, num string
+	var cons sql.NullInt64
+	err = d.tx.QueryRow("SELECT site, number, consumption FROM meters WHERE member_id = ?", r.id).Scan(&site, &num, &cons)
+	switch {
+	case errNoRows(err):
+	case err != nil:
+		return model.Member{}, fmt.Errorf("query meter: %w", err)
+	default:
+		mt := &model.Meter{Site: site, Number: num}
+		if cons.Valid {
+			c := int(cons.Int64)
+			mt.Consumption = &c
+		}
+
+		rdgs, _, err := r.readings(2)
+		if err != nil {
+			return model.Member{}, err
+		}
+		if len(rdgs) > 0 {
+			mt.Reading = &model.Reading{Counter: rdgs[0].Counter, Date: model.NewDate(rdgs[0].Date), Check: rdgs[0].Customer}
+		}
+		var since time.Time
+		if len(rdgs) > 1 {
+			mt.PreviousReading = &model.Reading{Counter: rdgs[1].Counter, Date: model.NewDate(rdgs[1].Date)}
+			since = rdgs[1].Date
+		}
+
+		// The exchange of the latest reading interval or since the latest reading
+		ex, err := r.exchange(since, time.Time{})
+		if err != nil {
+			return model.Member{}, err
+		}
+		if ex != nil {
+			mt.Exchange = &model.Exchange{Date: model.Date{Time: ex.Date}, OldNumber: string(ex.OldNumber),
+				FinalCounter: ex.FinalCounter, StartCounter: ex.StartCounter}
+		}
+		res.Meter = mt
+	}
+
+	res.Bill, err = d.modelBill(r, res.Meter != nil && res.Meter.Consumption != nil)
+	if err != nil {
+		return model.Member{}, err
+	}
+
+	return res, nil
+}
+
+// modelBill returns the latest bill of the member as a bill of a dataset,
+// nil if there is none. The lines are itemised unless they were imported
+// as sums. The water fee and the basic fee of a metered
+// member are included even if the bill has no lines for them.
+func (d *Data) modelBill(r *record, metered bool) (*model.Bill, error) {
+	var billID int64
+	var ref string
+	var months, date sql.NullString
+	var billed, itemised bool
+	err := d.tx.QueryRow("SELECT id, reference, months, date, billed, itemised FROM bills WHERE member_id = ? ORDER BY id DESC LIMIT 1", r.id).
+		Scan(&billID, &ref, &months, &date, &billed, &itemised)
+	if errNoRows(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query bill: %w", err)
+	}
+
+	res := &model.Bill{Reference: ref}
+	if months.Valid {
+		m, err := decimal.NewFromString(months.String)
+		if err != nil {
+			return nil, fmt.Errorf("parse months: %w", err)
+		}
+		res.Months = &m
+	}
+	if date.Valid {
+		t, err := parseDate(date.String)
+		if err != nil {
+			return nil, fmt.Errorf("parse bill date: %w", err)
+		}
+		res.Date = model.NewDate(t)
+	}
+
+	if !billed {
+		return res, nil
+	}
+	lines, err := d.billLines(billID)
+	if err != nil {
+		return nil, err
+	}
+
+	var water, basic []updater.BillLine
+	var additional decimal.Decimal
+	for _, l := range lines {
+		switch l.Kind {
+		case updater.WaterFee:
+			water = append(water, l)
+			if itemised {
+				res.WaterLines = append(res.WaterLines, modelLine(l))
+			}
+		case updater.BasicFee:
+			basic = append(basic, l)
+			if itemised {
+				res.BasicLines = append(res.BasicLines, modelLine(l))
+			}
+		default:
+			res.AdditionalCosts = append(res.AdditionalCosts, model.Cost{
+				Description: l.Description,
+				VAT:         l.VAT,
+				Amount:      model.Amount{WithoutTax: l.WithoutTax, Tax: l.Tax, WithTax: l.WithTax},
+			})
+			additional = additional.Add(l.WithTax)
+		}
+	}
+	if metered || len(water) > 0 {
+		res.WaterFee = sumAmount(water)
+	}
+	if metered || len(basic) > 0 {
+		res.BasicFee = sumAmount(basic)
+	}
+
+	total := updater.Bill{Lines: lines}.Total()
+	res.AdditionalTotal = &additional
+	res.Total = &total
+
+	return res, nil
+}
+
+func sumAmount(lines []updater.BillLine) *model.Amount {
+	sum := updater.SumLines("", lines)
+	return &model.Amount{WithoutTax: sum.WithoutTax, Tax: sum.Tax, WithTax: sum.WithTax}
+}
+
+// modelDate returns the date for the database, null if not set.
+func modelDate(d *model.Date) sql.NullString {
+	if d == nil {
+		return sql.NullString{}
+	}
+
+	return nullDate(d.Time)
+}
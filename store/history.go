@@ -0,0 +1,116 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jarnoan/vesimittari/meter"
+	"github.com/jarnoan/vesimittari/reference"
+	"github.com/jarnoan/vesimittari/updater"
+)
+
+// ErrNotFound is returned when there is no member with the id.
+var ErrNotFound = errors.New("not found")
+
+// ID returns the id of the member of the record. The id does not change
+// when members are added.
+func (d *Data) ID(mr updater.MeterRecord) (int64, error) {
+	r, err := d.record(mr)
+	if err != nil {
+		return 0, err
+	}
+
+	return r.id, nil
+}
+
+// Record returns the record of the member with the id.
+func (d *Data) Record(id int64) (updater.MeterRecord, error) {
+	r := &record{d: d, id: id}
+	err := d.tx.QueryRow("SELECT name FROM members WHERE id = ?", id).Scan(&r.name)
+	if errNoRows(err) {
+		return nil, fmt.Errorf("member %d: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query member %d: %w", id, err)
+	}
+
+	return r, nil
+}
+
+// Readings returns all the readings of the meters of the member, the
+// latest first.
+func (d *Data) Readings(mr updater.MeterRecord) ([]meter.Reading, error) {
+	r, err := d.record(mr)
+	if err != nil {
+		return nil, err
+	}
+
+	res, _, err := r.readings(-1) // no limit
+	return res, err
+}
+
+// Exchanges returns the exchanges of the meter of the member, the latest
+// first.
+func (d *Data) Exchanges(mr updater.MeterRecord) ([]meter.Exchange, error) {
+	r, err := d.record(mr)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.exchanges()
+}
+
+// DatedBill is a bill with the date it was issued.
+type DatedBill struct {
+	updater.Bill
+	Date time.Time // zero if not known
+}
+
+// Bills returns all the bills of the member, the latest first.
+func (d *Data) Bills(mr updater.MeterRecord) ([]DatedBill, error) {
+	r, err := d.record(mr)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.tx.Query("SELECT id, reference, date FROM bills WHERE member_id = ? AND billed ORDER BY id DESC", r.id)
+	if err != nil {
+		return nil, fmt.Errorf("query bills of %s: %w", r.name, err)
+	}
+	defer rows.Close()
+
+	type row struct {
+		id   int64
+		ref  string
+		date sql.NullString
+	}
+	var bills []row
+	for rows.Next() {
+		var b row
+		if err := rows.Scan(&b.id, &b.ref, &b.date); err != nil {
+			return nil, fmt.Errorf("scan bill: %w", err)
+		}
+		bills = append(bills, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var res []DatedBill
+	for _, b := range bills {
+		db := DatedBill{Bill: updater.Bill{Name: r.name, Reference: reference.Number(b.ref)}}
+		if b.date.Valid {
+			if db.Date, err = parseDate(b.date.String); err != nil {
+				return nil, fmt.Errorf("parse bill date: %w", err)
+			}
+		}
+		if db.Lines, err = d.billLines(b.id); err != nil {
+			return nil, fmt.Errorf("bill of %s: %w", r.name, err)
+		}
+		res = append(res, db)
+	}
+
+	return res, nil
+}
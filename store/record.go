@@ -0,0 +1,449 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jarnoan/vesimittari/meter"
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/jarnoan/vesimittari/reference"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+// record is a member in the database. It implements updater.MeterRecord.
+type record struct {
+	d    *Data
+	id   int64
+	name string
+	bill updater.Bill // bill calculated during this run
+}
+
+func (r *record) Name() string {
+	return r.name
+}
+
+func (r *record) MeterNumber() (meter.Number, error) {
+	_, num, err := r.meter()
+	return meter.Number(num), err
+}
+
+func (r *record) SiteNumber() (meter.SiteNumber, error) {
+	site, _, err := r.meter()
+	return meter.SiteNumber(site), err
+}
+
+// meter returns the site and the number of the meter, empty if the member
+// has no meter.
+func (r *record) meter() (string, string, error) {
+	var site, num string
+	err := r.d.tx.QueryRow("SELECT site, number FROM meters WHERE member_id = ?", r.id).Scan(&site, &num)
+	if err != nil && !errNoRows(err) {
+		return "", "", fmt.Errorf("query meter of %s: %w", r.name, err)
+	}
+
+	return site, num, nil
+}
+
+// Reference returns the reference of the latest bill of the member.
+func (r *record) Reference() reference.Number {
+	if r.bill.Reference != "" {
+		return r.bill.Reference
+	}
+
+	var res string
+	err := r.d.tx.QueryRow("SELECT reference FROM bills WHERE member_id = ? ORDER BY id DESC LIMIT 1", r.id).Scan(&res)
+	if err != nil && !errNoRows(err) && r.d.err == nil {
+		r.d.err = fmt.Errorf("query reference of %s: %w", r.name, err)
+	}
+
+	return reference.Number(res)
+}
+
+// Email returns the email address of the member.
+func (r *record) Email() string {
+	var res string
+	if err := r.d.tx.QueryRow("SELECT email FROM members WHERE id = ?", r.id).Scan(&res); err != nil && r.d.err == nil {
+		r.d.err = fmt.Errorf("query email of %s: %w", r.name, err)
+	}
+
+	return res
+}
+
+// Membership returns the period from the join date to the leave date.
+// Start or End is zero if the corresponding date is not set.
+func (r *record) Membership() (period.Period, error) {
+	var join, leave sql.NullString
+	if err := r.d.tx.QueryRow("SELECT join_date, leave_date FROM members WHERE id = ?", r.id).Scan(&join, &leave); err != nil {
+		return period.Period{}, fmt.Errorf("query membership of %s: %w", r.name, err)
+	}
+
+	var res period.Period
+	var err error
+	if join.Valid {
+		if res.Start, err = parseDate(join.String); err != nil {
+			return res, fmt.Errorf("join date: %w", err)
+		}
+	}
+	if leave.Valid {
+		if res.End, err = parseDate(leave.String); err != nil {
+			return res, fmt.Errorf("leave date: %w", err)
+		}
+	}
+
+	return res, nil
+}
+
+// readings returns the latest readings of the meter, the latest first.
+func (r *record) readings(n int) ([]meter.Reading, []int64, error) {
+	rows, err := r.d.tx.Query(`SELECT r.id, r.date, r.counter, r.checked FROM readings r
+		JOIN meters m ON m.id = r.meter_id WHERE m.member_id = ?
+		ORDER BY r.date DESC, r.id DESC LIMIT ?`, r.id, n)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query readings of %s: %w", r.name, err)
+	}
+	defer rows.Close()
+
+	var res []meter.Reading
+	var ids []int64
+	for rows.Next() {
+		var rdg meter.Reading
+		var id int64
+		var date sql.NullString
+		if err := rows.Scan(&id, &date, &rdg.Counter, &rdg.Customer); err != nil {
+			return nil, nil, fmt.Errorf("scan reading of %s: %w", r.name, err)
+		}
+		if date.Valid {
+			if rdg.Date, err = parseDate(date.String); err != nil {
+				return nil, nil, fmt.Errorf("reading of %s: %w", r.name, err)
+			}
+		}
+		res = append(res, rdg)
+		ids = append(ids, id)
+	}
+
+	return res, ids, rows.Err()
+}
+
+// Reading returns the latest reading of the meter.
+func (r *record) Reading() (meter.Reading, error) {
+	rdgs, _, err := r.readings(1)
+	if err != nil {
+		return meter.Reading{}, err
+	}
+	if len(rdgs) == 0 {
+		return meter.Reading{}, fmt.Errorf("no readings for %s", r.name)
+	}
+
+	return rdgs[0], nil
+}
+
+// PreviousReading returns the reading before the latest one. The date of
+// the reading is zero if there is no previous reading.
+func (r *record) PreviousReading() (meter.Reading, error) {
+	rdgs, _, err := r.readings(2)
+	if err != nil || len(rdgs) < 2 {
+		return meter.Reading{}, err
+	}
+
+	return meter.Reading{Counter: rdgs[1].Counter, Date: rdgs[1].Date}, nil
+}
+
+// Consumption returns the consumption between the previous and the latest
+// reading in m³.
+func (r *record) Consumption() (int, error) {
+	var res sql.NullInt64
+	err := r.d.tx.QueryRow("SELECT consumption FROM meters WHERE member_id = ?", r.id).Scan(&res)
+	if err != nil && !errNoRows(err) {
+		return 0, fmt.Errorf("query consumption of %s: %w", r.name, err)
+	}
+	if !res.Valid {
+		return 0, fmt.Errorf("no consumption for %s", r.name)
+	}
+
+	return int(res.Int64), nil
+}
+
+// AddReading adds the reading as the latest one. A meter exchange since
+// the previous reading is included in the consumption.
+func (r *record) AddReading(rdg meter.Reading) error {
+	prev, err := r.Reading()
+	if err != nil {
+		return fmt.Errorf("previous reading: %w", err)
+	}
+
+	ex, err := r.exchange(prev.Date, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	var cons int
+	if ex != nil {
+		if !rdg.Date.After(ex.Date) {
+			return fmt.Errorf("reading on %s is not after the meter exchange on %s", rdg.Date.Format(dateFormat), ex.Date.Format(dateFormat))
+		}
+		cons, err = ex.Consumption(prev.Counter, rdg.Counter, rdg.Digits)
+	} else {
+		cons, err = meter.Consumption(prev.Counter, rdg.Counter, rdg.Digits)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = r.d.tx.Exec(`INSERT INTO readings (meter_id, date, counter, checked)
+		SELECT id, ?, ?, ? FROM meters WHERE member_id = ?`,
+		rdg.Date.Format(dateFormat), rdg.Counter, rdg.Customer, r.id)
+	if err != nil {
+		return fmt.Errorf("add reading of %s: %w", r.name, err)
+	}
+
+	return r.setConsumption(cons)
+}
+
+// CorrectReading replaces the counter of the latest reading and
+// recalculates the consumption. The date must match the latest reading.
+func (r *record) CorrectReading(rdg meter.Reading) error {
+	rdgs, ids, err := r.readings(2)
+	if err != nil {
+		return err
+	}
+	if len(rdgs) < 2 {
+		return fmt.Errorf("no previous reading for %s", r.name)
+	}
+	if !rdgs[0].Date.Equal(rdg.Date) {
+		return fmt.Errorf("latest reading is from %s, not %s", rdgs[0].Date.Format(dateFormat), rdg.Date.Format(dateFormat))
+	}
+
+	ex, err := r.exchange(rdgs[1].Date, rdgs[0].Date)
+	if err != nil {
+		return err
+	}
+
+	var cons int
+	if ex != nil {
+		cons, err = ex.Consumption(rdgs[1].Counter, rdg.Counter, rdg.Digits)
+	} else {
+		cons, err = meter.Consumption(rdgs[1].Counter, rdg.Counter, rdg.Digits)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.d.tx.Exec("UPDATE readings SET counter = ? WHERE id = ?", rdg.Counter, ids[0]); err != nil {
+		return fmt.Errorf("correct reading of %s: %w", r.name, err)
+	}
+
+	return r.setConsumption(cons)
+}
+
+// ExchangeMeter replaces the meter of the member. The exchange is
+// recorded with the final counter of the old meter and the start counter
+// of the new one, so that the next reading yields the consumption of both.
+func (r *record) ExchangeMeter(ex meter.Exchange) error {
+	latest, err := r.Reading()
+	if err != nil {
+		return err
+	}
+	if ex.Date.Before(latest.Date) {
+		return fmt.Errorf("exchange date %s is before the latest reading %s", ex.Date.Format(dateFormat), latest.Date.Format(dateFormat))
+	}
+
+	pending, err := r.exchange(latest.Date, time.Time{})
+	if err != nil {
+		return err
+	}
+	if pending != nil {
+		return fmt.Errorf("meter already exchanged on %s and not read since", pending.Date.Format(dateFormat))
+	}
+
+	if _, err := meter.Consumption(latest.Counter, ex.FinalCounter, ex.Digits); err != nil {
+		return fmt.Errorf("final counter of old meter: %w", err)
+	}
+
+	_, err = r.d.tx.Exec(`INSERT INTO exchanges (meter_id, date, old_number, final_counter, new_number, start_counter)
+		SELECT id, ?, number, ?, ?, ? FROM meters WHERE member_id = ?`,
+		ex.Date.Format(dateFormat), ex.FinalCounter, string(ex.NewNumber), ex.StartCounter, r.id)
+	if err != nil {
+		return fmt.Errorf("add exchange of %s: %w", r.name, err)
+	}
+	if _, err := r.d.tx.Exec("UPDATE meters SET number = ? WHERE member_id = ?", string(ex.NewNumber), r.id); err != nil {
+		return fmt.Errorf("exchange meter of %s: %w", r.name, err)
+	}
+
+	return nil
+}
+
+// exchanges returns the exchanges of the meter, the latest first.
+func (r *record) exchanges() ([]meter.Exchange, error) {
+	rows, err := r.d.tx.Query(`SELECT e.date, e.old_number, e.final_counter, e.new_number, e.start_counter
+		FROM exchanges e JOIN meters m ON m.id = e.meter_id WHERE m.member_id = ?
+		ORDER BY e.date DESC, e.id DESC`, r.id)
+	if err != nil {
+		return nil, fmt.Errorf("query exchanges of %s: %w", r.name, err)
+	}
+	defer rows.Close()
+
+	var res []meter.Exchange
+	for rows.Next() {
+		var ex meter.Exchange
+		var date, oldNum, newNum string
+		if err := rows.Scan(&date, &oldNum, &ex.FinalCounter, &newNum, &ex.StartCounter); err != nil {
+			return nil, fmt.Errorf("scan exchange of %s: %w", r.name, err)
+		}
+		if ex.Date, err = parseDate(date); err != nil {
+			return nil, fmt.Errorf("exchange of %s: %w", r.name, err)
+		}
+		ex.OldNumber, ex.NewNumber = meter.Number(oldNum), meter.Number(newNum)
+		res = append(res, ex)
+	}
+
+	return res, rows.Err()
+}
+
+// exchange returns the latest exchange of the meter from the date on and
+// before the end date, nil if there is none. A zero end is unbounded.
+func (r *record) exchange(from, to time.Time) (*meter.Exchange, error) {
+	exs, err := r.exchanges()
+	if err != nil {
+		return nil, err
+	}
+
+	return exchangeWithin(exs, from, to), nil
+}
+
+// exchangeWithin returns the latest of the exchanges, the latest first,
+// from the date on and before the end date. A zero end is unbounded.
+func exchangeWithin(exs []meter.Exchange, from, to time.Time) *meter.Exchange {
+	for i, ex := range exs {
+		if !ex.Date.Before(from) && (to.IsZero() || ex.Date.Before(to)) {
+			return &exs[i]
+		}
+	}
+
+	return nil
+}
+
+func (r *record) setConsumption(cons int) error {
+	if _, err := r.d.tx.Exec("UPDATE meters SET consumption = ? WHERE member_id = ?", cons, r.id); err != nil {
+		return fmt.Errorf("set consumption of %s: %w", r.name, err)
+	}
+
+	return nil
+}
+
+var one = decimal.NewFromInt(1)
+
+func (r *record) UpdateBilling(ref reference.Number, cv updater.CommonVariables, acs []updater.AdditionalCost) error {
+	var lines []updater.BillLine
+	var months sql.NullString
+
+	// Basic fee and consumption are billed only from members who have a water meter
+	var cons sql.NullInt64
+	err := r.d.tx.QueryRow("SELECT consumption FROM meters WHERE member_id = ?", r.id).Scan(&cons)
+	if err != nil && !errNoRows(err) {
+		return fmt.Errorf("query consumption: %w", err)
+	}
+	if cons.Valid {
+		rdgs, _, err := r.readings(2)
+		if err != nil {
+			return err
+		}
+		if len(rdgs) < 2 || rdgs[1].Date.IsZero() || rdgs[0].Date.IsZero() {
+			return fmt.Errorf("no dated previous and latest readings for %s", r.name)
+		}
+		if rdgs[0].Date.Before(rdgs[1].Date) {
+			return fmt.Errorf("meter date %s is before previous date %s", rdgs[0].Date.Format(dateFormat), rdgs[1].Date.Format(dateFormat))
+		}
+
+		membership, err := r.Membership()
+		if err != nil {
+			return err
+		}
+
+		var m decimal.Decimal
+		lines, m = updater.MeteredLines(decimal.NewFromInt(cons.Int64), period.New(rdgs[1].Date, rdgs[0].Date), membership, cv)
+		months = sql.NullString{String: m.String(), Valid: true}
+	}
+
+	// Additional costs are billed from all members
+	for _, ac := range acs {
+		lines = append(lines, updater.NewBillLine(updater.AdditionalFee, ac.Description, one, "", ac.Cost, ac.VAT))
+	}
+
+	r.bill = updater.Bill{Name: r.name, Reference: ref, Lines: lines}
+
+	id, err := r.d.insertBill(r.id, r.bill, months, sql.NullString{}, true)
+	if err != nil {
+		return err
+	}
+	r.d.undated = append(r.d.undated, id)
+
+	return nil
+}
+
+// Bill returns the bill calculated by UpdateBilling during this run, or
+// the latest bill of the member in the database.
+func (r *record) Bill() (updater.Bill, error) {
+	if r.bill.Reference != "" {
+		return r.bill, nil
+	}
+
+	res := updater.Bill{Name: r.name}
+	var billID int64
+	var ref string
+	err := r.d.tx.QueryRow("SELECT id, reference FROM bills WHERE member_id = ? ORDER BY id DESC LIMIT 1", r.id).Scan(&billID, &ref)
+	if errNoRows(err) {
+		return res, nil
+	}
+	if err != nil {
+		return res, fmt.Errorf("query bill of %s: %w", r.name, err)
+	}
+	res.Reference = reference.Number(ref)
+
+	res.Lines, err = r.d.billLines(billID)
+	if err != nil {
+		return res, fmt.Errorf("bill of %s: %w", r.name, err)
+	}
+
+	return res, nil
+}
+
+// Total returns the total of the latest bill, zero if there is none.
+func (r *record) Total() (decimal.Decimal, error) {
+	b, err := r.Bill()
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	return b.Total(), nil
+}
+
+// billLines returns the lines of the bill.
+func (d *Data) billLines(billID int64) ([]updater.BillLine, error) {
+	rows, err := d.tx.Query(`SELECT kind, description, quantity, unit, unit_price, vat, without_tax, tax, with_tax
+		FROM bill_lines WHERE bill_id = ? ORDER BY position`, billID)
+	if err != nil {
+		return nil, fmt.Errorf("query bill lines: %w", err)
+	}
+	defer rows.Close()
+
+	var res []updater.BillLine
+	for rows.Next() {
+		var l updater.BillLine
+		var kind int
+		var decs [6]string
+		if err := rows.Scan(&kind, &l.Description, &decs[0], &l.Unit, &decs[1], &decs[2], &decs[3], &decs[4], &decs[5]); err != nil {
+			return nil, fmt.Errorf("scan bill line: %w", err)
+		}
+		l.Kind = updater.LineKind(kind)
+		for i, dst := range []*decimal.Decimal{&l.Quantity, &l.UnitPrice, &l.VAT, &l.WithoutTax, &l.Tax, &l.WithTax} {
+			if *dst, err = decimal.NewFromString(decs[i]); err != nil {
+				return nil, fmt.Errorf("parse bill line: %w", err)
+			}
+		}
+		res = append(res, l)
+	}
+
+	return res, rows.Err()
+}
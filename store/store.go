@@ -0,0 +1,561 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+
+	_ "github.com/mattn/go-sqlite3" // database/sql driver "sqlite3"
+)
+
+// dateFormat is the format of the dates in the database.
+const dateFormat = "2006-01-02"
+
+// schema creates the tables. The decimals are stored as text to keep them
+// exact and the dates as text like 2022-12-31.
+const schema = `
+CREATE TABLE IF NOT EXISTS members (
+	id                  INTEGER PRIMARY KEY,
+	position            INTEGER NOT NULL, -- order of the members, the main meter first
+	name                TEXT NOT NULL,
+	bank_account        TEXT NOT NULL DEFAULT '',
+	phone               TEXT NOT NULL DEFAULT '',
+	email               TEXT NOT NULL DEFAULT '',
+	street_address      TEXT NOT NULL DEFAULT '',
+	postal_code         TEXT NOT NULL DEFAULT '',
+	city                TEXT NOT NULL DEFAULT '',
+	property_id         TEXT NOT NULL DEFAULT '',
+	tenants             TEXT NOT NULL DEFAULT '',
+	permanent_residency TEXT NOT NULL DEFAULT '',
+	join_date           TEXT,
+	leave_date          TEXT
+);
+
+CREATE TABLE IF NOT EXISTS meters (
+	id          INTEGER PRIMARY KEY,
+	member_id   INTEGER NOT NULL UNIQUE REFERENCES members(id) ON DELETE CASCADE,
+	site        TEXT NOT NULL DEFAULT '',
+	number      TEXT NOT NULL DEFAULT '',
+	consumption INTEGER -- m³ between the two latest readings
+);
+
+CREATE TABLE IF NOT EXISTS readings (
+	id       INTEGER PRIMARY KEY,
+	meter_id INTEGER NOT NULL REFERENCES meters(id) ON DELETE CASCADE,
+	date     TEXT,
+	counter  INTEGER NOT NULL,
+	checked  TEXT NOT NULL DEFAULT '' -- reading reported by the member
+);
+
+CREATE TABLE IF NOT EXISTS tariffs (
+	id             INTEGER PRIMARY KEY CHECK (id = 1),
+	main_meter_fee TEXT NOT NULL, -- € per month without tax
+	vat            TEXT NOT NULL  -- %, the general rate
+);
+
+CREATE TABLE IF NOT EXISTS price_components (
+	id       INTEGER PRIMARY KEY,
+	position INTEGER NOT NULL UNIQUE, -- order on the bills
+	label    TEXT NOT NULL,
+	vat      TEXT NOT NULL, -- %
+	price    TEXT NOT NULL  -- €/m³ without tax
+);
+
+CREATE TABLE IF NOT EXISTS price_tiers (
+	component_id INTEGER NOT NULL REFERENCES price_components(id) ON DELETE CASCADE,
+	above        TEXT NOT NULL, -- m³ per year
+	price        TEXT NOT NULL, -- €/m³ without tax
+	PRIMARY KEY (component_id, above)
+);
+
+CREATE TABLE IF NOT EXISTS vat_changes (
+	date TEXT PRIMARY KEY,
+	old  TEXT NOT NULL, -- % before the date
+	new  TEXT NOT NULL  -- % from the date on
+);
+
+CREATE TABLE IF NOT EXISTS exchanges (
+	id            INTEGER PRIMARY KEY,
+	meter_id      INTEGER NOT NULL REFERENCES meters(id) ON DELETE CASCADE,
+	date          TEXT NOT NULL,
+	old_number    TEXT NOT NULL,
+	final_counter INTEGER NOT NULL, -- of the old meter
+	new_number    TEXT NOT NULL,
+	start_counter INTEGER NOT NULL  -- of the new meter
+);
+
+CREATE TABLE IF NOT EXISTS billing (
+	id           INTEGER PRIMARY KEY CHECK (id = 1),
+	date         TEXT NOT NULL, -- latest billing date
+	payment_days INTEGER NOT NULL,
+	message      TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS bills (
+	id        INTEGER PRIMARY KEY,
+	member_id INTEGER NOT NULL REFERENCES members(id) ON DELETE CASCADE,
+	reference TEXT NOT NULL,
+	months    TEXT, -- of basic fee
+	date      TEXT, -- issued, null if not known
+	billed    INTEGER NOT NULL, -- 0 if only the reference is known, like that of the main meter
+	itemised  INTEGER NOT NULL DEFAULT 1 -- 0 if the water and basic fee lines are sums imported from the columns
+);
+
+CREATE TABLE IF NOT EXISTS bill_lines (
+	bill_id     INTEGER NOT NULL REFERENCES bills(id) ON DELETE CASCADE,
+	position    INTEGER NOT NULL,
+	kind        INTEGER NOT NULL,
+	description TEXT NOT NULL,
+	quantity    TEXT NOT NULL,
+	unit        TEXT NOT NULL,
+	unit_price  TEXT NOT NULL,
+	vat         TEXT NOT NULL,
+	without_tax TEXT NOT NULL,
+	tax         TEXT NOT NULL,
+	with_tax    TEXT NOT NULL,
+	PRIMARY KEY (bill_id, position)
+);
+`
+
+// Store is the data of the cooperative in an SQLite database. Unlike the
+// data file it keeps the history of the readings and the bills.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens the database, creating it if it does not exist.
+func Open(name string) (*Store, error) {
+	dsn := url.URL{Scheme: "file", Opaque: url.PathEscape(name), RawQuery: "_foreign_keys=on"}
+	db, err := sql.Open("sqlite3", dsn.String())
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create schema: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Begin starts a transaction for reading or changing the data.
+func (s *Store) Begin() (*Data, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+
+	return &Data{tx: tx}, nil
+}
+
+// Data is the data in a transaction of the database. It implements
+// updater.Data, updater.HandoverData and updater.CorrectionData. The
+// changes are stored by Commit.
+type Data struct {
+	tx      *sql.Tx
+	err     error   // of a method that cannot return it
+	undated []int64 // bills calculated during this run, dated by SetDate
+}
+
+// Commit stores the changes. It fails if any change failed.
+func (d *Data) Commit() error {
+	if d.err != nil {
+		d.tx.Rollback()
+		return d.err
+	}
+
+	return d.tx.Commit()
+}
+
+// Rollback discards the changes.
+func (d *Data) Rollback() error {
+	return d.tx.Rollback()
+}
+
+func (d *Data) MeterRecords() ([]updater.MeterRecord, error) {
+	rows, err := d.tx.Query("SELECT id, name FROM members ORDER BY position")
+	if err != nil {
+		return nil, fmt.Errorf("query members: %w", err)
+	}
+	defer rows.Close()
+
+	var res []updater.MeterRecord
+	for rows.Next() {
+		r := &record{d: d}
+		if err := rows.Scan(&r.id, &r.name); err != nil {
+			return nil, fmt.Errorf("scan member: %w", err)
+		}
+		res = append(res, r)
+	}
+
+	return res, rows.Err()
+}
+
+// Date returns the billing date.
+func (d *Data) Date() (time.Time, error) {
+	var s string
+	if err := d.tx.QueryRow("SELECT date FROM billing").Scan(&s); err != nil {
+		return time.Time{}, fmt.Errorf("query billing date: %w", err)
+	}
+
+	return parseDate(s)
+}
+
+// SetDate sets the billing date. It is also the date of the bills
+// calculated during this run. An error is returned by Commit.
+func (d *Data) SetDate(t time.Time) {
+	if _, err := d.tx.Exec("UPDATE billing SET date = ?", t.Format(dateFormat)); err != nil && d.err == nil {
+		d.err = fmt.Errorf("set billing date: %w", err)
+	}
+	for _, id := range d.undated {
+		if _, err := d.tx.Exec("UPDATE bills SET date = ? WHERE id = ?", t.Format(dateFormat), id); err != nil && d.err == nil {
+			d.err = fmt.Errorf("set bill date: %w", err)
+		}
+	}
+	d.undated = nil
+}
+
+// PaymentDays returns how many days there's time to pay.
+func (d *Data) PaymentDays() (int, error) {
+	var res int
+	if err := d.tx.QueryRow("SELECT payment_days FROM billing").Scan(&res); err != nil {
+		return 0, fmt.Errorf("query payment days: %w", err)
+	}
+
+	return res, nil
+}
+
+// Message returns the message to the members.
+func (d *Data) Message() (string, error) {
+	var res string
+	if err := d.tx.QueryRow("SELECT message FROM billing").Scan(&res); err != nil {
+		return "", fmt.Errorf("query message: %w", err)
+	}
+
+	return res, nil
+}
+
+func (d *Data) CommonVariables() (updater.CommonVariables, error) {
+	var fee, vat string
+	if err := d.tx.QueryRow("SELECT main_meter_fee, vat FROM tariffs").Scan(&fee, &vat); err != nil {
+		return updater.CommonVariables{}, fmt.Errorf("query tariffs: %w", err)
+	}
+
+	var res updater.CommonVariables
+	var err error
+	if res.MainMeterFee, err = decimal.NewFromString(fee); err != nil {
+		return updater.CommonVariables{}, fmt.Errorf("parse main meter fee: %w", err)
+	}
+	if res.VAT, err = decimal.NewFromString(vat); err != nil {
+		return updater.CommonVariables{}, fmt.Errorf("parse VAT: %w", err)
+	}
+	if res.WaterPrices, err = d.waterPrices(); err != nil {
+		return updater.CommonVariables{}, err
+	}
+	if res.VATChanges, err = d.vatChanges(); err != nil {
+		return updater.CommonVariables{}, err
+	}
+
+	return res, nil
+}
+
+// waterPrices returns the components of the water price with their tiers.
+func (d *Data) waterPrices() ([]updater.PriceComponent, error) {
+	rows, err := d.tx.Query(`SELECT c.id, c.label, c.vat, c.price, t.above, t.price
+		FROM price_components c LEFT JOIN price_tiers t ON t.component_id = c.id
+		ORDER BY c.position, CAST(t.above AS REAL)`)
+	if err != nil {
+		return nil, fmt.Errorf("query water prices: %w", err)
+	}
+	defer rows.Close()
+
+	var res []updater.PriceComponent
+	lastID := int64(-1)
+	for rows.Next() {
+		var id int64
+		var label, vat, price string
+		var above, tierPrice sql.NullString
+		if err := rows.Scan(&id, &label, &vat, &price, &above, &tierPrice); err != nil {
+			return nil, fmt.Errorf("scan water price: %w", err)
+		}
+
+		if id != lastID {
+			pc := updater.PriceComponent{Label: label}
+			if pc.VAT, err = decimal.NewFromString(vat); err != nil {
+				return nil, fmt.Errorf("parse VAT of %s: %w", label, err)
+			}
+			if pc.Price, err = decimal.NewFromString(price); err != nil {
+				return nil, fmt.Errorf("parse price of %s: %w", label, err)
+			}
+			res = append(res, pc)
+			lastID = id
+		}
+
+		if above.Valid {
+			var t updater.PriceTier
+			if t.Above, err = decimal.NewFromString(above.String); err != nil {
+				return nil, fmt.Errorf("parse tier of %s: %w", label, err)
+			}
+			if t.Price, err = decimal.NewFromString(tierPrice.String); err != nil {
+				return nil, fmt.Errorf("parse tier price of %s: %w", label, err)
+			}
+			pc := &res[len(res)-1]
+			pc.Tiers = append(pc.Tiers, t)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no water prices")
+	}
+
+	return res, nil
+}
+
+// SetWaterPrices replaces the components of the water price.
+func (d *Data) SetWaterPrices(pcs []updater.PriceComponent) error {
+	if _, err := d.tx.Exec("DELETE FROM price_components"); err != nil {
+		return fmt.Errorf("clear water prices: %w", err)
+	}
+
+	for i, pc := range pcs {
+		res, err := d.tx.Exec("INSERT INTO price_components (position, label, vat, price) VALUES (?, ?, ?, ?)",
+			i, pc.Label, pc.VAT.String(), pc.Price.String())
+		if err != nil {
+			return fmt.Errorf("add water price %s: %w", pc.Label, err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("add water price %s: %w", pc.Label, err)
+		}
+
+		for _, t := range pc.Tiers {
+			_, err := d.tx.Exec("INSERT INTO price_tiers (component_id, above, price) VALUES (?, ?, ?)",
+				id, t.Above.String(), t.Price.String())
+			if err != nil {
+				return fmt.Errorf("add tier of %s: %w", pc.Label, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// vatChanges returns the changes of the VAT rates in ascending order of date.
+func (d *Data) vatChanges() ([]updater.VATChange, error) {
+	rows, err := d.tx.Query("SELECT date, old, new FROM vat_changes ORDER BY date")
+	if err != nil {
+		return nil, fmt.Errorf("query VAT changes: %w", err)
+	}
+	defer rows.Close()
+
+	var res []updater.VATChange
+	for rows.Next() {
+		var date, old, new string
+		if err := rows.Scan(&date, &old, &new); err != nil {
+			return nil, fmt.Errorf("scan VAT change: %w", err)
+		}
+
+		var c updater.VATChange
+		if c.Date, err = parseDate(date); err != nil {
+			return nil, fmt.Errorf("VAT change: %w", err)
+		}
+		if c.Old, err = decimal.NewFromString(old); err != nil {
+			return nil, fmt.Errorf("parse previous VAT: %w", err)
+		}
+		if c.New, err = decimal.NewFromString(new); err != nil {
+			return nil, fmt.Errorf("parse new VAT: %w", err)
+		}
+		res = append(res, c)
+	}
+
+	return res, rows.Err()
+}
+
+// SetVATChanges replaces the changes of the VAT rates.
+func (d *Data) SetVATChanges(changes []updater.VATChange) error {
+	if _, err := d.tx.Exec("DELETE FROM vat_changes"); err != nil {
+		return fmt.Errorf("clear VAT changes: %w", err)
+	}
+
+	for _, c := range changes {
+		_, err := d.tx.Exec("INSERT INTO vat_changes (date, old, new) VALUES (?, ?, ?)",
+			c.Date.Format(dateFormat), c.Old.String(), c.New.String())
+		if err != nil {
+			return fmt.Errorf("add VAT change: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Transfer ends the membership of the seller at the date and adds a
+// member for the buyer right after it. The buyer starts from the seller's
+// latest reading and keeps the site, meter and property details.
+func (d *Data) Transfer(seller updater.MeterRecord, buyer string, date time.Time) (updater.MeterRecord, error) {
+	sr, err := d.record(seller)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := d.tx.Exec("UPDATE members SET leave_date = ? WHERE id = ?", date.Format(dateFormat), sr.id); err != nil {
+		return nil, fmt.Errorf("end membership of %s: %w", sr.name, err)
+	}
+
+	id, err := d.insertAfter(sr.id, `INSERT INTO members (position, name, street_address, postal_code, city, property_id, join_date)
+		SELECT ?, ?, street_address, postal_code, city, property_id, ? FROM members WHERE id = ?`,
+		buyer, date.Format(dateFormat), sr.id)
+	if err != nil {
+		return nil, fmt.Errorf("add buyer %s: %w", buyer, err)
+	}
+
+	res, err := d.tx.Exec("INSERT INTO meters (member_id, site, number) SELECT ?, site, number FROM meters WHERE member_id = ?", id, sr.id)
+	if err != nil {
+		return nil, fmt.Errorf("add meter of %s: %w", buyer, err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		_, err := d.tx.Exec(`INSERT INTO readings (meter_id, date, counter)
+			SELECT (SELECT id FROM meters WHERE member_id = ?), r.date, r.counter FROM readings r
+			JOIN meters m ON m.id = r.meter_id WHERE m.member_id = ? ORDER BY r.date DESC, r.id DESC LIMIT 1`, id, sr.id)
+		if err != nil {
+			return nil, fmt.Errorf("add reading of %s: %w", buyer, err)
+		}
+	}
+
+	return &record{d: d, id: id, name: buyer}, nil
+}
+
+// AddCorrection adds a member for the correction bill right after the
+// member. The membership ends when it starts so that it is never billed.
+func (d *Data) AddCorrection(mr updater.MeterRecord, b updater.Bill, date time.Time) error {
+	r, err := d.record(mr)
+	if err != nil {
+		return err
+	}
+
+	id, err := d.insertAfter(r.id, `INSERT INTO members (position, name, bank_account, phone, email,
+			street_address, postal_code, city, property_id, join_date, leave_date)
+		SELECT ?, name, bank_account, phone, email, street_address, postal_code, city, property_id, ?, ?
+		FROM members WHERE id = ?`,
+		date.Format(dateFormat), date.Format(dateFormat), r.id)
+	if err != nil {
+		return fmt.Errorf("add correction of %s: %w", r.name, err)
+	}
+
+	// The consumption and months are the differences to the original bill
+	var cons, months decimal.Decimal
+	metered := false
+	for _, l := range b.Lines {
+		switch l.Kind {
+		case updater.WaterFee:
+			cons = cons.Add(l.Quantity)
+			metered = true
+		case updater.BasicFee:
+			months = months.Add(l.Quantity)
+			metered = true
+		}
+	}
+
+	var monthsText sql.NullString
+	if metered {
+		if _, err := d.tx.Exec("INSERT INTO meters (member_id, consumption) VALUES (?, ?)", id, cons.IntPart()); err != nil {
+			return fmt.Errorf("add correction consumption: %w", err)
+		}
+		monthsText = sql.NullString{String: months.String(), Valid: true}
+	}
+
+	_, err = d.insertBill(id, b, monthsText, nullDate(date), true)
+	return err
+}
+
+// record returns the record of the database.
+func (d *Data) record(mr updater.MeterRecord) (*record, error) {
+	r, ok := mr.(*record)
+	if !ok || r.d != d {
+		return nil, fmt.Errorf("%s is not a member of the database", mr.Name())
+	}
+
+	return r, nil
+}
+
+// insertAfter inserts a member right after the member with the id. The
+// first argument of the query is the position. It returns the id of the
+// new member.
+func (d *Data) insertAfter(id int64, query string, args ...interface{}) (int64, error) {
+	var pos int64
+	if err := d.tx.QueryRow("SELECT position FROM members WHERE id = ?", id).Scan(&pos); err != nil {
+		return 0, err
+	}
+	if _, err := d.tx.Exec("UPDATE members SET position = position + 1 WHERE position > ?", pos); err != nil {
+		return 0, err
+	}
+
+	res, err := d.tx.Exec(query, append([]interface{}{pos + 1}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+// insertBill stores the bill of the member and returns its id. An
+// unbilled bill only reserves the reference.
+func (d *Data) insertBill(memberID int64, b updater.Bill, months, date sql.NullString, billed bool) (int64, error) {
+	res, err := d.tx.Exec("INSERT INTO bills (member_id, reference, months, date, billed) VALUES (?, ?, ?, ?, ?)",
+		memberID, string(b.Reference), months, date, billed)
+	if err != nil {
+		return 0, fmt.Errorf("add bill: %w", err)
+	}
+	billID, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("add bill: %w", err)
+	}
+
+	for i, l := range b.Lines {
+		_, err := d.tx.Exec(`INSERT INTO bill_lines (bill_id, position, kind, description, quantity, unit,
+				unit_price, vat, without_tax, tax, with_tax)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			billID, i, int(l.Kind), l.Description, l.Quantity.String(), l.Unit,
+			l.UnitPrice.String(), l.VAT.String(), l.WithoutTax.String(), l.Tax.String(), l.WithTax.String())
+		if err != nil {
+			return 0, fmt.Errorf("add bill line: %w", err)
+		}
+	}
+
+	return billID, nil
+}
+
+// nullDate returns the date for the database, null if it is zero.
+func nullDate(t time.Time) sql.NullString {
+	if t.IsZero() {
+		return sql.NullString{}
+	}
+
+	return sql.NullString{String: t.Format(dateFormat), Valid: true}
+}
+
+func parseDate(s string) (time.Time, error) {
+	res, err := time.Parse(dateFormat, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse date: %w", err)
+	}
+
+	return res, nil
+}
+
+// errNoRows tells whether the error is about a missing row.
+func errNoRows(err error) bool {
+	return errors.Is(err, sql.ErrNoRows)
+}
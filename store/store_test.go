@@ -0,0 +1,257 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarnoan/vesimittari/csv"
+	"github.com/jarnoan/vesimittari/meter"
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/jarnoan/vesimittari/updater"
+	"github.com/shopspring/decimal"
+)
+
+const testData = `Nimi,Tilinumero,Puhelin,Sähköposti,Katuosoite,Postinumero,Postitoimipaikka,Kiinteistötunnus,Asukkaita,Vakituinen asunto,Liittynyt,Eronnut,Mittauspaikka,Mittari,Edellinen lukema,Edellinen pvm,Lukema,Pvm,Tarkistus,Kulutus,Vesimaksu alv 0,Vesimaksu alv,Vesimaksu,Kk,Perusmaksu alv 0,Perusmaksu alv,Perusmaksu,Lisämaksujen selite,Lisämaksut,Yhteensä,Viite
+Pää,,,,,,,,,,,,1,M0,900,1.1.2022,1000,1.7.2022,,100,,,,,,,,,,,1000012
+Aho,FI21 1234 5600 0007 85,+358401234567,aho@example.com,Tie 1,12345,Kylä,91-404-1-123,2,k,,,2,M1,100,1.1.2022,140,1.7.2022,141,40,"75,00","18,00","93,00","5,95","59,51","14,28","73,79","Vakuutus 33,92 + ALV 0 % 0,00 = 33,92; Huolto 10,18 + ALV 25,5 % 2,60 = 12,78","46,70","213,49",1000038
+Aho,,,,,,,,,,19.10.2026,19.10.2026,,,,,,,,-10,"-15,00","-3,60","-18,60","0,00","0,00","0,00","0,00",,"0,00","-18,60",1000067
+Bäck,,,,,,,,,,,,3,M2,50,1.1.2022,110,1.7.2022,,60,,,,,,,,,,,1000025
+Lähtenyt,,,,,,,,,,,1.12.2021,4,M3,10,1.4.2021,30,1.12.2021,,20,,,,,,,,,,,
+Mökki,,,,,,,,,,,,,,,,,,,,,,,,,,,"Vakuutus 33,91 + ALV 0 % 0,00 = 33,91","33,91","33,91",1000054
+###,,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Päivä,30.6.2022,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Maksuaika,14,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Päämittari,"30,00",,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Vesi,"1,525",,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+ALV,"25,5",24,1.9.2024,,,,,,,,,,,,,,,,,,,,,,,,,,,
+Viesti,Hei,,,,,,,,,,,,,,,,,,,,,,,,,,,,,
+`
+
+// testStore returns a store with the data imported.
+func testStore(t *testing.T, data string) *Store {
+	t.Helper()
+
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	f, err := csv.Read(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := f.Dataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := s.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Import(ds); err != nil {
+		d.Rollback()
+		t.Fatal(err)
+	}
+	if err := d.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// exportCSV returns the data of the store in the CSV layout.
+func exportCSV(t *testing.T, s *Store) string {
+	t.Helper()
+
+	d, err := s.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Rollback()
+
+	ds, err := d.Dataset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := csv.FromDataset(ds).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.String()
+}
+
+func TestData_Import(t *testing.T) {
+	s := testStore(t, testData)
+
+	if got := exportCSV(t, s); got != testData {
+		t.Errorf("round trip changed the data:\n%s", got)
+	}
+}
+
+func TestData_Update(t *testing.T) {
+	opts := updater.Options{MonthConvention: period.Actual365, Date: time.Date(2022, 7, 5, 0, 0, 0, 0, time.UTC)}
+
+	f, err := csv.Read(strings.NewReader(testData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := updater.New(nil, opts).Update(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := testStore(t, testData)
+	d, err := s.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := updater.New(nil, opts).Update(d, nil)
+	if err != nil {
+		d.Rollback()
+		t.Fatal(err)
+	}
+	if err := d.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d bills, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Reference != want[i].Reference || !got[i].Total().Equal(want[i].Total()) {
+			t.Errorf("bill %d = %s %s %s, want %s %s %s", i, got[i].Name, got[i].Reference, got[i].Total(),
+				want[i].Name, want[i].Reference, want[i].Total())
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := exportCSV(t, s); got != buf.String() {
+		t.Errorf("updated data differs:\n%s\nwant\n%s", got, buf.String())
+	}
+}
+
+func TestOpen_name(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "vesi?osuuskunta #1 100%.db")
+	s, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	d, err := s.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.tx.Exec("INSERT INTO billing (id, date, payment_days) VALUES (1, '2022-07-01', 14)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(name); err != nil {
+		t.Errorf("database not created with the name: %v", err)
+	}
+}
+
+func TestData_SetWaterPrices(t *testing.T) {
+	s := testStore(t, testData)
+	d, err := s.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Rollback()
+
+	prices := []updater.PriceComponent{
+		{Label: "Vesi", VAT: decimal.RequireFromString("25.5"), Price: decimal.RequireFromString("1.5"),
+			Tiers: []updater.PriceTier{
+				{Above: decimal.NewFromInt(100), Price: decimal.NewFromInt(2)},
+				{Above: decimal.NewFromInt(20), Price: decimal.RequireFromString("1.75")},
+			}},
+		{Label: "Jätevesi", VAT: decimal.NewFromInt(14), Price: decimal.RequireFromString("2.1")},
+	}
+	if err := d.SetWaterPrices(prices); err != nil {
+		t.Fatal(err)
+	}
+	changes := []updater.VATChange{
+		{Date: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC), Old: decimal.NewFromInt(24), New: decimal.RequireFromString("25.5")},
+		{Date: time.Date(2013, 1, 1, 0, 0, 0, 0, time.UTC), Old: decimal.NewFromInt(23), New: decimal.NewFromInt(24)},
+	}
+	if err := d.SetVATChanges(changes); err != nil {
+		t.Fatal(err)
+	}
+
+	cv, err := d.CommonVariables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cv.WaterPrices) != 2 || cv.WaterPrices[1].Label != "Jätevesi" || !cv.WaterPrices[1].VAT.Equal(decimal.NewFromInt(14)) {
+		t.Fatalf("water prices = %+v", cv.WaterPrices)
+	}
+	if tiers := cv.WaterPrices[0].Tiers; len(tiers) != 2 || !tiers[0].Above.Equal(decimal.NewFromInt(20)) || !tiers[1].Price.Equal(decimal.NewFromInt(2)) {
+		t.Errorf("tiers = %+v", tiers)
+	}
+	if len(cv.VATChanges) != 2 || cv.VATChanges[0].Date.Year() != 2013 {
+		t.Errorf("VAT changes = %+v", cv.VATChanges)
+	}
+}
+
+func TestData_ExchangeMeter(t *testing.T) {
+	ex := meter.Exchange{Date: time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC), FinalCounter: 130, NewNumber: "M9", StartCounter: 5}
+	rdg := meter.Reading{Counter: 25, Date: time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)}
+	exchange := func(d updater.Data) {
+		t.Helper()
+		if err := updater.New(nil, updater.Options{}).ExchangeMeter(d, "Bäck", ex); err != nil {
+			t.Fatal(err)
+		}
+		mrs, err := d.MeterRecords()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := mrs[3].AddReading(rdg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := csv.Read(strings.NewReader(testData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchange(f)
+	var want bytes.Buffer
+	if err := f.Write(&want); err != nil {
+		t.Fatal(err)
+	}
+
+	s := testStore(t, testData)
+	d, err := s.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchange(d)
+	mrs, _ := d.MeterRecords()
+	rdgs, err := d.Readings(mrs[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rdgs) != 3 || rdgs[0].Counter != 25 || rdgs[1].Counter != 110 {
+		t.Errorf("readings = %+v, want the real counters", rdgs)
+	}
+	if err := d.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := exportCSV(t, s); got != want.String() {
+		t.Errorf("exchanged data differs:\n%s\nwant\n%s", got, want.String())
+	}
+}
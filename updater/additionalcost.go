@@ -0,0 +1,80 @@
+package updater
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Groups of members that an additional cost can target.
+const (
+	GroupAll       = "all"       // all members
+	GroupMetered   = "metered"   // members with a water meter
+	GroupUnmetered = "unmetered" // members without a water meter
+)
+
+// AllocationRule defines how an additional cost is divided between
+// the members it targets.
+type AllocationRule int
+
+const (
+	// ByDays divides the cost in proportion to the days of membership
+	// during the billing period.
+	ByDays AllocationRule = iota
+	// Equally divides the cost equally between the members.
+	Equally
+	// Each charges the full cost from each member.
+	Each
+)
+
+var allocationRuleNames = map[AllocationRule]string{
+	ByDays:  "days",
+	Equally: "equal",
+	Each:    "each",
+}
+
+func (r AllocationRule) String() string {
+	return allocationRuleNames[r]
+}
+
+// Set parses the rule from its name. An empty name means ByDays.
+func (r *AllocationRule) Set(s string) error {
+	if s == "" {
+		*r = ByDays
+		return nil
+	}
+
+	for rule, name := range allocationRuleNames {
+		if name == s {
+			*r = rule
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown allocation rule %q", s)
+}
+
+// targets tells whether the cost is billed from the member of the record.
+func (c AdditionalCost) targets(mr MeterRecord) (bool, error) {
+	num, err := mr.MeterNumber()
+	if err != nil {
+		return false, fmt.Errorf("get meter number: %w", err)
+	}
+
+	site, err := mr.SiteNumber()
+	if err != nil {
+		return false, fmt.Errorf("get site number: %w", err)
+	}
+
+	switch strings.TrimSpace(c.Target) {
+	case "", GroupAll:
+		return true, nil
+	case GroupMetered:
+		return num != "", nil
+	case GroupUnmetered:
+		return num == "", nil
+	case mr.Name(), string(site):
+		return true, nil
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,63 @@
+package updater
+
+import (
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// Allocate divides the amount into parts in proportion to the weights so
+// that the parts are whole cents and sum exactly to the amount. The cents
+// left over after rounding down go to the parts with the largest remainders.
+// The adjustments tell how much each part differs from its share rounded
+// to the nearest cent.
+func Allocate(amount decimal.Decimal, weights []decimal.Decimal) (parts, adjustments []decimal.Decimal) {
+	parts = make([]decimal.Decimal, len(weights))
+	adjustments = make([]decimal.Decimal, len(weights))
+
+	var weightSum decimal.Decimal
+	for _, w := range weights {
+		weightSum = weightSum.Add(w)
+	}
+	if weightSum.IsZero() {
+		return parts, adjustments
+	}
+
+	sign := decimal.NewFromInt(int64(amount.Sign()))
+	cents := amount.Abs().Shift(2).Round(0)
+
+	exact := make([]decimal.Decimal, len(weights))
+	remainders := make([]decimal.Decimal, len(weights))
+	left := cents
+	for i, w := range weights {
+		exact[i] = cents.Mul(w).Div(weightSum)
+		parts[i] = exact[i].Floor()
+		remainders[i] = exact[i].Sub(parts[i])
+		left = left.Sub(parts[i])
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]].GreaterThan(remainders[order[b]])
+	})
+	for _, i := range order {
+		if !left.IsPositive() {
+			break
+		}
+		if weights[i].IsZero() {
+			continue
+		}
+		parts[i] = parts[i].Add(decimal.NewFromInt(1))
+		left = left.Sub(decimal.NewFromInt(1))
+	}
+
+	for i := range parts {
+		adjustments[i] = parts[i].Sub(exact[i].Round(0)).Shift(-2).Mul(sign)
+		parts[i] = parts[i].Shift(-2).Mul(sign)
+	}
+
+	return parts, adjustments
+}
@@ -0,0 +1,43 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestAllocate(t *testing.T) {
+	d := decimal.RequireFromString
+	tests := []struct {
+		name        string
+		amount      string
+		weights     []int64
+		parts       []string
+		adjustments []string
+	}{
+		{"even", "30", []int64{1, 1, 1}, []string{"10", "10", "10"}, []string{"0", "0", "0"}},
+		{"thirds", "100", []int64{1, 1, 1}, []string{"33.34", "33.33", "33.33"}, []string{"0.01", "0", "0"}},
+		{"sevenths", "30", []int64{1, 1, 1, 1, 1, 1, 1}, []string{"4.29", "4.29", "4.29", "4.29", "4.28", "4.28", "4.28"}, []string{"0", "0", "0", "0", "-0.01", "-0.01", "-0.01"}},
+		{"weighted", "10", []int64{2, 1, 0}, []string{"6.67", "3.33", "0"}, []string{"0", "0", "0"}},
+		{"negative", "-100", []int64{1, 1, 1}, []string{"-33.34", "-33.33", "-33.33"}, []string{"-0.01", "0", "0"}},
+		{"no weights", "10", []int64{0, 0}, []string{"0", "0"}, []string{"0", "0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weights := make([]decimal.Decimal, len(tt.weights))
+			for i, w := range tt.weights {
+				weights[i] = decimal.NewFromInt(w)
+			}
+
+			parts, adjs := Allocate(d(tt.amount), weights)
+			for i := range parts {
+				if !parts[i].Equal(d(tt.parts[i])) {
+					t.Errorf("part %d = %v, want %v", i, parts[i], tt.parts[i])
+				}
+				if !adjs[i].Equal(d(tt.adjustments[i])) {
+					t.Errorf("adjustment %d = %v, want %v", i, adjs[i], tt.adjustments[i])
+				}
+			}
+		})
+	}
+}
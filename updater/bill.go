@@ -0,0 +1,168 @@
+package updater
+
+import (
+	"fmt"
+
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/jarnoan/vesimittari/reference"
+	"github.com/shopspring/decimal"
+)
+
+var hundred = decimal.NewFromInt(100)
+
+// Bill is the bill of a single member.
+type Bill struct {
+	Name      string
+	Reference reference.Number
+	Lines     []BillLine
+}
+
+// Total returns the sum of the bill lines with tax.
+func (b Bill) Total() decimal.Decimal {
+	var res decimal.Decimal
+	for _, l := range b.Lines {
+		res = res.Add(l.WithTax)
+	}
+
+	return res
+}
+
+// LineKind tells what a bill line is charged for.
+type LineKind int
+
+const (
+	WaterFee LineKind = iota
+	BasicFee
+	AdditionalFee
+	OtherFee
+)
+
+// BillLine is a single line of a bill.
+type BillLine struct {
+	Kind        LineKind
+	Description string
+	Quantity    decimal.Decimal
+	Unit        string
+	UnitPrice   decimal.Decimal // € without tax
+	VAT         decimal.Decimal // %
+	WithoutTax  decimal.Decimal // €, rounded to cents
+	Tax         decimal.Decimal // €, rounded to cents
+	WithTax     decimal.Decimal // €
+}
+
+// NewBillLine constructs a bill line and calculates its amounts.
+func NewBillLine(kind LineKind, desc string, qty decimal.Decimal, unit string, unitPrice, vat decimal.Decimal) BillLine {
+	withoutTax := qty.Mul(unitPrice).Round(2)
+	tax := withoutTax.Mul(vat).Div(hundred).Round(2)
+
+	return BillLine{
+		Kind:        kind,
+		Description: desc,
+		Quantity:    qty,
+		Unit:        unit,
+		UnitPrice:   unitPrice,
+		VAT:         vat,
+		WithoutTax:  withoutTax,
+		Tax:         tax,
+		WithTax:     withoutTax.Add(tax),
+	}
+}
+
+// SumLines returns a line with the sums of the amounts of the lines.
+func SumLines(desc string, lines []BillLine) BillLine {
+	res := BillLine{Description: desc}
+	for _, l := range lines {
+		res.WithoutTax = res.WithoutTax.Add(l.WithoutTax)
+		res.Tax = res.Tax.Add(l.Tax)
+		res.WithTax = res.WithTax.Add(l.WithTax)
+	}
+
+	return res
+}
+
+// withTotal adjusts the last of the lines so that the lines sum to the
+// amount without tax. The tax of the adjusted line is calculated again.
+func withTotal(lines []BillLine, withoutTax decimal.Decimal) []BillLine {
+	last := &lines[len(lines)-1]
+	last.WithoutTax = withoutTax.Sub(SumLines("", lines[:len(lines)-1]).WithoutTax)
+	last.Tax = last.WithoutTax.Mul(last.VAT).Div(hundred).Round(2)
+	last.WithTax = last.WithoutTax.Add(last.Tax)
+
+	return lines
+}
+
+// PriceComponent is a part of the water fee billed per m³,
+// like water or wastewater.
+type PriceComponent struct {
+	Label string
+	VAT   decimal.Decimal // %
+	Price decimal.Decimal // €/m³ without tax
+	Tiers []PriceTier     // in ascending order of volume
+}
+
+// PriceTier is a price for the consumption above a yearly volume.
+type PriceTier struct {
+	Above decimal.Decimal // m³ per year
+	Price decimal.Decimal // €/m³ without tax
+}
+
+// Lines returns the bill lines for a consumption of m³ during a period of
+// days. The yearly volumes of the tiers are prorated to the period.
+func (pc PriceComponent) Lines(cons decimal.Decimal, days int) []BillLine {
+	var res []BillLine
+	label := pc.Label
+	price := pc.Price
+	from := decimal.Zero
+
+	if days > 0 {
+		for _, t := range pc.Tiers {
+			limit := t.Above.Mul(decimal.NewFromInt(int64(days))).Div(decimal.NewFromInt(365)).Round(2)
+			if !cons.GreaterThan(limit) {
+				break
+			}
+			if limit.GreaterThan(from) {
+				res = append(res, NewBillLine(WaterFee, label, limit.Sub(from), "m³", price, pc.VAT))
+				from = limit
+			}
+			label = fmt.Sprintf("%s yli %s m³/v", pc.Label, t.Above)
+			price = t.Price
+		}
+	}
+
+	return append(res, NewBillLine(WaterFee, label, cons.Sub(from), "m³", price, pc.VAT))
+}
+
+// MeteredLines returns the lines of the water fee for the consumption of
+// m³ between the readings and the lines of the basic fee for the part of
+// the reading period during the membership. The basic fee lines sum to the
+// basic fee of the member if it is set. It also returns the months of
+// basic fee billed.
+func MeteredLines(cons decimal.Decimal, readings, membership period.Period, cv CommonVariables) ([]BillLine, decimal.Decimal) {
+	var res []BillLine
+
+	billed := readings.Clip(membership)
+	months := billed.Months(cv.MonthConvention)
+
+	// Each price component is a line of its own
+	for _, pc := range cv.WaterPrices {
+		for _, l := range pc.Lines(cons, readings.Days()) {
+			res = append(res, SplitVAT(l, readings, cv.VATChanges)...)
+		}
+	}
+
+	if cv.BasicFee == nil {
+		basicFee := NewBillLine(BasicFee, "Perusmaksu", months, "kk", cv.MonthlyFee, cv.VAT)
+		return append(res, SplitVAT(basicFee, billed, cv.VATChanges)...), months
+	}
+
+	// The share of the main meter fee is billed as such, whatever the
+	// rounding of the lines split at VAT changes
+	price := decimal.Zero
+	if !months.IsZero() {
+		price = cv.BasicFee.DivRound(months, 4)
+	}
+	basicFee := NewBillLine(BasicFee, "Perusmaksu", months, "kk", price, cv.VAT)
+	res = append(res, withTotal(SplitVAT(basicFee, billed, cv.VATChanges), *cv.BasicFee)...)
+
+	return res, months
+}
@@ -0,0 +1,83 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/shopspring/decimal"
+)
+
+func TestPriceComponent_Lines(t *testing.T) {
+	pc := PriceComponent{
+		Label: "Vesi",
+		VAT:   decimal.NewFromInt(24),
+		Price: decimal.NewFromInt(2),
+		Tiers: []PriceTier{{Above: decimal.NewFromInt(100), Price: decimal.NewFromInt(3)}},
+	}
+	tests := []struct {
+		name  string
+		cons  int64
+		days  int
+		lines []string // quantity and amount without tax
+	}{
+		{"below tier", 40, 365, []string{"40 80"}},
+		{"above tier", 120, 365, []string{"100 200", "20 60"}},
+		{"half year", 60, 182, []string{"49.86 99.72", "10.14 30.42"}},
+		{"no days", 60, 0, []string{"60 120"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := pc.Lines(decimal.NewFromInt(tt.cons), tt.days)
+			if len(lines) != len(tt.lines) {
+				t.Fatalf("got %d lines, want %d", len(lines), len(tt.lines))
+			}
+			for i, l := range lines {
+				if got := l.Quantity.String() + " " + l.WithoutTax.String(); got != tt.lines[i] {
+					t.Errorf("line %d = %s, want %s", i, got, tt.lines[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewBillLine(t *testing.T) {
+	l := NewBillLine(WaterFee, "Perusmaksu", decimal.RequireFromString("1.5"), "kk", decimal.RequireFromString("3.33"), decimal.NewFromInt(24))
+	if !l.WithoutTax.Equal(decimal.RequireFromString("5")) || !l.Tax.Equal(decimal.RequireFromString("1.2")) || !l.WithTax.Equal(decimal.RequireFromString("6.2")) {
+		t.Errorf("NewBillLine() = %+v", l)
+	}
+}
+
+func TestSplitVAT(t *testing.T) {
+	change := VATChange{
+		Date: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC),
+		Old:  decimal.NewFromInt(24),
+		New:  decimal.RequireFromString("25.5"),
+	}
+	l := NewBillLine(WaterFee, "Vesi", decimal.NewFromInt(92), "m³", decimal.NewFromInt(2), change.New)
+	tests := []struct {
+		name       string
+		start, end time.Time
+		lines      []string // quantity, VAT and tax
+	}{
+		{"straddling", time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC),
+			[]string{"62 24 29.76", "30 25.5 15.3"}},
+		{"before", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC),
+			[]string{"92 24 44.16"}},
+		{"after", time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+			[]string{"92 25.5 46.92"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := SplitVAT(l, period.New(tt.start, tt.end), []VATChange{change})
+			if len(lines) != len(tt.lines) {
+				t.Fatalf("got %d lines, want %d", len(lines), len(tt.lines))
+			}
+			for i, l := range lines {
+				if got := l.Quantity.String() + " " + l.VAT.String() + " " + l.Tax.String(); got != tt.lines[i] {
+					t.Errorf("line %d = %s, want %s", i, got, tt.lines[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package updater
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jarnoan/vesimittari/meter"
+	"github.com/shopspring/decimal"
+)
+
+// CorrectionData is Data that can record corrections to sent bills.
+type CorrectionData interface {
+	Data
+	// AddCorrection adds the correction bill for the member of the record.
+	AddCorrection(mr MeterRecord, b Bill, date time.Time) error
+}
+
+// Correction is a correction to a bill that has already been sent.
+// Either the meter counter or the total amount of the bill is corrected.
+type Correction struct {
+	Name    string
+	Date    time.Time       // date of the correction
+	Counter *int            // corrected meter counter, nil if Amount is corrected
+	Amount  decimal.Decimal // corrected total with tax, used if Counter is nil
+}
+
+// Correct compares the original bill of the member in the history with
+// the corrected one and adds the difference to the data as a bill with a
+// reference of its own. A bill with a negative total is a credit note,
+// otherwise it is a supplementary bill. If the corrected reading is still
+// the latest reading of the member in the data, it is corrected there too.
+func (u *Updater) Correct(history Data, d CorrectionData, c Correction) (Bill, error) {
+	hmrs, err := history.MeterRecords()
+	if err != nil {
+		return Bill{}, fmt.Errorf("read history meter records: %w", err)
+	}
+
+	hmr, err := findRecord(hmrs, c.Name)
+	if err != nil {
+		return Bill{}, fmt.Errorf("history: %w", err)
+	}
+
+	orig, err := hmr.Bill()
+	if err != nil {
+		return Bill{}, fmt.Errorf("get original bill: %w", err)
+	}
+	if orig.Reference == "" {
+		return Bill{}, fmt.Errorf("no bill for %s in history", c.Name)
+	}
+
+	cv, err := u.commonVariables(history)
+	if err != nil {
+		return Bill{}, err
+	}
+
+	var lines []BillLine
+	var rdg meter.Reading
+	if c.Counter != nil {
+		rdg, err = hmr.Reading()
+		if err != nil {
+			return Bill{}, fmt.Errorf("get original reading: %w", err)
+		}
+		rdg.Counter = *c.Counter
+		rdg.Digits = u.opts.MeterDigits
+
+		lines, err = u.recalculate(hmr, orig, cv, rdg)
+		if err != nil {
+			return Bill{}, err
+		}
+	} else {
+		// The tax of a corrected total is assumed to be at the general rate
+		diff := c.Amount.Sub(orig.Total())
+		withoutTax := diff.Mul(hundred).DivRound(hundred.Add(cv.VAT), 2)
+		lines = []BillLine{{
+			Kind:        OtherFee,
+			Description: "Oikaisu",
+			Quantity:    decimal.NewFromInt(1),
+			UnitPrice:   withoutTax,
+			VAT:         cv.VAT,
+			WithoutTax:  withoutTax,
+			Tax:         diff.Sub(withoutTax),
+			WithTax:     diff,
+		}}
+	}
+
+	if len(lines) == 0 {
+		return Bill{}, fmt.Errorf("corrected bill of %s equals the original", c.Name)
+	}
+	for i := range lines {
+		lines[i].Description = fmt.Sprintf("%s, lasku %s", lines[i].Description, orig.Reference)
+	}
+
+	mrs, err := d.MeterRecords()
+	if err != nil {
+		return Bill{}, fmt.Errorf("read meter records: %w", err)
+	}
+
+	mr, err := findRecord(mrs, c.Name)
+	if err != nil {
+		return Bill{}, err
+	}
+
+	if c.Counter != nil {
+		latest, err := mr.Reading()
+		if err != nil {
+			return Bill{}, fmt.Errorf("get latest reading: %w", err)
+		}
+		if latest.Date.Equal(rdg.Date) {
+			if err := mr.CorrectReading(rdg); err != nil {
+				return Bill{}, fmt.Errorf("correct reading of %s: %w", c.Name, err)
+			}
+		}
+	}
+
+	b := Bill{Name: c.Name, Reference: lastReference(mrs).Next(), Lines: lines}
+	if err := d.AddCorrection(mr, b, c.Date); err != nil {
+		return Bill{}, fmt.Errorf("add correction for %s: %w", c.Name, err)
+	}
+
+	if u.opts.Verbose {
+		log.Printf("correction %s for %s: %s", b.Reference, c.Name, b.Total())
+	}
+
+	return b, nil
+}
+
+// recalculate bills the history record again with the corrected reading
+// and returns the differences to the original bill.
+func (u *Updater) recalculate(hmr MeterRecord, orig Bill, cv CommonVariables, rdg meter.Reading) ([]BillLine, error) {
+	// The original share of the main meter fee is billed again
+	var basicFee decimal.Decimal
+	var acs []AdditionalCost
+	for _, l := range orig.Lines {
+		switch l.Kind {
+		case BasicFee:
+			basicFee = basicFee.Add(l.WithoutTax)
+		case AdditionalFee:
+			acs = append(acs, AdditionalCost{Description: l.Description, VAT: l.VAT, Cost: l.WithoutTax})
+		}
+	}
+	cv.BasicFee = &basicFee
+
+	// Bill the original reading again so that both bills are calculated alike
+	if err := hmr.UpdateBilling(orig.Reference, cv, acs); err != nil {
+		return nil, fmt.Errorf("recalculate original bill: %w", err)
+	}
+	recalc, err := hmr.Bill()
+	if err != nil {
+		return nil, err
+	}
+	if !recalc.Total().Equal(orig.Total()) {
+		log.Printf("recalculated bill %s of %s differs from the original: %s != %s",
+			orig.Reference, hmr.Name(), recalc.Total(), orig.Total())
+	}
+
+	if err := hmr.CorrectReading(rdg); err != nil {
+		return nil, fmt.Errorf("correct reading: %w", err)
+	}
+	if err := hmr.UpdateBilling(orig.Reference, cv, acs); err != nil {
+		return nil, fmt.Errorf("calculate corrected bill: %w", err)
+	}
+	corrected, err := hmr.Bill()
+	if err != nil {
+		return nil, err
+	}
+
+	return lineDifferences(recalc.Lines, corrected.Lines), nil
+}
+
+// lineDifferences returns a line for each kind of fee and VAT rate whose
+// amount differs between the bills.
+func lineDifferences(orig, corrected []BillLine) []BillLine {
+	type key struct {
+		kind LineKind
+		vat  string
+	}
+
+	var keys []key
+	sums := make(map[key]BillLine)
+	add := func(l BillLine, sign int64) {
+		k := key{l.Kind, l.VAT.String()}
+		s, ok := sums[k]
+		if !ok {
+			keys = append(keys, k)
+			s = BillLine{Kind: l.Kind, Description: l.Description, Unit: l.Unit, VAT: l.VAT}
+		}
+		m := decimal.NewFromInt(sign)
+		s.Quantity = s.Quantity.Add(l.Quantity.Mul(m))
+		s.WithoutTax = s.WithoutTax.Add(l.WithoutTax.Mul(m))
+		s.Tax = s.Tax.Add(l.Tax.Mul(m))
+		s.WithTax = s.WithTax.Add(l.WithTax.Mul(m))
+		sums[k] = s
+	}
+
+	for _, l := range corrected {
+		add(l, 1)
+	}
+	for _, l := range orig {
+		add(l, -1)
+	}
+
+	var res []BillLine
+	for _, k := range keys {
+		l := sums[k]
+		if l.WithTax.IsZero() {
+			continue
+		}
+		if !l.Quantity.IsZero() {
+			l.UnitPrice = l.WithoutTax.DivRound(l.Quantity, 4)
+		}
+		res = append(res, l)
+	}
+
+	return res
+}
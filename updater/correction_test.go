@@ -0,0 +1,29 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestLineDifferences(t *testing.T) {
+	vat := decimal.NewFromInt(24)
+	price := decimal.NewFromInt(2)
+	orig := []BillLine{
+		NewBillLine(WaterFee, "Vesi", decimal.NewFromInt(50), "m³", price, vat),
+		NewBillLine(BasicFee, "Perusmaksu", decimal.NewFromInt(6), "kk", price, vat),
+	}
+	corrected := []BillLine{
+		NewBillLine(WaterFee, "Vesi", decimal.NewFromInt(40), "m³", price, vat),
+		NewBillLine(BasicFee, "Perusmaksu", decimal.NewFromInt(6), "kk", price, vat),
+	}
+
+	diffs := lineDifferences(orig, corrected)
+	if len(diffs) != 1 {
+		t.Fatalf("got %d lines, want 1: %+v", len(diffs), diffs)
+	}
+	d := diffs[0]
+	if d.Kind != WaterFee || !d.Quantity.Equal(decimal.NewFromInt(-10)) || !d.WithTax.Equal(decimal.RequireFromString("-24.8")) || !d.UnitPrice.Equal(price) {
+		t.Errorf("difference = %+v", d)
+	}
+}
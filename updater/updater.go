@@ -1,33 +1,69 @@
 package updater
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/jarnoan/vesimittari/meter"
+	"github.com/jarnoan/vesimittari/period"
 	"github.com/jarnoan/vesimittari/reference"
 	"github.com/shopspring/decimal"
 )
 
+var (
+	// ErrAlreadyRead is returned when the meters are read again before the
+	// readings have been billed.
+	ErrAlreadyRead = errors.New("meters already read since the previous billing")
+	// ErrNotRead is returned when the members are billed again without
+	// reading the meters since the previous billing.
+	ErrNotRead = errors.New("meters not read since the previous billing")
+)
+
 type Data interface {
 	MeterRecords() ([]MeterRecord, error)
+	Date() (time.Time, error)
 	SetDate(time.Time)
 	CommonVariables() (CommonVariables, error)
 }
 
+// HandoverData is Data that supports changing the owner of a meter site.
+type HandoverData interface {
+	Data
+	// Transfer ends the membership of the seller at the date and adds
+	// a record for the buyer starting from the seller's latest reading.
+	Transfer(seller MeterRecord, buyer string, date time.Time) (MeterRecord, error)
+}
+
+// Handover describes a change of ownership of a meter site.
+type Handover struct {
+	Seller  string    // name of the current owner
+	Buyer   string    // name of the new owner
+	Date    time.Time // date of the handover
+	Counter int       // meter counter at the handover
+}
+
 // CommonVariables contains general values needed for fee calculations.
 type CommonVariables struct {
-	VAT        decimal.Decimal // %
-	MonthlyFee decimal.Decimal // € without tax (päämittarin kuukausimaksu)
-	WaterPrice decimal.Decimal // €/m³ without tax
+	VAT          decimal.Decimal  // %
+	VATChanges   []VATChange      // changes of VAT rates, in ascending order of date
+	MainMeterFee decimal.Decimal  // € per month without tax (päämittarin kuukausimaksu)
+	MonthlyFee   decimal.Decimal  // € per month without tax, the share of a single meter
+	BasicFee     *decimal.Decimal // € without tax, the share of the member of the main meter fee, MonthlyFee per month if nil
+	WaterPrices  []PriceComponent // water fee per m³, like water and wastewater
+
+	MonthConvention period.Convention // how days between readings are converted to months
 }
 
-// AdditionalCost is some cost that is shared between all shareholders, like insurance.
+// AdditionalCost is some cost that is shared between shareholders, like insurance,
+// or charged from a single member, like a late fee.
 type AdditionalCost struct {
 	Description string
 	VAT         decimal.Decimal // %
 	Cost        decimal.Decimal // € without tax
+	Target      string          // member name, site number or group, all members if empty
+	Rule        AllocationRule
 }
 
 // MeterRecord defines the methods needed from a single meter record.
@@ -36,8 +72,13 @@ type MeterRecord interface {
 	MeterNumber() (meter.Number, error)
 	SiteNumber() (meter.SiteNumber, error)
 	Reference() reference.Number
+	Membership() (period.Period, error)
+	Reading() (meter.Reading, error)
 	AddReading(meter.Reading) error
+	CorrectReading(meter.Reading) error
+	ExchangeMeter(meter.Exchange) error
 	UpdateBilling(reference.Number, CommonVariables, []AdditionalCost) error
+	Bill() (Bill, error)
 }
 
 type MeterReader interface {
@@ -47,9 +88,15 @@ type MeterReader interface {
 type Options struct {
 	Verbose             bool
 	UpdateMeterReadings bool
+	MonthConvention     period.Convention
+	MeterDigits         int              // number of digits in meter counters, 0 if unknown
+	WaterPrices         []PriceComponent // overrides the water price of the data if set
+	VAT                 *decimal.Decimal // overrides the general VAT of the data if set
+	MainMeterFee        *decimal.Decimal // overrides the main meter fee of the data if set
+	Date                time.Time        // billing date, now if zero
 }
 
-//Updater updates the Data.
+// Updater updates the Data.
 type Updater struct {
 	meterReader MeterReader
 	opts        Options
@@ -61,72 +108,479 @@ func New(mr MeterReader, opts Options) *Updater {
 }
 
 // Update reads the consumptions and updates the data accordingly.
-func (u *Updater) Update(d Data, acs []AdditionalCost) error {
+// It returns the bills of the members billed.
+func (u *Updater) Update(d Data, acs []AdditionalCost) ([]Bill, error) {
 	mrs, err := d.MeterRecords()
 	if err != nil {
-		return fmt.Errorf("read meter records: %w", err)
+		return nil, fmt.Errorf("read meter records: %w", err)
 	}
 
-	cv, err := d.CommonVariables()
+	cv, err := u.commonVariables(d)
 	if err != nil {
-		return fmt.Errorf("get common variables: %w", err)
+		return nil, err
 	}
 
-	var lastRef reference.Number
-	for _, mr := range mrs {
-		if lastRef == "" || mr.Reference() > lastRef {
-			lastRef = mr.Reference()
+	lastRef := lastReference(mrs)
+
+	billingDate := u.billingDate()
+	prevBillingDate, err := d.Date()
+	if err != nil {
+		return nil, fmt.Errorf("get previous billing date: %w", err)
+	}
+	bp := period.New(prevBillingDate, billingDate)
+
+	// Calculate the share of the billing period for each member
+	shares := make([]decimal.Decimal, len(mrs))
+	for i, mr := range mrs {
+		if i == 0 { // exclude main meter
+			continue
+		}
+
+		membership, err := mr.Membership()
+		if err != nil {
+			return nil, fmt.Errorf("get membership of %s: %w", mr.Name(), err)
 		}
+
+		shares[i] = membershipShare(bp, membership)
 	}
 
-	// Calculate additional costs per member
-	memberCount := decimal.NewFromInt(int64(len(mrs) - 1)) // exclude main meter
-	acsPerMember := make([]AdditionalCost, len(acs))
-	for i, c := range acs {
-		acsPerMember[i] = c
-		acsPerMember[i].Cost = c.Cost.DivRound(memberCount, 2)
+	// Split the main meter fee of the billing period and the additional
+	// costs between the members so that the shares sum to the originals
+	basicFees, err := basicFees(mrs, shares, cv, bp)
+	if err != nil {
+		return nil, err
+	}
+
+	acShares := make([][]decimal.Decimal, len(acs))
+	for j, c := range acs {
+		acShares[j], err = allocateCost(c, mrs, shares)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The readings are taken during the run or by reading the meters
+	// before it, so without new readings the period has been billed already
+	if !u.opts.UpdateMeterReadings {
+		if err := checkNewReadings(mrs, shares, prevBillingDate); err != nil {
+			return nil, err
+		}
 	}
+
 	if u.opts.Verbose {
 		log.Printf("common variables: %+v", cv)
-		log.Printf("additional costs: %+v", acsPerMember)
+		log.Printf("additional costs: %+v", acs)
+		log.Printf("billing period: %s - %s", bp.Start.Format("2.1.2006"), bp.End.Format("2.1.2006"))
 		log.Printf("last reference: %s\n", lastRef)
 	}
 
 	// Read the meterings and update the meter records
+	var bills []Bill
 	for i, mr := range mrs {
-		num, err := mr.MeterNumber()
-		if err != nil {
-			return fmt.Errorf("get meter number: %w", err)
+		if i > 0 && shares[i].IsZero() {
+			if u.opts.Verbose {
+				log.Printf("skipping %s, not a member during the billing period", mr.Name())
+			}
+			continue
 		}
 
-		site, err := mr.SiteNumber()
-		if err != nil {
-			return fmt.Errorf("get site number: %w", err)
+		if u.opts.UpdateMeterReadings {
+			if err := u.readMeter(mr, billingDate); err != nil {
+				return nil, err
+			}
 		}
 
-		if u.opts.UpdateMeterReadings && num != "" {
-			log.Printf("reading meter for %s", mr.Name())
-			r, err := u.meterReader.ReadMeter(site, num)
+		if i > 0 { // skip the main meter row
+			ref := lastRef.Next()
+			lastRef = ref
+
+			// Additional costs are shared in proportion to the days of membership
+			var acsPerMember []AdditionalCost
+			for j, c := range acs {
+				if acShares[j][i].IsZero() {
+					continue // not targeted to this member
+				}
+				c.Cost = acShares[j][i]
+				acsPerMember = append(acsPerMember, c)
+			}
+
+			mcv := cv
+			mcv.BasicFee = &basicFees[i]
+
+			if err := mr.UpdateBilling(ref, mcv, acsPerMember); err != nil {
+				return nil, fmt.Errorf("update billing for %s: %w", mr.Name(), err)
+			}
+
+			b, err := mr.Bill()
 			if err != nil {
-				return fmt.Errorf("read meter %s: %w", num, err)
+				return nil, fmt.Errorf("get bill of %s: %w", mr.Name(), err)
 			}
+			bills = append(bills, b)
+		}
+	}
+
+	d.SetDate(billingDate)
+
+	return bills, nil
+}
+
+// ReadMeters reads the meters of the members of the billing period and
+// adds the readings to the data without billing them. The meters can be
+// read only once between billings, as a second reading would replace the
+// start of the period to bill.
+func (u *Updater) ReadMeters(d Data) error {
+	mrs, err := d.MeterRecords()
+	if err != nil {
+		return fmt.Errorf("read meter records: %w", err)
+	}
+
+	date := u.billingDate()
+	prevBillingDate, err := d.Date()
+	if err != nil {
+		return fmt.Errorf("get previous billing date: %w", err)
+	}
+	bp := period.New(prevBillingDate, date)
 
-			if err := mr.AddReading(r); err != nil {
-				return fmt.Errorf("add reading for meter %s: %w", num, err)
+	var read []MeterRecord
+	for i, mr := range mrs {
+		if i > 0 { // the main meter is always read
+			membership, err := mr.Membership()
+			if err != nil {
+				return fmt.Errorf("get membership of %s: %w", mr.Name(), err)
+			}
+			if membershipShare(bp, membership).IsZero() {
+				continue
 			}
 		}
 
-		if i > 0 { // skip the main meter row
-			ref := lastRef.Next()
-			lastRef = ref
+		rdg, err := mr.Reading()
+		if err != nil {
+			return fmt.Errorf("get reading of %s: %w", mr.Name(), err)
+		}
+		if rdg.Date.After(prevBillingDate) {
+			return fmt.Errorf("%w: %s on %s after the billing date %s, bill the readings first",
+				ErrAlreadyRead, mr.Name(), rdg.Date.Format("2.1.2006"), prevBillingDate.Format("2.1.2006"))
+		}
+		read = append(read, mr)
+	}
+
+	for _, mr := range read {
+		if err := u.readMeter(mr, date); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkNewReadings returns an error if none of the meters of the members
+// of the billing period has been read after the previous billing date.
+func checkNewReadings(mrs []MeterRecord, shares []decimal.Decimal, prevBillingDate time.Time) error {
+	metered := false
+	for i, mr := range mrs {
+		if i > 0 && shares[i].IsZero() {
+			continue
+		}
+
+		num, err := mr.MeterNumber()
+		if err != nil {
+			return fmt.Errorf("get meter number of %s: %w", mr.Name(), err)
+		}
+		if num == "" {
+			continue
+		}
+		metered = true
+
+		rdg, err := mr.Reading()
+		if err != nil {
+			return fmt.Errorf("get reading of %s: %w", mr.Name(), err)
+		}
+		if rdg.Date.After(prevBillingDate) {
+			return nil
+		}
+	}
+	if !metered {
+		return nil
+	}
+
+	return fmt.Errorf("%w: none after the billing date %s, read the meters first", ErrNotRead, prevBillingDate.Format("2.1.2006"))
+}
+
+// readMeter reads the meter of the record and adds the reading. Members
+// who have left by the date got their final reading when leaving.
+func (u *Updater) readMeter(mr MeterRecord, date time.Time) error {
+	num, err := mr.MeterNumber()
+	if err != nil {
+		return fmt.Errorf("get meter number: %w", err)
+	}
+	if num == "" {
+		return nil
+	}
+
+	site, err := mr.SiteNumber()
+	if err != nil {
+		return fmt.Errorf("get site number: %w", err)
+	}
+
+	membership, err := mr.Membership()
+	if err != nil {
+		return fmt.Errorf("get membership of %s: %w", mr.Name(), err)
+	}
+	if !membership.End.IsZero() && !membership.End.After(date) {
+		return nil
+	}
+
+	log.Printf("reading meter for %s", mr.Name())
+	r, err := u.meterReader.ReadMeter(site, num)
+	if err != nil {
+		return fmt.Errorf("read meter %s: %w", num, err)
+	}
+
+	r.Digits = u.opts.MeterDigits
+	if err := mr.AddReading(r); err != nil {
+		return fmt.Errorf("add reading for meter %s: %w", num, err)
+	}
+
+	return nil
+}
 
-			if err := mr.UpdateBilling(ref, cv, acsPerMember); err != nil {
-				return fmt.Errorf("update billing for %s: %w", mr.Name(), err)
+// billingDate returns the billing date of the options, now if not set.
+func (u *Updater) billingDate() time.Time {
+	if u.opts.Date.IsZero() {
+		return time.Now()
+	}
+
+	return u.opts.Date
+}
+
+// commonVariables returns the common variables of the data with the
+// overrides of the options.
+func (u *Updater) commonVariables(d Data) (CommonVariables, error) {
+	cv, err := d.CommonVariables()
+	if err != nil {
+		return cv, fmt.Errorf("get common variables: %w", err)
+	}
+
+	cv.MonthConvention = u.opts.MonthConvention
+	if u.opts.VAT != nil {
+		cv = overrideVAT(cv, *u.opts.VAT)
+	}
+	if len(u.opts.WaterPrices) > 0 {
+		cv.WaterPrices = u.opts.WaterPrices // with their own rates
+	}
+	if u.opts.MainMeterFee != nil {
+		cv.MainMeterFee = *u.opts.MainMeterFee
+	}
+
+	return cv, nil
+}
+
+// overrideVAT replaces the general VAT rate of the data with the rate.
+// The water price components and the VAT changes billed with the general
+// rate of the data get the new rate, other rates are kept.
+func overrideVAT(cv CommonVariables, vat decimal.Decimal) CommonVariables {
+	general := cv.VAT
+	cv.VAT = vat
+
+	prices := make([]PriceComponent, len(cv.WaterPrices))
+	for i, pc := range cv.WaterPrices {
+		if pc.VAT.Equal(general) {
+			pc.VAT = vat
+		}
+		prices[i] = pc
+	}
+	cv.WaterPrices = prices
+
+	changes := make([]VATChange, len(cv.VATChanges))
+	for i, c := range cv.VATChanges {
+		if c.New.Equal(general) {
+			c.New = vat
+		}
+		changes[i] = c
+	}
+	cv.VATChanges = changes
+
+	return cv
+}
+
+// lastReference returns the greatest reference number of the records.
+func lastReference(mrs []MeterRecord) reference.Number {
+	var res reference.Number
+	for _, mr := range mrs {
+		if res == "" || mr.Reference() > res {
+			res = mr.Reference()
+		}
+	}
+
+	return res
+}
+
+// basicFees splits the main meter fee of the billing period between the
+// members with a meter in proportion to their shares of the period, so
+// that the basic fees sum exactly to the fee. Records of successive owners
+// share the fee of their meter by their days.
+func basicFees(mrs []MeterRecord, shares []decimal.Decimal, cv CommonVariables, bp period.Period) ([]decimal.Decimal, error) {
+	weights := make([]decimal.Decimal, len(mrs))
+	for i, mr := range mrs {
+		if shares[i].IsZero() {
+			continue // main meter or not a member during the billing period
+		}
+
+		num, err := mr.MeterNumber()
+		if err != nil {
+			return nil, fmt.Errorf("get meter number of %s: %w", mr.Name(), err)
+		}
+		if num != "" {
+			weights[i] = shares[i]
+		}
+	}
+
+	fee := cv.MainMeterFee.Mul(bp.Months(cv.MonthConvention)).Round(2)
+	res, adjs := Allocate(fee, weights)
+	logAdjustments("main meter fee", mrs, adjs)
+
+	return res, nil
+}
+
+// allocateCost returns the share of the cost for each record. Shares are
+// zero for records the cost does not target.
+func allocateCost(c AdditionalCost, mrs []MeterRecord, shares []decimal.Decimal) ([]decimal.Decimal, error) {
+	weights := make([]decimal.Decimal, len(mrs))
+	targeted := 0
+	for i, mr := range mrs {
+		if shares[i].IsZero() {
+			continue // main meter or not a member during the billing period
+		}
+
+		ok, err := c.targets(mr)
+		if err != nil {
+			return nil, fmt.Errorf("target of %s: %w", c.Description, err)
+		}
+		if !ok {
+			continue
+		}
+		targeted++
+
+		switch c.Rule {
+		case ByDays:
+			weights[i] = shares[i]
+		default:
+			weights[i] = decimal.NewFromInt(1)
+		}
+	}
+
+	if targeted == 0 {
+		return nil, fmt.Errorf("%s: no member matches target %q", c.Description, c.Target)
+	}
+
+	if c.Rule == Each {
+		res := make([]decimal.Decimal, len(mrs))
+		for i, w := range weights {
+			if !w.IsZero() {
+				res[i] = c.Cost
 			}
 		}
+		return res, nil
 	}
 
-	d.SetDate(time.Now())
+	res, adjs := Allocate(c.Cost, weights)
+	logAdjustments(c.Description, mrs, adjs)
+
+	return res, nil
+}
+
+// logAdjustments reports the cents by which the shares of a cost were
+// adjusted to make them sum to the cost.
+func logAdjustments(desc string, mrs []MeterRecord, adjs []decimal.Decimal) {
+	for i, adj := range adjs {
+		if !adj.IsZero() {
+			log.Printf("%s: share adjusted by %s for %s", desc, adj, mrs[i].Name())
+		}
+	}
+}
+
+// Handover records the final reading of the seller and adds a record for
+// the buyer. The following Update bills the seller up to the handover date
+// and the buyer from it on.
+func (u *Updater) Handover(d HandoverData, h Handover) error {
+	mrs, err := d.MeterRecords()
+	if err != nil {
+		return fmt.Errorf("read meter records: %w", err)
+	}
+
+	prevBillingDate, err := d.Date()
+	if err != nil {
+		return fmt.Errorf("get previous billing date: %w", err)
+	}
+	if h.Date.Before(prevBillingDate) {
+		return fmt.Errorf("handover date %s is before the previous billing date", h.Date.Format("2.1.2006"))
+	}
+
+	seller, err := findRecord(mrs, h.Seller)
+	if err != nil {
+		return err
+	}
+
+	rdg := meter.Reading{Counter: h.Counter, Date: h.Date, Customer: h.Seller, Digits: u.opts.MeterDigits}
+	if err := seller.AddReading(rdg); err != nil {
+		return fmt.Errorf("add handover reading for %s: %w", h.Seller, err)
+	}
+
+	if _, err := d.Transfer(seller, h.Buyer, h.Date); err != nil {
+		return fmt.Errorf("transfer to %s: %w", h.Buyer, err)
+	}
+
+	if u.opts.Verbose {
+		log.Printf("handover from %s to %s on %s at counter %d", h.Seller, h.Buyer, h.Date.Format("2.1.2006"), h.Counter)
+	}
+
+	return nil
+}
+
+// ExchangeMeter records a replacement of the meter of the named member.
+func (u *Updater) ExchangeMeter(d Data, name string, ex meter.Exchange) error {
+	mrs, err := d.MeterRecords()
+	if err != nil {
+		return fmt.Errorf("read meter records: %w", err)
+	}
+
+	mr, err := findRecord(mrs, name)
+	if err != nil {
+		return err
+	}
+
+	ex.Digits = u.opts.MeterDigits
+	if err := mr.ExchangeMeter(ex); err != nil {
+		return fmt.Errorf("exchange meter of %s: %w", name, err)
+	}
+
+	if u.opts.Verbose {
+		log.Printf("meter of %s exchanged to %s on %s", name, ex.NewNumber, ex.Date.Format("2.1.2006"))
+	}
 
 	return nil
 }
+
+func findRecord(mrs []MeterRecord, name string) (MeterRecord, error) {
+	for _, mr := range mrs {
+		if mr.Name() == name {
+			return mr, nil
+		}
+	}
+
+	return nil, fmt.Errorf("member %s not found", name)
+}
+
+// membershipShare returns the part of the billing period during which
+// the membership was valid, from 0 to 1.
+func membershipShare(bp, membership period.Period) decimal.Decimal {
+	if bp.Days() == 0 {
+		if membership.Contains(bp.End) {
+			return decimal.NewFromInt(1)
+		}
+		return decimal.Zero
+	}
+
+	days := decimal.NewFromInt(int64(bp.Clip(membership).Days()))
+	return days.Div(decimal.NewFromInt(int64(bp.Days())))
+}
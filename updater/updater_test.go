@@ -0,0 +1,290 @@
+package updater
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jarnoan/vesimittari/meter"
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/jarnoan/vesimittari/reference"
+	"github.com/shopspring/decimal"
+)
+
+type fakeData struct {
+	records []MeterRecord
+	date    time.Time
+	cv      CommonVariables
+}
+
+func (d *fakeData) MeterRecords() ([]MeterRecord, error)      { return d.records, nil }
+func (d *fakeData) Date() (time.Time, error)                  { return d.date, nil }
+func (d *fakeData) SetDate(t time.Time)                       { d.date = t }
+func (d *fakeData) CommonVariables() (CommonVariables, error) { return d.cv, nil }
+
+type fakeRecord struct {
+	name       string
+	number     meter.Number
+	membership period.Period
+	ref        reference.Number
+	billed     bool
+	acs        []AdditionalCost
+	cv         CommonVariables
+	reading    meter.Reading
+}
+
+func (r *fakeRecord) Name() string                          { return r.name }
+func (r *fakeRecord) MeterNumber() (meter.Number, error)    { return r.number, nil }
+func (r *fakeRecord) SiteNumber() (meter.SiteNumber, error) { return "", nil }
+func (r *fakeRecord) Reference() reference.Number           { return r.ref }
+func (r *fakeRecord) Membership() (period.Period, error)    { return r.membership, nil }
+func (r *fakeRecord) AddReading(rdg meter.Reading) error    { r.reading = rdg; return nil }
+func (r *fakeRecord) ExchangeMeter(meter.Exchange) error    { return nil }
+func (r *fakeRecord) Bill() (Bill, error)                   { return Bill{}, nil }
+func (r *fakeRecord) Reading() (meter.Reading, error)       { return r.reading, nil }
+func (r *fakeRecord) CorrectReading(meter.Reading) error    { return nil }
+func (r *fakeRecord) UpdateBilling(ref reference.Number, cv CommonVariables, acs []AdditionalCost) error {
+	r.ref = ref
+	r.billed = true
+	r.acs = acs
+	r.cv = cv
+	return nil
+}
+
+func TestUpdater_Update_membership(t *testing.T) {
+	prev := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	main := &fakeRecord{name: "main", ref: "1232"}
+	full := &fakeRecord{name: "full"}
+	joined := &fakeRecord{name: "joined", membership: period.Period{Start: time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)}}
+	left := &fakeRecord{name: "left", membership: period.Period{End: time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)}}
+	d := &fakeData{records: []MeterRecord{main, full, joined, left}, date: prev}
+
+	acs := []AdditionalCost{{Description: "insurance", Cost: decimal.NewFromInt(100)}}
+	u := New(nil, Options{Date: now})
+	if _, err := u.Update(d, acs); err != nil {
+		t.Fatal(err)
+	}
+
+	if left.billed {
+		t.Error("member who left before the billing period was billed")
+	}
+	if got, want := full.acs[0].Cost, decimal.RequireFromString("66.54"); !got.Equal(want) {
+		t.Errorf("full member cost = %v, want %v", got, want)
+	}
+	if got, want := joined.acs[0].Cost, decimal.RequireFromString("33.46"); !got.Equal(want) {
+		t.Errorf("joined member cost = %v, want %v", got, want)
+	}
+	if !d.date.Equal(now) {
+		t.Errorf("date = %v, want %v", d.date, now)
+	}
+}
+
+func TestUpdater_Update_targetedCosts(t *testing.T) {
+	prev := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	main := &fakeRecord{name: "main", ref: "1232"}
+	a := &fakeRecord{name: "a"}
+	b := &fakeRecord{name: "b"}
+	c := &fakeRecord{name: "c", membership: period.Period{Start: time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)}}
+	d := &fakeData{records: []MeterRecord{main, a, b, c}, date: prev}
+
+	acs := []AdditionalCost{
+		{Description: "late fee", Cost: decimal.NewFromInt(5), Target: "b", Rule: Each},
+		{Description: "maintenance", Cost: decimal.NewFromInt(100), Rule: Equally},
+	}
+	u := New(nil, Options{Date: now})
+	if _, err := u.Update(d, acs); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(a.acs) != 1 || a.acs[0].Description != "maintenance" || !a.acs[0].Cost.Equal(decimal.RequireFromString("33.34")) {
+		t.Errorf("costs of a = %+v", a.acs)
+	}
+	if len(b.acs) != 2 || b.acs[0].Description != "late fee" || !b.acs[0].Cost.Equal(decimal.NewFromInt(5)) {
+		t.Errorf("costs of b = %+v", b.acs)
+	}
+	if len(c.acs) != 1 || !c.acs[0].Cost.Equal(decimal.RequireFromString("33.33")) {
+		t.Errorf("costs of c = %+v", c.acs)
+	}
+}
+
+func TestUpdater_Update_basicFees(t *testing.T) {
+	prev := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	main := &fakeRecord{name: "main", number: "M0", ref: "1232"}
+	a := &fakeRecord{name: "a", number: "M1"}
+	b := &fakeRecord{name: "b", number: "M2"}
+	seller := &fakeRecord{name: "seller", number: "M3", membership: period.Period{End: time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)}}
+	buyer := &fakeRecord{name: "buyer", number: "M3", membership: period.Period{Start: time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)}}
+	left := &fakeRecord{name: "left", number: "M4", membership: period.Period{End: time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)}}
+	unmetered := &fakeRecord{name: "unmetered"}
+	for _, r := range []*fakeRecord{main, a, b, seller, buyer} {
+		r.reading = meter.Reading{Date: now}
+	}
+	d := &fakeData{
+		records: []MeterRecord{main, a, b, seller, buyer, left, unmetered},
+		date:    prev,
+		cv:      CommonVariables{MainMeterFee: decimal.NewFromInt(10)},
+	}
+
+	if _, err := New(nil, Options{Date: now, MonthConvention: period.Actual360}).Update(d, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// 181 days are 6.0333 months of 30 days, 60.33 € in all
+	var sum decimal.Decimal
+	for _, r := range []*fakeRecord{a, b, seller, buyer} {
+		if r.cv.BasicFee == nil {
+			t.Fatalf("no basic fee for %s", r.name)
+		}
+		sum = sum.Add(*r.cv.BasicFee)
+	}
+	if want := decimal.RequireFromString("60.33"); !sum.Equal(want) {
+		t.Errorf("basic fees sum to %s, want %s", sum, want)
+	}
+	if got := seller.cv.BasicFee.Add(*buyer.cv.BasicFee); got.Sub(*a.cv.BasicFee).Abs().GreaterThan(decimal.RequireFromString("0.01")) {
+		t.Errorf("seller and buyer pay %s, a pays %s", got, a.cv.BasicFee)
+	}
+	if left.billed {
+		t.Error("member who left before the billing period was billed")
+	}
+	if unmetered.cv.BasicFee == nil || !unmetered.cv.BasicFee.IsZero() {
+		t.Errorf("basic fee of a member without a meter = %v, want 0", unmetered.cv.BasicFee)
+	}
+}
+
+func TestMeteredLines_basicFee(t *testing.T) {
+	readings := period.New(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC))
+	fee := decimal.RequireFromString("33.34")
+	cv := CommonVariables{
+		VAT:             decimal.RequireFromString("25.5"),
+		VATChanges:      []VATChange{{Date: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC), Old: decimal.NewFromInt(24), New: decimal.RequireFromString("25.5")}},
+		BasicFee:        &fee,
+		MonthConvention: period.Actual365,
+	}
+
+	lines, _ := MeteredLines(decimal.Zero, readings, period.Period{}, cv)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %+v", len(lines), lines)
+	}
+	if got := SumLines("", lines).WithoutTax; !got.Equal(fee) {
+		t.Errorf("basic fee lines sum to %s, want %s", got, fee)
+	}
+	if !lines[0].VAT.Equal(decimal.NewFromInt(24)) || !lines[1].VAT.Equal(cv.VAT) {
+		t.Errorf("VAT rates %s and %s, want 24 and %s", lines[0].VAT, lines[1].VAT, cv.VAT)
+	}
+}
+
+// fakeReader reads each meter at 100 on the date.
+type fakeReader struct {
+	date  time.Time
+	reads int
+}
+
+func (r *fakeReader) ReadMeter(meter.SiteNumber, meter.Number) (meter.Reading, error) {
+	r.reads++
+	return meter.Reading{Counter: 100, Date: r.date}, nil
+}
+
+func TestUpdater_ReadMeters_rerun(t *testing.T) {
+	prev := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	main := &fakeRecord{name: "main", number: "M0", reading: meter.Reading{Date: prev}}
+	a := &fakeRecord{name: "a", number: "M1", reading: meter.Reading{Date: prev}}
+	d := &fakeData{records: []MeterRecord{main, a}, date: prev}
+
+	mr := &fakeReader{date: now}
+	if err := New(mr, Options{Date: now}).ReadMeters(d); err != nil {
+		t.Fatal(err)
+	}
+	if mr.reads != 2 || !a.reading.Date.Equal(now) {
+		t.Fatalf("read %d meters, latest reading of a on %v", mr.reads, a.reading.Date)
+	}
+
+	// Reading again before billing would replace the start of the period
+	err := New(mr, Options{Date: now.AddDate(0, 0, 1)}).ReadMeters(d)
+	if !errors.Is(err, ErrAlreadyRead) {
+		t.Errorf("second read: error %v, want %v", err, ErrAlreadyRead)
+	}
+	if mr.reads != 2 {
+		t.Errorf("second read read %d meters", mr.reads-2)
+	}
+}
+
+func TestUpdater_Update_rerun(t *testing.T) {
+	prev := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	main := &fakeRecord{name: "main", number: "M0", ref: "1232", reading: meter.Reading{Date: now}}
+	a := &fakeRecord{name: "a", number: "M1", reading: meter.Reading{Date: now}}
+	d := &fakeData{records: []MeterRecord{main, a}, date: prev}
+
+	if _, err := New(nil, Options{Date: now}).Update(d, nil); err != nil {
+		t.Fatal(err)
+	}
+	a.billed = false
+
+	// The readings have been billed already
+	_, err := New(nil, Options{Date: now.AddDate(0, 0, 1)}).Update(d, nil)
+	if !errors.Is(err, ErrNotRead) {
+		t.Errorf("second bill: error %v, want %v", err, ErrNotRead)
+	}
+	if a.billed {
+		t.Error("second bill billed a")
+	}
+	if !d.date.Equal(now) {
+		t.Errorf("date = %v, want %v", d.date, now)
+	}
+}
+
+func TestUpdater_Update_unknownTarget(t *testing.T) {
+	d := &fakeData{records: []MeterRecord{&fakeRecord{name: "main"}, &fakeRecord{name: "a"}}}
+	acs := []AdditionalCost{{Description: "late fee", Cost: decimal.NewFromInt(5), Target: "x"}}
+
+	if _, err := New(nil, Options{Date: time.Now()}).Update(d, acs); err == nil {
+		t.Error("Update succeeded, want error")
+	}
+}
+
+func TestUpdater_commonVariables_vat(t *testing.T) {
+	d := &fakeData{cv: CommonVariables{
+		VAT:        decimal.RequireFromString("25.5"),
+		VATChanges: []VATChange{{Date: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC), Old: decimal.NewFromInt(24), New: decimal.RequireFromString("25.5")}},
+		WaterPrices: []PriceComponent{
+			{Label: "Vesi", VAT: decimal.RequireFromString("25.5"), Price: decimal.NewFromInt(2)},
+			{Label: "Muu", VAT: decimal.NewFromInt(14), Price: decimal.NewFromInt(1)},
+		},
+	}}
+	vat := decimal.NewFromInt(10)
+
+	cv, err := New(nil, Options{VAT: &vat}).commonVariables(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !cv.VAT.Equal(vat) {
+		t.Errorf("VAT = %s, want %s", cv.VAT, vat)
+	}
+	if got := cv.WaterPrices[0].VAT; !got.Equal(vat) {
+		t.Errorf("VAT of the general rate component = %s, want %s", got, vat)
+	}
+	if got := cv.WaterPrices[1].VAT; !got.Equal(decimal.NewFromInt(14)) {
+		t.Errorf("VAT of the reduced rate component = %s, want 14", got)
+	}
+	if c := cv.VATChanges[0]; !c.Old.Equal(decimal.NewFromInt(24)) || !c.New.Equal(vat) {
+		t.Errorf("VAT change = %s -> %s, want 24 -> %s", c.Old, c.New, vat)
+	}
+	if got := d.cv.WaterPrices[0].VAT; !got.Equal(decimal.RequireFromString("25.5")) {
+		t.Errorf("VAT of the data changed to %s", got)
+	}
+
+	// Configured prices keep their own rates
+	prices := []PriceComponent{{Label: "Vesi", VAT: decimal.RequireFromString("25.5"), Price: decimal.NewFromInt(3)}}
+	cv, err = New(nil, Options{VAT: &vat, WaterPrices: prices}).commonVariables(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cv.WaterPrices[0].VAT; !got.Equal(decimal.RequireFromString("25.5")) {
+		t.Errorf("VAT of the configured component = %s, want 25.5", got)
+	}
+}
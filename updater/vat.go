@@ -0,0 +1,51 @@
+package updater
+
+import (
+	"time"
+
+	"github.com/jarnoan/vesimittari/period"
+	"github.com/shopspring/decimal"
+)
+
+// VATChange is a change of a VAT rate on a date.
+type VATChange struct {
+	Date time.Time
+	Old  decimal.Decimal // % before the date
+	New  decimal.Decimal // % from the date on
+}
+
+// affects tells whether the line is billed with the changing rate.
+func (c VATChange) affects(l BillLine) bool {
+	return l.VAT.Equal(c.Old) || l.VAT.Equal(c.New)
+}
+
+// SplitVAT applies the VAT changes to a line accrued evenly during the
+// period. A line whose period straddles a change is split by days into
+// lines before and after the change, each with its own rate.
+func SplitVAT(l BillLine, p period.Period, changes []VATChange) []BillLine {
+	for i, c := range changes {
+		if !c.affects(l) {
+			continue
+		}
+
+		before := period.New(p.Start, c.Date).Clip(p)
+		after := period.New(c.Date, p.End).Clip(p)
+		if before.Days() == 0 || after.Days() == 0 {
+			rate := c.New
+			if p.Start.Before(c.Date) {
+				rate = c.Old
+			}
+			l = NewBillLine(l.Kind, l.Description, l.Quantity, l.Unit, l.UnitPrice, rate)
+			continue
+		}
+
+		days := decimal.NewFromInt(int64(p.Days()))
+		qtyBefore := l.Quantity.Mul(decimal.NewFromInt(int64(before.Days()))).Div(days).Round(2)
+		lBefore := NewBillLine(l.Kind, l.Description+" ennen "+c.Date.Format("2.1.2006"), qtyBefore, l.Unit, l.UnitPrice, c.Old)
+		lAfter := NewBillLine(l.Kind, l.Description+" "+c.Date.Format("2.1.2006")+" alkaen", l.Quantity.Sub(qtyBefore), l.Unit, l.UnitPrice, c.New)
+
+		return append(SplitVAT(lBefore, before, changes[i+1:]), SplitVAT(lAfter, after, changes[i+1:])...)
+	}
+
+	return []BillLine{l}
+}
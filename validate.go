@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/jarnoan/vesimittari/config"
+)
+
+// runValidate checks every meter row and parameter row of the data and
+// reports all the problems found. Optionally the contact details and property
+// IDs of the members are normalised and the data written.
+func runValidate(cfg config.Config, args []string) error {
+	fs := newFlagSet("validate")
+	normalize := fs.Bool("normalize", false, "write bank accounts, phone numbers, email addresses and property IDs in standard format and write the data")
+	fs.Parse(args)
+
+	csvf, file, err := readData(cfg)
+	if err != nil {
+		return err
+	}
+
+	problems := csvf.Validate()
+	for _, p := range problems {
+		log.Print(p)
+	}
+
+	if *normalize {
+		log.Printf("%d values normalised", csvf.Normalize())
+		if err := writeData(file, csvf); err != nil {
+			return err
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found", len(problems))
+	}
+
+	return nil
+}